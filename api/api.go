@@ -1,5 +1,11 @@
 package api
 
+import (
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
 // AWS API service configuration
 type ServiceConfig struct {
 
@@ -9,13 +15,94 @@ type ServiceConfig struct {
 	// Cognito user pool ID
 	UserPoolID string
 
-	// User pool resource app 
-	// client ID and secret
+	// User pool resource app client ID and
+	// secret. the secret should be left empty
+	// for public clients such as distributed
+	// desktop apps which login using PKCE.
 	CliendID, 
 	ClientSecret string
 
 	// Endpoint URLs
 	AuthURL, 
 	TokenURL,
+	DeviceAuthURL,
 	ApiURL string
+
+	// OIDC issuer URL of an identity provider
+	// other than the Cognito user pool. the
+	// provider's endpoints are discovered if
+	// the auth and token URLs are not set.
+	IssuerURL string
+
+	// Maps the identity provider's id token
+	// claims to a user's identity attributes.
+	// claims that are not set are mapped using
+	// the identity provider's default mapping.
+	Claims *ClaimMapping
+
+	// Maximum difference allowed between the
+	// client's and the identity provider's
+	// clocks when validating token expiry
+	ClockSkew time.Duration
+}
+
+// names of the id token claims that
+// hold a user's identity attributes
+type ClaimMapping struct {
+	UserID,
+	Username,
+	Preferences,
+	KeyTimestamp,
+	ConfigTimestamp string
+
+	// claim which identifies the type of
+	// token. if set id tokens must have
+	// this claim with the value "id".
+	TokenUse string
+}
+
+// returns the claim mapping with the claims
+// that have been set in the given mapping
+// overriding the claims of this mapping
+func (m ClaimMapping) Merge(overrides *ClaimMapping) ClaimMapping {
+
+	if overrides == nil {
+		return m
+	}
+	merge := func(claim *string, override string) {
+		if len(override) > 0 {
+			*claim = override
+		}
+	}
+	merge(&m.UserID, overrides.UserID)
+	merge(&m.Username, overrides.Username)
+	merge(&m.Preferences, overrides.Preferences)
+	merge(&m.KeyTimestamp, overrides.KeyTimestamp)
+	merge(&m.ConfigTimestamp, overrides.ConfigTimestamp)
+	merge(&m.TokenUse, overrides.TokenUse)
+	return m
+}
+
+// returns the oauth configuration for 
+// the service's user pool app client
+func (c ServiceConfig) OAuthConfig() *oauth2.Config {
+
+	oauthConfig := &oauth2.Config{
+		ClientID:     c.CliendID,
+		ClientSecret: c.ClientSecret,
+		Scopes:       []string{"openid", "profile"},
+
+		Endpoint: oauth2.Endpoint{
+			AuthURL:       c.AuthURL,
+			TokenURL:      c.TokenURL,
+			DeviceAuthURL: c.DeviceAuthURL,
+		},
+	}
+	if len(c.ClientSecret) == 0 {
+		// public clients identify themselves
+		// with the client id in the request
+		// body as they have no credentials
+		oauthConfig.Endpoint.AuthStyle = oauth2.AuthStyleInParams
+	}
+	return oauthConfig
 }
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	graphql "github.com/hasura/go-graphql-client"
+)
+
+// returns the type of the first error in a
+// graphql error response from the MyCS cloud
+// API service. an empty string is returned if
+// the error is not a graphql error response.
+func ErrorType(err error) string {
+
+	var (
+		gqlErrors graphql.Errors
+	)
+
+	if errors.As(err, &gqlErrors) && len(gqlErrors) > 0 {
+		if errorType, ok := gqlErrors[0].Extensions["errorType"].(string); ok {
+			return errorType
+		}
+	}
+	return ""
+}
+
+// graphql error types of responses that were
+// rejected with an HTTP error status instead
+// of a graphql error response
+var statusErrorTypes = map[int]string{
+	http.StatusBadRequest:   "BadRequest",
+	http.StatusUnauthorized: "Unauthorized",
+	http.StatusForbidden:    "AccessDenied",
+	http.StatusNotFound:     "NotFound",
+	http.StatusConflict:     "Conflict",
+}
+
+// AppSync returns the type of each error in a
+// graphql response as a top-level "errorType"
+// field which is discarded by the graphql
+// client. this transport copies the field to
+// the error's extensions so it is retained.
+// responses with an HTTP error status that has
+// a known error type are returned as graphql
+// error responses of that type.
+type errorTypeTransport struct {
+	transport http.RoundTripper
+}
+
+func (t *errorTypeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	var (
+		err error
+
+		resp *http.Response
+		body []byte
+	)
+
+	if resp, err = t.transport.RoundTrip(req); err != nil {
+		return resp, err
+	}
+	errorType, isTypedStatus := statusErrorTypes[resp.StatusCode]
+	if resp.StatusCode != http.StatusOK && !isTypedStatus {
+		return resp, nil
+	}
+
+	body, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if isTypedStatus {
+		if body, err = statusErrorResponse(resp.Status, errorType); err != nil {
+			return nil, err
+		}
+		resp.StatusCode = http.StatusOK
+		resp.Status = "200 OK"
+		resp.Header.Set("Content-Type", "application/json")
+	} else if bytes.Contains(body, []byte(`"errorType"`)) {
+		body = copyErrorTypes(body)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Del("Content-Length")
+	return resp, nil
+}
+
+// returns a graphql error response
+// for an HTTP error status
+func statusErrorResponse(status, errorType string) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"data": nil,
+		"errors": []map[string]interface{}{
+			{
+				"message": fmt.Sprintf("service responded with status '%s'", status),
+				"extensions": map[string]interface{}{
+					"errorType": errorType,
+				},
+			},
+		},
+	})
+}
+
+func copyErrorTypes(body []byte) []byte {
+
+	var (
+		err error
+
+		response   map[string]json.RawMessage
+		respErrors []map[string]interface{}
+		newBody    []byte
+	)
+
+	if err = json.Unmarshal(body, &response); err != nil {
+		return body
+	}
+	if err = json.Unmarshal(response["errors"], &respErrors); err != nil {
+		return body
+	}
+	for _, respError := range respErrors {
+		if errorType, ok := respError["errorType"]; ok {
+			extensions, _ := respError["extensions"].(map[string]interface{})
+			if extensions == nil {
+				extensions = make(map[string]interface{})
+				respError["extensions"] = extensions
+			}
+			if _, exists := extensions["errorType"]; !exists {
+				extensions["errorType"] = errorType
+			}
+		}
+	}
+	if response["errors"], err = json.Marshal(respErrors); err != nil {
+		return body
+	}
+	if newBody, err = json.Marshal(response); err != nil {
+		return body
+	}
+	return newBody
+}
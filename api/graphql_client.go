@@ -1,24 +1,46 @@
 package api
 
 import (
+	"context"
 	"net"
 	"net/http"
 	"time"
 
 	graphql "github.com/hasura/go-graphql-client"
+	"golang.org/x/oauth2"
 
 	"github.com/appbricks/cloud-builder/config"
+	"github.com/mevansam/goutils/logger"
 )
 
 // returns a graphql client for querying
 // the MyCS cloud API service
 func NewGraphQLClient(apiUrl, subUrl string, authContext config.AuthContext) *graphql.Client {
+	return NewGraphQLClientWithTokenSource(apiUrl, subUrl, newAuthContextTokenSource(authContext))
+}
+
+// returns a graphql client for querying the
+// MyCS cloud API service which refreshes the 
+// auth context token when it is about to expire
+func NewGraphQLClientForService(
+	ctx context.Context,
+	serviceConfig ServiceConfig, 
+	authContext config.AuthContext,
+) *graphql.Client {
+	
+	return NewGraphQLClientWithTokenSource(
+		serviceConfig.ApiURL, "", 
+		NewAuthTokenSource(ctx, serviceConfig, authContext),
+	)
+}
+
+// returns a graphql client for querying the MyCS 
+// cloud API service which authorizes requests with
+// the token returned by the given token source
+func NewGraphQLClientWithTokenSource(apiUrl, subUrl string, tokenSource oauth2.TokenSource) *graphql.Client {
 
 	return graphql.NewClient(apiUrl, &http.Client{
-		Transport: authHeader{
-			idToken:   authContext.GetToken().Extra("id_token").(string),
-			transport: http.DefaultTransport,
-		},
+		Transport: newTransport(tokenSource, http.DefaultTransport),
 	})
 }
 
@@ -27,34 +49,115 @@ func NewGraphQLClient(apiUrl, subUrl string, authContext config.AuthContext) *gr
 // pooled for reuse and has a very short
 // timeout
 func NewGraphQLClientNoPool(apiUrl, subUrl string, config config.Config) *graphql.Client {
+	return newGraphQLClientNoPool(apiUrl, newAuthContextTokenSource(config.AuthContext()))
+}
+
+// returns a graphql client for querying the
+// MyCS cloud API service which is not pooled
+// for reuse and has a very short timeout. the
+// auth context token is refreshed when it is
+// about to expire.
+func NewGraphQLClientNoPoolForService(
+	ctx context.Context,
+	serviceConfig ServiceConfig,
+	authContext config.AuthContext,
+) *graphql.Client {
+	return newGraphQLClientNoPool(serviceConfig.ApiURL, NewAuthTokenSource(ctx, serviceConfig, authContext))
+}
+
+// the short timeout applies to each attempt
+// and not to the client as a whole so that
+// retries and the replay of a request with
+// a refreshed token are not cut short. the
+// request's context bounds the overall call.
+func newGraphQLClientNoPool(apiUrl string, tokenSource oauth2.TokenSource) *graphql.Client {
 
 	transport := http.DefaultTransport.(*http.Transport).Clone()
 	transport.DisableKeepAlives = true
 	transport.MaxIdleConnsPerHost = -1
 	transport.TLSHandshakeTimeout = 1000 * time.Millisecond
+	transport.ResponseHeaderTimeout = 5000 * time.Millisecond
 	transport.DialContext = (&net.Dialer{ Timeout: 1000 * time.Millisecond }).DialContext
 	
 	return graphql.NewClient(apiUrl, &http.Client{
-		Transport: authHeader{
-			idToken:   config.AuthContext().GetToken().Extra("id_token").(string),
-			transport: transport,
-		},
-		Timeout: 5000 * time.Millisecond,
+		Transport: newTransport(tokenSource, transport),
 	})
 }
 
-// AWS Cognito authorization token
-type authHeader struct {
-	idToken   string
-	transport http.RoundTripper
+// returns the transport chain for requests 
+// to the MyCS cloud API service. error types
+// are resolved last so that the auth transport
+// sees the unauthorized status of a rejected
+// token and can replay the request.
+func newTransport(tokenSource oauth2.TokenSource, transport http.RoundTripper) http.RoundTripper {
+
+	return &errorTypeTransport{
+		transport: &authTransport{
+			tokenSource: tokenSource,
+			transport:   &retryTransport{
+				policy:    DefaultRetryPolicy,
+				transport: transport,
+			},
+		},
+	}
+}
+
+// adds the Cognito authorization id token
+// retrieved from a token source to all 
+// client query requests
+type authTransport struct {
+	tokenSource oauth2.TokenSource
+	transport   http.RoundTripper
 }
 
-// adds the Cognito authorization id 
-// token to all client query requests
-func (h authHeader) RoundTrip(req *http.Request) (*http.Response, error) {
-	req.Header.Add("Connection", "keep-alive")
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Accept", "*/*")
-	req.Header.Add("Authorization", h.idToken)
-	return h.transport.RoundTrip(req)
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	var (
+		err error
+
+		token *oauth2.Token
+		resp  *http.Response
+	)
+
+	if token, err = t.tokenSource.Token(); err != nil {
+		return nil, err
+	}
+	if resp, err = t.transport.RoundTrip(authorizeRequest(req, token)); err != nil {
+		return nil, err
+	}
+	
+	// replay the request once with a refreshed 
+	// token if the current token was rejected
+	if resp.StatusCode == http.StatusUnauthorized && req.GetBody != nil {
+		if ts, ok := t.tokenSource.(*AuthTokenSource); ok {
+			if token, err = ts.Refresh(token); err != nil {
+				logger.DebugMessage(
+					"authTransport.RoundTrip(): Request was unauthorized and token could not be refreshed: %s", 
+					err.Error(),
+				)
+				return resp, nil
+			}
+			replay := authorizeRequest(req, token)
+			if replay.Body, err = req.GetBody(); err != nil {
+				return resp, nil
+			}
+			resp.Body.Close()
+
+			logger.DebugMessage("authTransport.RoundTrip(): Replaying unauthorized request with refreshed token.")
+			return t.transport.RoundTrip(replay)
+		}
+	}
+	return resp, nil
+}
+
+// returns a copy of the given request 
+// with the authorization headers set
+func authorizeRequest(req *http.Request, token *oauth2.Token) *http.Request {
+	
+	authReq := req.Clone(req.Context())
+	authReq.Header.Set("Connection", "keep-alive")
+	authReq.Header.Set("Content-Type", "application/json")
+	authReq.Header.Set("Accept", "*/*")
+	authReq.Header.Set("Authorization", IDToken(token))
+	return authReq
 }
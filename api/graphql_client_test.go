@@ -2,6 +2,12 @@ package api_test
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
 
 	graphql "github.com/hasura/go-graphql-client"
 	. "github.com/onsi/ginkgo"
@@ -79,4 +85,118 @@ var _ = Describe("Auth Context", func() {
 		Expect(err).ToNot(HaveOccurred())
 		Expect(q.Test.Name).To(Equal(graphql.String("test9999")))
 	})
+
+	Context("token refresh", func() {
+
+		var (
+			authServer *httptest.Server
+
+			refreshCount     atomic.Int32
+			unauthorizedSent atomic.Bool
+		)
+
+		BeforeEach(func() {
+			refreshCount.Store(0)
+			unauthorizedSent.Store(false)
+
+			authServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/token":
+					Expect(r.ParseForm()).To(Succeed())
+					Expect(r.Form.Get("grant_type")).To(Equal("refresh_token"))
+					Expect(r.Form.Get("refresh_token")).To(Equal("mock refresh token"))
+
+					n := refreshCount.Add(1)
+					w.Header().Set("Content-Type", "application/json")
+					fmt.Fprintf(w,
+						`{"access_token":"access token #%d","id_token":"refreshed id token #%d","token_type":"Bearer","expires_in":3600}`,
+						n, n,
+					)
+
+				case "/graphql":
+					body, _ := io.ReadAll(r.Body)
+					Expect(string(body)).To(MatchJSON(`{"query":"{test{id,name}}"}`))
+
+					if r.Header.Get("Authorization") == "rejected id token" {
+						unauthorizedSent.Store(true)
+						w.WriteHeader(http.StatusUnauthorized)
+						return
+					}
+					w.Header().Set("Content-Type", "application/json")
+					fmt.Fprintf(w,
+						`{"data":{"test":{"id":"9999","name":"%s"}}}`,
+						r.Header.Get("Authorization"),
+					)
+				}
+			}))
+		})
+
+		AfterEach(func() {
+			authServer.Close()
+		})
+
+		newClient := func(idToken string, expiry time.Time) *graphql.Client {
+			authContext := config.NewAuthContext()
+			authContext.SetToken(
+				(&oauth2.Token{
+					AccessToken:  "mock access token",
+					RefreshToken: "mock refresh token",
+					Expiry:       expiry,
+				}).WithExtra(
+					map[string]interface{}{
+						"id_token": idToken,
+					},
+				),
+			)
+			cfg = mocks.NewMockConfig(authContext, nil, nil)
+
+			return api.NewGraphQLClientForService(
+				context.Background(),
+				api.ServiceConfig{
+					CliendID: "mock client id",
+					TokenURL: authServer.URL + "/token",
+					ApiURL:   authServer.URL + "/graphql",
+				},
+				cfg.AuthContext(),
+			)
+		}
+
+		var q struct {
+			Test struct {
+				ID   graphql.ID
+				Name graphql.String
+			}
+		}
+
+		It("refreshes a token that is about to expire", func() {
+			client := newClient("expiring id token", time.Now().Add(time.Minute))
+
+			err = client.Query(context.Background(), &q, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(q.Test.Name).To(Equal(graphql.String("refreshed id token #1")))
+			Expect(refreshCount.Load()).To(Equal(int32(1)))
+
+			// refreshed token should have been saved to the auth context
+			token := cfg.AuthContext().GetToken()
+			Expect(token.Extra("id_token")).To(Equal("refreshed id token #1"))
+			Expect(token.RefreshToken).To(Equal("mock refresh token"))
+
+			// token should not be refreshed again
+			err = client.Query(context.Background(), &q, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(q.Test.Name).To(Equal(graphql.String("refreshed id token #1")))
+			Expect(refreshCount.Load()).To(Equal(int32(1)))
+		})
+
+		It("replays an unauthorized request once with a refreshed token", func() {
+			client := newClient("rejected id token", time.Now().Add(time.Hour))
+
+			err = client.Query(context.Background(), &q, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(unauthorizedSent.Load()).To(BeTrue())
+			Expect(q.Test.Name).To(Equal(graphql.String("refreshed id token #1")))
+			Expect(refreshCount.Load()).To(Equal(int32(1)))
+			Expect(cfg.AuthContext().GetToken().Extra("id_token")).To(Equal("refreshed id token #1"))
+		})
+	})
 })
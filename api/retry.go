@@ -0,0 +1,230 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mevansam/goutils/logger"
+)
+
+// policy for retrying failed requests
+// to the MyCS cloud API service
+type RetryPolicy struct {
+
+	// maximum number of times a request
+	// will be attempted including the
+	// first attempt
+	MaxAttempts int
+
+	// the backoff before the first retry which
+	// is multiplied by the backoff multiplier
+	// for each subsequent retry up to the
+	// maximum backoff
+	InitialBackoff,
+	MaxBackoff time.Duration
+	Multiplier float64
+
+	// fraction of the backoff by which the
+	// backoff is randomly increased or
+	// decreased to spread out retries
+	Jitter float64
+
+	// overall time limit for all attempts.
+	// a request will not be retried if the
+	// next attempt would start after the
+	// deadline.
+	Deadline time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 250 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+	Deadline:       30 * time.Second,
+}
+
+type retryContextKey int
+
+const (
+	retryPolicyKey retryContextKey = iota
+	retryMutationKey
+)
+
+// returns a context which overrides the
+// retry policy of requests made with it
+func WithRetryPolicy(ctx context.Context, policy RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryPolicyKey, policy)
+}
+
+// returns a context which marks mutations made
+// with it as safe to retry. mutations are not
+// retried by default as they may not be
+// idempotent.
+func WithMutationRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryMutationKey, true)
+}
+
+// returns the backoff before the given retry
+func (p RetryPolicy) backoff(retry int) time.Duration {
+
+	backoff := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(retry-1))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		backoff = backoff * (1 - p.Jitter + 2*p.Jitter*rand.Float64())
+	}
+	return time.Duration(backoff)
+}
+
+// retries queries and mutations marked as safe
+// to retry when the service cannot be reached
+// or responds with a transient error status
+type retryTransport struct {
+	policy    RetryPolicy
+	transport http.RoundTripper
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	var (
+		err error
+
+		resp    *http.Response
+		body    io.ReadCloser
+		attempt int
+	)
+
+	policy := t.policy
+	if p, ok := req.Context().Value(retryPolicyKey).(RetryPolicy); ok {
+		policy = p
+	}
+	if policy.MaxAttempts <= 1 || !isRetryable(req) {
+		return t.transport.RoundTrip(req)
+	}
+	deadline := time.Now().Add(policy.Deadline)
+
+	for attempt = 1; ; attempt++ {
+		attemptReq := req
+		if attempt > 1 {
+			if body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(req.Context())
+			attemptReq.Body = body
+		}
+
+		resp, err = t.transport.RoundTrip(attemptReq)
+		if !shouldRetry(req, resp, err) {
+			break
+		}
+		if attempt == policy.MaxAttempts {
+			break
+		}
+		backoff := policy.backoff(attempt)
+		if policy.Deadline > 0 && time.Now().Add(backoff).After(deadline) {
+			logger.DebugMessage(
+				"retryTransport.RoundTrip(): Not retrying request as the retry deadline of %s would be exceeded.",
+				policy.Deadline,
+			)
+			break
+		}
+		logger.DebugMessage(
+			"retryTransport.RoundTrip(): Attempt %d of %d failed: %s. Retrying in %s.",
+			attempt, policy.MaxAttempts, failureReason(resp, err), backoff,
+		)
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+
+	if attempt > 1 {
+		if err != nil || resp.StatusCode >= http.StatusBadRequest {
+			logger.ErrorMessage(
+				"retryTransport.RoundTrip(): Request failed after %d attempts: %s",
+				attempt, failureReason(resp, err),
+			)
+		} else {
+			logger.DebugMessage(
+				"retryTransport.RoundTrip(): Request succeeded after %d attempts.",
+				attempt,
+			)
+		}
+	}
+	return resp, err
+}
+
+// returns whether the given request can be retried.
+// all queries are retryable whereas mutations are
+// only retryable if they have been marked as safe.
+func isRetryable(req *http.Request) bool {
+
+	if req.GetBody == nil {
+		return false
+	}
+	if retry, _ := req.Context().Value(retryMutationKey).(bool); retry {
+		return true
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return false
+	}
+	defer body.Close()
+
+	var gqlRequest struct {
+		Query string `json:"query"`
+	}
+	if err = json.NewDecoder(body).Decode(&gqlRequest); err != nil {
+		return false
+	}
+	return !strings.HasPrefix(strings.TrimSpace(gqlRequest.Query), "mutation")
+}
+
+// returns whether a failed attempt should be
+// retried. requests are retried on network
+// errors and on transient error responses.
+func shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+
+	if err != nil {
+		if req.Context().Err() != nil ||
+			errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func failureReason(resp *http.Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("service responded with status '%s'", resp.Status)
+}
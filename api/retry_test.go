@@ -0,0 +1,129 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	graphql "github.com/hasura/go-graphql-client"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/oauth2"
+
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/appbricks/mycloudspace-client/api"
+)
+
+var _ = Describe("Request Retries", func() {
+
+	var (
+		err error
+
+		testServer *httptest.Server
+		client     *graphql.Client
+
+		requestCount atomic.Int32
+		failCount    int32
+
+		ctx context.Context
+	)
+
+	BeforeEach(func() {
+		requestCount.Store(0)
+		failCount = 0
+
+		testServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if requestCount.Add(1) <= failCount {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"data":{"test":{"id":"9999","name":"test9999"}}}`))
+		}))
+
+		authContext := config.NewAuthContext()
+		authContext.SetToken(
+			(&oauth2.Token{}).WithExtra(
+				map[string]interface{}{
+					"id_token": "mock authorization token",
+				},
+			),
+		)
+		client = api.NewGraphQLClient(testServer.URL, "", authContext)
+
+		ctx = api.WithRetryPolicy(context.Background(), api.RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: 10 * time.Millisecond,
+			MaxBackoff:     50 * time.Millisecond,
+			Multiplier:     2,
+			Jitter:         0.2,
+			Deadline:       time.Second,
+		})
+	})
+
+	AfterEach(func() {
+		testServer.Close()
+	})
+
+	var q struct {
+		Test struct {
+			ID   graphql.ID
+			Name graphql.String
+		}
+	}
+	var m struct {
+		Test struct {
+			ID   graphql.ID
+			Name graphql.String
+		}
+	}
+
+	It("retries queries that fail with a transient error", func() {
+		failCount = 2
+
+		err = client.Query(ctx, &q, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(q.Test.Name).To(Equal(graphql.String("test9999")))
+		Expect(requestCount.Load()).To(Equal(int32(3)))
+	})
+
+	It("stops retrying after the maximum number of attempts", func() {
+		failCount = 5
+
+		err = client.Query(ctx, &q, nil)
+		Expect(err).To(HaveOccurred())
+		Expect(requestCount.Load()).To(Equal(int32(3)))
+	})
+
+	It("stops retrying when the deadline would be exceeded", func() {
+		failCount = 5
+
+		err = client.Query(
+			api.WithRetryPolicy(context.Background(), api.RetryPolicy{
+				MaxAttempts:    5,
+				InitialBackoff: 200 * time.Millisecond,
+				Multiplier:     2,
+				Deadline:       500 * time.Millisecond,
+			}),
+			&q, nil,
+		)
+		Expect(err).To(HaveOccurred())
+		Expect(requestCount.Load()).To(Equal(int32(2)))
+	})
+
+	It("only retries mutations marked as safe to retry", func() {
+		failCount = 1
+
+		err = client.Mutate(ctx, &m, nil)
+		Expect(err).To(HaveOccurred())
+		Expect(requestCount.Load()).To(Equal(int32(1)))
+
+		requestCount.Store(0)
+		err = client.Mutate(api.WithMutationRetry(ctx), &m, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(m.Test.Name).To(Equal(graphql.String("test9999")))
+		Expect(requestCount.Load()).To(Equal(int32(2)))
+	})
+})
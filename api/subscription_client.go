@@ -0,0 +1,360 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	graphql "github.com/hasura/go-graphql-client"
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/mevansam/goutils/logger"
+)
+
+const (
+	// max time to wait for a message from the
+	// subscription service before the connection
+	// is considered to be dead
+	subscriptionReadTimeout = 5 * time.Minute
+	// time allowed to establish a connection
+	subscriptionRetryTimeout = 30 * time.Second
+	// delays between attempts to re-establish
+	// a subscription connection that was lost
+	subscriptionMinRetryDelay = time.Second
+	subscriptionMaxRetryDelay = time.Minute
+)
+
+// a subscription client for the MyCS cloud
+// API service. subscriptions added to the
+// client are re-established whenever the
+// underlying websocket connection is lost
+type SubscriptionClient struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	subUrl      string
+	authContext config.AuthContext
+
+	// subscriptions that will be sent to
+	// the service each time it connects
+	subscriptions map[string]*subscription
+	nextID        int
+
+	// current connection to the service
+	client    *graphql.SubscriptionClient
+	connected bool
+
+	mx      sync.Mutex
+	running sync.WaitGroup
+}
+
+type subscription struct {
+	query     interface{}
+	variables map[string]interface{}
+	handler   func(data *json.RawMessage, err error)
+
+	// id of the subscription with
+	// the current service connection
+	clientID string
+}
+
+// returns a graphql subscription client for
+// receiving events from the MyCS cloud API
+// service. the client will not connect to
+// the service until it is started.
+func NewSubscriptionClient(subUrl string, authContext config.AuthContext) *SubscriptionClient {
+
+	return &SubscriptionClient{
+		subUrl:      subUrl,
+		authContext: authContext,
+
+		subscriptions: make(map[string]*subscription),
+	}
+}
+
+// adds a subscription. the given query should be
+// a pointer to a struct that corresponds to the
+// graphql subscription schema and the handler
+// will be called with the raw data of each event
+// received for the subscription.
+func (sc *SubscriptionClient) Subscribe(
+	query interface{},
+	variables map[string]interface{},
+	handler func(data *json.RawMessage, err error),
+) (string, error) {
+
+	sc.mx.Lock()
+	defer sc.mx.Unlock()
+
+	var (
+		err error
+	)
+
+	sc.nextID++
+	id := fmt.Sprintf("sub-%d", sc.nextID)
+
+	sub := &subscription{
+		query:     query,
+		variables: variables,
+		handler:   handler,
+	}
+	if sc.client != nil {
+		if err = sc.subscribe(sc.client, sub); err != nil {
+			return "", err
+		}
+	}
+	sc.subscriptions[id] = sub
+	return id, nil
+}
+
+// removes a subscription
+func (sc *SubscriptionClient) Unsubscribe(id string) error {
+
+	sc.mx.Lock()
+	defer sc.mx.Unlock()
+
+	var (
+		exists bool
+
+		sub *subscription
+	)
+
+	if sub, exists = sc.subscriptions[id]; !exists {
+		return fmt.Errorf("subscription with id '%s' does not exist", id)
+	}
+	delete(sc.subscriptions, id)
+
+	if sc.client != nil && len(sub.clientID) > 0 {
+		return sc.client.Unsubscribe(sub.clientID)
+	}
+	return nil
+}
+
+// returns whether the client is currently
+// connected to the subscription service
+func (sc *SubscriptionClient) IsConnected() bool {
+	sc.mx.Lock()
+	defer sc.mx.Unlock()
+	return sc.connected
+}
+
+// connects to the subscription service and
+// starts receiving events for all subscriptions
+func (sc *SubscriptionClient) Start() {
+
+	sc.ctx, sc.cancel = context.WithCancel(context.Background())
+	sc.running.Add(1)
+
+	go func() {
+		defer sc.running.Done()
+
+		var (
+			err error
+		)
+		retryDelay := subscriptionMinRetryDelay
+
+		for {
+			if client := sc.connect(); client != nil {
+				if err = client.Run(); err != nil {
+					logger.DebugMessage(
+						"SubscriptionClient.Start(): Connection to subscription service at '%s' was lost: %s",
+						sc.subUrl, err.Error(),
+					)
+				}
+				if sc.disconnect(client) {
+					// reset the retry delay as the
+					// connection had been established
+					retryDelay = subscriptionMinRetryDelay
+				}
+			}
+
+			select {
+			case <-sc.ctx.Done():
+				logger.TraceMessage("SubscriptionClient.Start(): Subscription client has stopped.")
+				return
+			case <-time.After(retryDelay):
+				logger.DebugMessage(
+					"SubscriptionClient.Start(): Re-connecting to subscription service at '%s'.",
+					sc.subUrl,
+				)
+			}
+			if retryDelay *= 2; retryDelay > subscriptionMaxRetryDelay {
+				retryDelay = subscriptionMaxRetryDelay
+			}
+		}
+	}()
+}
+
+// disconnects from the subscription service. the
+// websocket connection is bound to the client's
+// context so cancelling it stops a connection in
+// any state including one that is being dialed.
+func (sc *SubscriptionClient) Stop() {
+
+	if sc.cancel == nil {
+		return
+	}
+	sc.cancel()
+	sc.running.Wait()
+}
+
+// creates a new service connection and
+// adds all subscriptions to it
+func (sc *SubscriptionClient) connect() *graphql.SubscriptionClient {
+
+	sc.mx.Lock()
+	defer sc.mx.Unlock()
+
+	var (
+		err error
+	)
+
+	if sc.ctx.Err() != nil {
+		return nil
+	}
+
+	client := graphql.NewSubscriptionClient(sc.subUrl).
+		WithWebSocket(sc.dial).
+		WithTimeout(subscriptionReadTimeout).
+		// a failed connection is retried by
+		// the run loop and not the client
+		WithRetryTimeout(0).
+		WithLog(func(args ...interface{}) {
+			logger.TraceMessage("SubscriptionClient: %# v", args)
+		}).
+		OnConnected(func() {
+			logger.DebugMessage("SubscriptionClient: Connected to subscription service at '%s'.", sc.subUrl)
+			sc.mx.Lock()
+			sc.connected = true
+			sc.mx.Unlock()
+		}).
+		OnError(func(_ *graphql.SubscriptionClient, err error) error {
+			// returning the error closes the connection
+			// so that it can be re-established
+			if sc.ctx.Err() != nil {
+				return err
+			}
+			logger.ErrorMessage("SubscriptionClient: Subscription service connection error: %s", err.Error())
+			return err
+		})
+
+	for id, sub := range sc.subscriptions {
+		if err = sc.subscribe(client, sub); err != nil {
+			logger.ErrorMessage(
+				"SubscriptionClient.connect(): Failed to add subscription '%s': %s",
+				id, err.Error(),
+			)
+		}
+	}
+	sc.client = client
+	return client
+}
+
+// dials the subscription service. this is called
+// each time the connection is established including
+// when the connection is reset by the graphql client
+// so the latest id token is always presented.
+func (sc *SubscriptionClient) dial(client *graphql.SubscriptionClient) (graphql.WebsocketConn, error) {
+
+	var (
+		err error
+
+		conn *websocket.Conn
+	)
+
+	client.WithConnectionParams(map[string]interface{}{
+		"Authorization": IDToken(sc.authContext.GetToken()),
+	})
+
+	ctx, cancel := context.WithTimeout(sc.ctx, subscriptionRetryTimeout)
+	defer cancel()
+
+	if conn, _, err = websocket.Dial(ctx, client.GetURL(), &websocket.DialOptions{
+		Subprotocols: []string{"graphql-ws"},
+	}); err != nil {
+		return nil, err
+	}
+	return &websocketConn{
+		ctx:     sc.ctx,
+		timeout: client.GetTimeout(),
+		Conn:    conn,
+	}, nil
+}
+
+// clears the given service connection and
+// returns whether it had been connected
+func (sc *SubscriptionClient) disconnect(client *graphql.SubscriptionClient) bool {
+
+	sc.mx.Lock()
+	defer sc.mx.Unlock()
+
+	closeClient(client)
+	connected := sc.connected
+
+	sc.client = nil
+	sc.connected = false
+	for _, sub := range sc.subscriptions {
+		sub.clientID = ""
+	}
+	return connected
+}
+
+func (sc *SubscriptionClient) subscribe(client *graphql.SubscriptionClient, sub *subscription) error {
+
+	var (
+		err error
+	)
+
+	handler := sub.handler
+	if sub.clientID, err = client.Subscribe(
+		sub.query,
+		sub.variables,
+		func(data *json.RawMessage, err error) error {
+			// handler errors are not returned as that
+			// would result in the connection being reset
+			handler(data, err)
+			return nil
+		},
+	); err != nil {
+		return err
+	}
+	return nil
+}
+
+// closes a service connection once
+// it is no longer being run
+func closeClient(client *graphql.SubscriptionClient) {
+	if err := client.Close(); err != nil {
+		logger.DebugMessage("SubscriptionClient: Error closing subscription service connection: %s", err.Error())
+	}
+}
+
+// websocket connection whose reads and writes
+// are cancelled when the subscription client
+// is stopped
+type websocketConn struct {
+	ctx     context.Context
+	timeout time.Duration
+
+	*websocket.Conn
+}
+
+func (c *websocketConn) ReadJSON(v interface{}) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeout)
+	defer cancel()
+	return wsjson.Read(ctx, c.Conn, v)
+}
+
+func (c *websocketConn) WriteJSON(v interface{}) error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeout)
+	defer cancel()
+	return wsjson.Write(ctx, c.Conn, v)
+}
+
+func (c *websocketConn) Close() error {
+	return c.Conn.Close(websocket.StatusNormalClosure, "close websocket")
+}
@@ -0,0 +1,122 @@
+package api_test
+
+import (
+	"encoding/json"
+	"time"
+
+	graphql "github.com/hasura/go-graphql-client"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/oauth2"
+
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/appbricks/mycloudspace-client/api"
+
+	mycs_mocks "github.com/appbricks/mycloudspace-client/test/mocks"
+)
+
+var _ = Describe("Subscription Client", func() {
+
+	var (
+		err error
+
+		authContext config.AuthContext
+		testServer  *mycs_mocks.MockSubscriptionServer
+	)
+
+	BeforeEach(func() {
+
+		authContext = config.NewAuthContext()
+		authContext.SetToken(
+			(&oauth2.Token{}).WithExtra(
+				map[string]interface{}{
+					"id_token": "mock authorization token",
+				},
+			),
+		)
+
+		// start test subscription server
+		testServer = mycs_mocks.NewMockSubscriptionServer()
+		testServer.Start()
+	})
+
+	AfterEach(func() {
+		testServer.Stop()
+	})
+
+	It("receives subscription events and resubscribes when the connection is lost", func() {
+
+		var s struct {
+			Test struct {
+				ID   graphql.ID
+				Name graphql.String
+			} `graphql:"test(id: $testID)"`
+		}
+		variables := map[string]interface{}{
+			"testID": graphql.ID("9999"),
+		}
+
+		events := make(chan string, 10)
+		subClient := api.NewSubscriptionClient(testServer.URL(), authContext)
+		_, err = subClient.Subscribe(&s, variables, func(data *json.RawMessage, err error) {
+			if err != nil {
+				events <- err.Error()
+			} else {
+				events <- string(*data)
+			}
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		subClient.Start()
+		defer subClient.Stop()
+
+		sub, err := testServer.WaitForSubscription(5 * time.Second)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sub.Query).To(Equal("subscription ($testID:ID!){test(id: $testID){id,name}}"))
+		Expect(sub.Variables).To(Equal(map[string]interface{}{"testID": "9999"}))
+		Expect(testServer.ConnectionParams()).To(Equal(map[string]interface{}{"Authorization": "mock authorization token"}))
+		Eventually(subClient.IsConnected, 5*time.Second).Should(BeTrue())
+
+		err = testServer.Publish(sub, `{"test":{"id":"9999","name":"test9999"}}`)
+		Expect(err).ToNot(HaveOccurred())
+		Eventually(events, 5*time.Second).Should(Receive(Equal(`{"test":{"id":"9999","name":"test9999"}}`)))
+
+		err = testServer.PublishError(sub, "a test error occurred")
+		Expect(err).ToNot(HaveOccurred())
+		Eventually(events, 5*time.Second).Should(Receive(Equal("Message: a test error occurred, Locations: []")))
+
+		// drop connection and wait for client to resubscribe
+		// with the token that is current when it reconnects
+		authContext.SetToken(
+			(&oauth2.Token{}).WithExtra(
+				map[string]interface{}{
+					"id_token": "refreshed authorization token",
+				},
+			),
+		)
+		testServer.DropConnections()
+		sub, err = testServer.WaitForSubscription(10 * time.Second)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sub.Query).To(Equal("subscription ($testID:ID!){test(id: $testID){id,name}}"))
+		Expect(testServer.ConnectCount()).To(Equal(2))
+		Expect(testServer.ConnectionParams()).To(Equal(map[string]interface{}{"Authorization": "refreshed authorization token"}))
+
+		err = testServer.Publish(sub, `{"test":{"id":"9999","name":"test9999 updated"}}`)
+		Expect(err).ToNot(HaveOccurred())
+		Eventually(events, 5*time.Second).Should(Receive(Equal(`{"test":{"id":"9999","name":"test9999 updated"}}`)))
+	})
+
+	It("stops while it is connecting to the subscription service", func() {
+
+		subClient := api.NewSubscriptionClient(testServer.URL(), authContext)
+		subClient.Start()
+
+		stopped := make(chan bool)
+		go func() {
+			subClient.Stop()
+			close(stopped)
+		}()
+		Eventually(stopped, 5*time.Second).Should(BeClosed())
+		Expect(subClient.IsConnected()).To(BeFalse())
+	})
+})
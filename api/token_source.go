@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/mevansam/goutils/logger"
+)
+
+// tokens are refreshed if they
+// expire within this window
+const tokenRefreshWindow = 5 * time.Minute
+
+// oauth2 token source that always returns the
+// current token in an auth context. if the
+// source has been created with an oauth config
+// then tokens close to expiry are refreshed and
+// the refreshed token is saved to the context.
+type AuthTokenSource struct {
+	ctx context.Context
+
+	oauthConfig *oauth2.Config
+	authContext config.AuthContext
+
+	mx sync.Mutex
+}
+
+// returns a token source that refreshes the tokens
+// in the given auth context via the service's
+// token endpoint
+func NewAuthTokenSource(
+	ctx context.Context,
+	serviceConfig ServiceConfig,
+	authContext config.AuthContext,
+) *AuthTokenSource {
+
+	var (
+		oauthConfig *oauth2.Config
+	)
+
+	if len(serviceConfig.TokenURL) > 0 {
+		oauthConfig = serviceConfig.OAuthConfig()
+	}
+	return &AuthTokenSource{
+		ctx: ctx,
+
+		oauthConfig: oauthConfig,
+		authContext: authContext,
+	}
+}
+
+// returns a token source that reads the tokens in
+// the given auth context but does not refresh them
+func newAuthContextTokenSource(authContext config.AuthContext) *AuthTokenSource {
+	return &AuthTokenSource{
+		ctx:         context.Background(),
+		authContext: authContext,
+	}
+}
+
+// oauth2.TokenSource implementation
+func (ts *AuthTokenSource) Token() (*oauth2.Token, error) {
+
+	ts.mx.Lock()
+	defer ts.mx.Unlock()
+
+	token := ts.authContext.GetToken()
+	if token == nil {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	if ts.oauthConfig != nil && len(token.RefreshToken) > 0 &&
+		!token.Expiry.IsZero() && token.Expiry.Before(time.Now().Add(tokenRefreshWindow)) {
+
+		logger.DebugMessage(
+			"AuthTokenSource.Token(): Refreshing token that expires at %s.",
+			token.Expiry.Format(time.RFC3339),
+		)
+		return ts.refresh(token)
+	}
+	return token, nil
+}
+
+// refreshes the given token if it is still the
+// current token in the auth context. this is
+// called when the service has rejected the token.
+func (ts *AuthTokenSource) Refresh(staleToken *oauth2.Token) (*oauth2.Token, error) {
+
+	ts.mx.Lock()
+	defer ts.mx.Unlock()
+
+	token := ts.authContext.GetToken()
+	if token == nil {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	if IDToken(token) != IDToken(staleToken) {
+		// token has already been refreshed
+		return token, nil
+	}
+	if ts.oauthConfig == nil || len(token.RefreshToken) == 0 {
+		return nil, fmt.Errorf("token cannot be refreshed")
+	}
+	return ts.refresh(token)
+}
+
+func (ts *AuthTokenSource) refresh(token *oauth2.Token) (*oauth2.Token, error) {
+
+	var (
+		err error
+
+		newToken *oauth2.Token
+	)
+
+	// a token with only the refresh token
+	// set is always refreshed by the source
+	if newToken, err = ts.oauthConfig.TokenSource(
+		ts.ctx,
+		&oauth2.Token{RefreshToken: token.RefreshToken},
+	).Token(); err != nil {
+		logger.ErrorMessage("AuthTokenSource.refresh(): Failed to refresh token: %s", err.Error())
+		return nil, err
+	}
+	if len(IDToken(newToken)) == 0 {
+		return nil, fmt.Errorf("refreshed token does not have an id token")
+	}
+	ts.authContext.SetToken(newToken)
+
+	logger.DebugMessage(
+		"AuthTokenSource.refresh(): Token has been refreshed and will expire at %s.",
+		newToken.Expiry.Format(time.RFC3339),
+	)
+	return newToken, nil
+}
+
+// returns the Cognito id token
+// in the given oauth token
+func IDToken(token *oauth2.Token) string {
+	if token == nil {
+		return ""
+	}
+	idToken, _ := token.Extra("id_token").(string)
+	return idToken
+}
@@ -0,0 +1,149 @@
+package audit
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/google/uuid"
+
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/appbricks/mycloudspace-common/events"
+	"github.com/mevansam/goutils/logger"
+)
+
+// types of the security relevant
+// events recorded by the client
+const (
+	EventLogin               = "io.appbricks.mycs.audit.login"
+	EventLogout              = "io.appbricks.mycs.audit.logout"
+	EventDeviceAccessRequest = "io.appbricks.mycs.audit.device.access-request"
+	EventDeviceOwnerReset    = "io.appbricks.mycs.audit.device.owner-reset"
+	EventOwnerKeyLoad        = "io.appbricks.mycs.audit.owner-key.load"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// data of an audit event
+type EventData struct {
+	UserID   string `json:"userID,omitempty"`
+	UserName string `json:"userName,omitempty"`
+	DeviceID string `json:"deviceID,omitempty"`
+
+	Outcome string `json:"outcome"`
+	Error   string `json:"error,omitempty"`
+
+	// event specific details such as
+	// the login flow used
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// posts audit events to a remote event store.
+// this is implemented by mycscloud.EventPublisher.
+type Publisher interface {
+	PostMeasurementEventsWithContext(ctx context.Context, cloudEvents []*cloudevents.Event) ([]events.CloudEventError, error)
+}
+
+var (
+	publisher Publisher
+	pubMx     sync.Mutex
+)
+
+// sets the publisher to which audit events are
+// posted in addition to being recorded in the
+// local journal. events are not posted if the
+// publisher is nil.
+func SetPublisher(p Publisher) {
+	pubMx.Lock()
+	defer pubMx.Unlock()
+	publisher = p
+}
+
+// records an event of the given type in the journal
+// of the given config. the subject of the event is
+// the name of the user the event is for and the
+// outcome of the event is determined by the given
+// error. recording failures are logged and not
+// returned so that auditing never fails the
+// operation being audited.
+func Record(
+	appConfig config.Config,
+	eventType, userName string,
+	err error,
+	details map[string]string,
+) {
+
+	event, eventErr := NewEvent(appConfig, eventType, userName, err, details)
+	if eventErr != nil {
+		logger.ErrorMessage("audit.Record(): Failed to create %s event: %s", eventType, eventErr.Error())
+		return
+	}
+	if _, eventErr = NewJournalForConfig(appConfig).Append(event); eventErr != nil {
+		logger.ErrorMessage("audit.Record(): Failed to record %s event: %s", eventType, eventErr.Error())
+	}
+
+	pubMx.Lock()
+	p := publisher
+	pubMx.Unlock()
+
+	if p != nil {
+		go func() {
+			if _, postErr := p.PostMeasurementEventsWithContext(context.Background(), []*cloudevents.Event{event}); postErr != nil {
+				logger.ErrorMessage("audit.Record(): Failed to post %s event: %s", eventType, postErr.Error())
+			}
+		}()
+	}
+}
+
+// returns the events recorded in the journal
+// of the given config that match the filter
+func Query(appConfig config.Config, filter Filter) ([]*cloudevents.Event, error) {
+	return NewJournalForConfig(appConfig).Query(filter)
+}
+
+// returns a new audit event of the given
+// type for the device of the given config
+func NewEvent(
+	appConfig config.Config,
+	eventType, userName string,
+	err error,
+	details map[string]string,
+) (*cloudevents.Event, error) {
+
+	data := EventData{
+		UserName: userName,
+		Outcome:  OutcomeSuccess,
+		Details:  details,
+	}
+	if err != nil {
+		data.Outcome = OutcomeFailure
+		data.Error = err.Error()
+	}
+
+	source := strings.Builder{}
+	source.WriteString("urn:mycs:device:")
+	if deviceContext := appConfig.DeviceContext(); deviceContext != nil {
+		data.DeviceID, _ = deviceContext.GetDeviceID()
+		source.WriteString(data.DeviceID)
+
+		if userName == deviceContext.GetLoggedInUserName() {
+			data.UserID = deviceContext.GetLoggedInUserID()
+		}
+	}
+
+	event := cloudevents.NewEvent()
+	event.SetID(uuid.New().String())
+	event.SetSource(source.String())
+	event.SetType(eventType)
+	event.SetSubject(userName)
+	event.SetTime(time.Now())
+	if err = event.SetData(cloudevents.ApplicationJSON, data); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
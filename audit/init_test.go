@@ -0,0 +1,20 @@
+package audit_test
+
+import (
+	"testing"
+
+	"github.com/mevansam/goutils/logger"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestAudit(t *testing.T) {
+	logger.Initialize()
+
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "audit")
+}
+
+var _ = AfterSuite(func() {
+})
@@ -0,0 +1,365 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/mevansam/goutils/logger"
+)
+
+var ErrJournalTampered = errors.New("audit journal has been tampered with")
+
+// an event appended to the journal. each entry
+// includes the hash of the previous entry so
+// that changing or removing an entry breaks
+// the chain of hashes that follows it.
+type Entry struct {
+	Sequence int64           `json:"seq"`
+	PrevHash string          `json:"prev"`
+	Hash     string          `json:"hash"`
+	Event    json.RawMessage `json:"event"`
+}
+
+// selects the events returned by a journal query.
+// zero values match all events.
+type Filter struct {
+	// event types to match
+	Types []string
+	// subject of the event which
+	// is the user's name
+	Subject string
+
+	Since,
+	Until time.Time
+
+	// maximum number of the latest
+	// matching events to return
+	Limit int
+}
+
+// append only journal of audit events saved as one
+// JSON entry per line. the journal is only tamper
+// evident and not tamper proof as it is saved in
+// the clear alongside the config.
+type Journal struct {
+	path string
+
+	// last entry appended and the size of the
+	// journal file once it was appended. the
+	// journal is only read again if its size
+	// has changed since the last append.
+	last *Entry
+	size int64
+	// whether the journal ends with an entry
+	// which was only partially written
+	partial bool
+
+	mx sync.Mutex
+}
+
+// returns the journal for the given
+// config which is saved alongside the
+// config file
+func NewJournalForConfig(appConfig config.Config) *Journal {
+	return NewJournal(filepath.Join(filepath.Dir(appConfig.GetConfigFile()), "audit.log"))
+}
+
+var (
+	journals   = make(map[string]*Journal)
+	journalsMx sync.Mutex
+)
+
+// returns the journal saved at the given path. the
+// same journal instance is returned for a path so
+// that appends to it are serialized.
+func NewJournal(path string) *Journal {
+	journalsMx.Lock()
+	defer journalsMx.Unlock()
+
+	if j, exists := journals[path]; exists {
+		return j
+	}
+	j := &Journal{
+		path: path,
+		size: -1,
+	}
+	journals[path] = j
+	return j
+}
+
+// appends the given event to the journal
+func (j *Journal) Append(event *cloudevents.Event) (*Entry, error) {
+	j.mx.Lock()
+	defer j.mx.Unlock()
+
+	var (
+		err error
+
+		last      *Entry
+		file      *os.File
+		eventJSON []byte
+		line      []byte
+	)
+
+	// the chain is continued from the last readable
+	// entry even if the journal has been tampered
+	// with so that events continue to be recorded
+	if last, err = j.lastEntry(); err != nil {
+		return nil, err
+	}
+	if eventJSON, err = json.Marshal(event); err != nil {
+		return nil, err
+	}
+	// the event is marshalled as it will be saved
+	// so that it hashes to the same value when the
+	// entry is read back
+	if eventJSON, err = json.Marshal(json.RawMessage(eventJSON)); err != nil {
+		return nil, err
+	}
+
+	entry := &Entry{
+		Sequence: 1,
+		Event:    eventJSON,
+	}
+	if last != nil {
+		entry.Sequence = last.Sequence + 1
+		entry.PrevHash = last.Hash
+	}
+	entry.Hash = entry.hash()
+
+	if line, err = json.Marshal(entry); err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(filepath.Dir(j.path), 0700); err != nil {
+		return nil, err
+	}
+	if file, err = os.OpenFile(j.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600); err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	line = append(line, '\n')
+	if j.partial {
+		// start a new line after the partial entry
+		line = append([]byte{'\n'}, line...)
+	}
+	if _, err = file.Write(line); err != nil {
+		// the journal is read again on
+		// the next append
+		j.last, j.size = nil, -1
+		return nil, err
+	}
+	j.last = entry
+	j.size += int64(len(line))
+	j.partial = false
+	return entry, nil
+}
+
+// returns the events in the journal that match the
+// given filter in the order they were recorded. an
+// error is returned if the journal's chain of
+// hashes is broken.
+func (j *Journal) Query(filter Filter) ([]*cloudevents.Event, error) {
+	j.mx.Lock()
+	defer j.mx.Unlock()
+
+	types := make(map[string]bool)
+	for _, t := range filter.Types {
+		types[t] = true
+	}
+
+	events := []*cloudevents.Event{}
+	if _, err := j.verify(func(entry *Entry) error {
+		event := cloudevents.NewEvent()
+		if err := json.Unmarshal(entry.Event, &event); err != nil {
+			return err
+		}
+		if len(types) > 0 && !types[event.Type()] {
+			return nil
+		}
+		if len(filter.Subject) > 0 && event.Subject() != filter.Subject {
+			return nil
+		}
+		if !filter.Since.IsZero() && event.Time().Before(filter.Since) {
+			return nil
+		}
+		if !filter.Until.IsZero() && event.Time().After(filter.Until) {
+			return nil
+		}
+		events = append(events, &event)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if filter.Limit > 0 && len(events) > filter.Limit {
+		events = events[len(events)-filter.Limit:]
+	}
+	return events, nil
+}
+
+// verifies the journal's chain of hashes
+// and returns the number of entries in
+// the journal
+func (j *Journal) Verify() (int64, error) {
+	j.mx.Lock()
+	defer j.mx.Unlock()
+
+	last, err := j.verify(func(*Entry) error { return nil })
+	if err != nil || last == nil {
+		return 0, err
+	}
+	return last.Sequence, nil
+}
+
+// reads each entry of the journal verifying
+// its hash and calls the given handler with it.
+// the last entry read is returned.
+func (j *Journal) verify(handleEntry func(entry *Entry) error) (*Entry, error) {
+
+	var (
+		err error
+
+		file *os.File
+		last *Entry
+	)
+
+	if file, err = os.Open(j.path); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		entry := &Entry{}
+		if err = json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			return nil, fmt.Errorf("%w: entry %d cannot be read: %s", ErrJournalTampered, nextSequence(last), err.Error())
+		}
+		if entry.Sequence != nextSequence(last) {
+			return nil, fmt.Errorf("%w: entry %d is out of sequence", ErrJournalTampered, nextSequence(last))
+		}
+		if last != nil && entry.PrevHash != last.Hash {
+			return nil, fmt.Errorf("%w: entry %d does not follow entry %d", ErrJournalTampered, entry.Sequence, last.Sequence)
+		}
+		if entry.Hash != entry.hash() {
+			return nil, fmt.Errorf("%w: entry %d has been modified", ErrJournalTampered, entry.Sequence)
+		}
+		if err = handleEntry(entry); err != nil {
+			return nil, err
+		}
+		last = entry
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return last, nil
+}
+
+// returns the last readable entry of the journal.
+// the journal is read only if it has changed since
+// it was last appended to. an entry which cannot
+// be read such as one that was only partially
+// written when the client crashed is skipped.
+func (j *Journal) lastEntry() (*Entry, error) {
+
+	var (
+		err error
+
+		info os.FileInfo
+		file *os.File
+		line []byte
+	)
+
+	if info, err = os.Stat(j.path); err != nil {
+		if os.IsNotExist(err) {
+			j.last, j.size, j.partial = nil, 0, false
+			return nil, nil
+		}
+		return nil, err
+	}
+	if info.Size() == j.size {
+		return j.last, nil
+	}
+
+	if file, err = os.Open(j.path); err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var (
+		last       *Entry
+		unreadable int
+		size       int64
+		terminated = true
+	)
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err = reader.ReadBytes('\n')
+		size += int64(len(line))
+		if len(line) > 0 {
+			terminated = line[len(line)-1] == '\n'
+		}
+		if data := bytes.TrimSpace(line); len(data) > 0 {
+			entry := &Entry{}
+			if json.Unmarshal(data, entry) == nil {
+				last = entry
+				unreadable = 0
+			} else {
+				unreadable++
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if unreadable > 0 {
+		logger.ErrorMessage(
+			"Journal.lastEntry(): %s: the last %d entries of journal '%s' cannot be read. New entries will follow entry %d.",
+			ErrJournalTampered.Error(), unreadable, j.path, nextSequence(last)-1,
+		)
+	}
+
+	j.last = last
+	j.size = size
+	j.partial = !terminated
+	return last, nil
+}
+
+func (r *Entry) hash() string {
+
+	hash := sha256.New()
+	hash.Write([]byte(fmt.Sprintf("%d|%s|", r.Sequence, r.PrevHash)))
+	hash.Write(r.Event)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func nextSequence(last *Entry) int64 {
+	if last == nil {
+		return 1
+	}
+	return last.Sequence + 1
+}
@@ -0,0 +1,193 @@
+package audit_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/appbricks/mycloudspace-client/audit"
+	"github.com/appbricks/mycloudspace-common/events"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// config whose audit journal is
+// saved to a temporary directory
+type auditConfig struct {
+	config.Config
+
+	configFile    string
+	deviceContext config.DeviceContext
+}
+
+func (c *auditConfig) GetConfigFile() string {
+	return c.configFile
+}
+
+func (c *auditConfig) DeviceContext() config.DeviceContext {
+	return c.deviceContext
+}
+
+type mockPublisher struct {
+	events chan *cloudevents.Event
+}
+
+func (p *mockPublisher) PostMeasurementEventsWithContext(ctx context.Context, cloudEvents []*cloudevents.Event) ([]events.CloudEventError, error) {
+	for _, e := range cloudEvents {
+		p.events <- e
+	}
+	return nil, nil
+}
+
+var _ = Describe("Audit Journal", func() {
+
+	var (
+		err error
+
+		appConfig *auditConfig
+		configDir string
+	)
+
+	BeforeEach(func() {
+		configDir, err = os.MkdirTemp("", "mycs-audit-")
+		Expect(err).ToNot(HaveOccurred())
+
+		deviceContext := config.NewDeviceContext()
+		device, err := deviceContext.NewDevice()
+		Expect(err).ToNot(HaveOccurred())
+		device.DeviceID = "676741a9-0608-4633-b293-05e49bea6504"
+		deviceContext.SetLoggedInUser("02891829-5b35-44c9-b06c-825441eb7a51", "ken")
+
+		appConfig = &auditConfig{
+			configFile:    filepath.Join(configDir, "config.yml"),
+			deviceContext: deviceContext,
+		}
+	})
+
+	AfterEach(func() {
+		audit.SetPublisher(nil)
+		os.RemoveAll(configDir)
+	})
+
+	recordEvents := func() {
+		audit.Record(appConfig, audit.EventLogin, "ken", nil, map[string]string{"method": "interactive"})
+		audit.Record(appConfig, audit.EventDeviceAccessRequest, "bob", errors.New("access denied"), nil)
+		audit.Record(appConfig, audit.EventLogout, "ken", nil, nil)
+	}
+
+	It("records events and queries them", func() {
+		recordEvents()
+
+		allEvents, err := audit.Query(appConfig, audit.Filter{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(allEvents)).To(Equal(3))
+
+		event := allEvents[0]
+		Expect(event.Type()).To(Equal(audit.EventLogin))
+		Expect(event.Subject()).To(Equal("ken"))
+		Expect(event.Source()).To(Equal("urn:mycs:device:676741a9-0608-4633-b293-05e49bea6504"))
+
+		data := audit.EventData{}
+		err = event.DataAs(&data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(Equal(audit.EventData{
+			UserID:   "02891829-5b35-44c9-b06c-825441eb7a51",
+			UserName: "ken",
+			DeviceID: "676741a9-0608-4633-b293-05e49bea6504",
+			Outcome:  audit.OutcomeSuccess,
+			Details:  map[string]string{"method": "interactive"},
+		}))
+
+		err = allEvents[1].DataAs(&data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data.UserID).To(BeEmpty())
+		Expect(data.Outcome).To(Equal(audit.OutcomeFailure))
+		Expect(data.Error).To(Equal("access denied"))
+
+		filteredEvents, err := audit.Query(appConfig, audit.Filter{
+			Types:   []string{audit.EventLogin, audit.EventLogout},
+			Subject: "ken",
+			Limit:   1,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(filteredEvents)).To(Equal(1))
+		Expect(filteredEvents[0].Type()).To(Equal(audit.EventLogout))
+
+		filteredEvents, err = audit.Query(appConfig, audit.Filter{
+			Since: time.Now().Add(time.Minute),
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(filteredEvents).To(BeEmpty())
+
+		numEntries, err := audit.NewJournalForConfig(appConfig).Verify()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(numEntries).To(Equal(int64(3)))
+	})
+
+	It("detects entries that have been modified or removed", func() {
+		recordEvents()
+
+		journalFile := filepath.Join(configDir, "audit.log")
+		journal, err := os.ReadFile(journalFile)
+		Expect(err).ToNot(HaveOccurred())
+
+		err = os.WriteFile(journalFile, []byte(strings.Replace(string(journal), `"subject":"bob"`, `"subject":"eve"`, 1)), 0600)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = audit.Query(appConfig, audit.Filter{})
+		Expect(errors.Is(err, audit.ErrJournalTampered)).To(BeTrue())
+		Expect(err.Error()).To(HaveSuffix("entry 2 has been modified"))
+
+		entries := strings.Split(string(journal), "\n")
+		err = os.WriteFile(journalFile, []byte(entries[0]+"\n"+entries[2]+"\n"), 0600)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = audit.NewJournalForConfig(appConfig).Verify()
+		Expect(errors.Is(err, audit.ErrJournalTampered)).To(BeTrue())
+		Expect(err.Error()).To(HaveSuffix("entry 2 is out of sequence"))
+	})
+
+	It("continues recording events after an entry was partially written", func() {
+		recordEvents()
+
+		// an entry torn by a crash while it was written
+		journalFile := filepath.Join(configDir, "audit.log")
+		file, err := os.OpenFile(journalFile, os.O_APPEND|os.O_WRONLY, 0600)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = file.WriteString(`{"seq":4,"prev":"`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+
+		audit.Record(appConfig, audit.EventLogin, "ken", nil, nil)
+		audit.Record(appConfig, audit.EventLogout, "ken", nil, nil)
+
+		journal, err := os.ReadFile(journalFile)
+		Expect(err).ToNot(HaveOccurred())
+		entries := strings.Split(strings.TrimSpace(string(journal)), "\n")
+		Expect(len(entries)).To(Equal(6))
+		Expect(entries[3]).To(Equal(`{"seq":4,"prev":"`))
+		Expect(entries[4]).To(HavePrefix(`{"seq":4,`))
+		Expect(entries[5]).To(HavePrefix(`{"seq":5,`))
+
+		// the break in the journal remains evident
+		_, err = audit.NewJournalForConfig(appConfig).Verify()
+		Expect(errors.Is(err, audit.ErrJournalTampered)).To(BeTrue())
+		Expect(err.Error()).To(HaveSuffix("entry 4 cannot be read: unexpected end of JSON input"))
+	})
+
+	It("posts events to the publisher", func() {
+		publisher := &mockPublisher{events: make(chan *cloudevents.Event, 1)}
+		audit.SetPublisher(publisher)
+
+		audit.Record(appConfig, audit.EventOwnerKeyLoad, "ken", nil, nil)
+		Eventually(publisher.events).Should(Receive(WithTransform(
+			func(e *cloudevents.Event) string { return e.Type() },
+			Equal(audit.EventOwnerKeyLoad),
+		)))
+	})
+})
@@ -1,60 +1,11 @@
 package auth
 
-const authSuccessHTML = `<!DOCTYPE html>
-<html lang="en">
-  <head>
-    <link
-      href="https://d3oia8etllorh5.cloudfront.net/20201215211355/css/bootstrap.min.css"
-      rel="stylesheet"
-      media="screen"
-    />
-    <link
-      href="https://d3oia8etllorh5.cloudfront.net/20201215211355/css/cognito-login.css"
-      rel="stylesheet"
-      media="screen"
-    />
-    <link
-      href="https://d3oia8etllorh5.cloudfront.net/us-east-1_eLvjGzCSB/4edm63vj2lg5tdoogiq4c9fa5t/20210126054122/assets/CSS/custom-css.css"
-      rel="stylesheet"
-      media="screen"
-    />
-    <title>Authenticated</title>    
-  </head>
-  <body">
-    <div class="container">
-      <div class="modal-dialog">        
-        <div
-          class="modal-content background-customizable modal-content-mobile visible-md visible-lg"
-        >
-          <div>
-            <div>
-              <div class="banner-customizable">
-                <center>
-                  <img
-                    alt="logo"
-                    class="logo-customizable"
-                    src="/logo.png"
-                  />
-                </center>
-              </div>
-            </div>
-          </div>
-          <div class="modal-body">
-            <div>
-              <div>
-                <div>
-                    <p>You are now signed in to your MyCS Cloud account. Please close 
-                    this window and return to the MyCS client that required authentication
-                    and wait for it to update with this login session.</p>
-                </div>
-              </div>
-            </div>
-          </div>
-        </div>
-      </div>
-    </div>
-  </body>
-</html>
-`
+import "embed"
+
+// templates of the pages shown in the browser
+// once an authorization code login completes
+//
+//go:embed templates/callback/*.html
+var callbackTemplates embed.FS
 
 const appBricksLogoImg = `iVBORw0KGgoAAAANSUhEUgAAAZAAAABoCAYAAADfCaYMAAAABmJLR0QA/wD/AP+gvaeTAAAACXBIWXMAAAsTAAALEwEAmpwYAAAAB3RJTUUH4wsCFBIOBb5sbgAAH75JREFUeNrtnX90G9WZ978jy4mTElBiOZiUNCbRyHKAIuommhE/otCWvl1WxO0WStndxoGe5e0vCGzbgOU0A7HstN1tTF92t0vbjWlPYVvegkF9Tw+FbZyGaK7IZjGExrJGFKekNMFycAppfljSff+Q7DiOfoykkWynz+ecnBPPjGbu3Hvnee5z7/M8FyAIgiAIgiAIgiAIgiAIgiAIgiAIgiAIgiAIgiAIgiAIgiAIgiAIgiAIgiAIgiAIgiAIgiAIgiAIgiAIgiAIgiCI8x1hJhaq1uHvdIvs8wDqmCYxmExfHD7wwMvUXARBEKRAsmJt9L8g29lHphxOvKStuvpI+Bv7qckIgiBmBqaZVJi6ld+qz6A8AKAqiapvU3MRBEHMHMwzqjSJhDPbKQ7hhkoW5cLLlUXVSfMaDvzx6EA7o65CEAQxgxWIgORb2U/yiq2BWJv835KXs3+csNBs3iO7oy7X6ED7QeoyBEEQKWbUFNbbg75XVU3OqESEJP+nSpShrqnjetnGvjalbi6+zhY6APysiroMQRDEDLRAACAWbns/g3+/JLIr0ofGVE3uiA22Pan7Jrf8rMq6P/otAJcU+nzJpn4qy6n59bboosNRDFO3IQiCmEYFYnV03gXgNllUTWpU6o0N+LaPnxsO+64MouM5txi6MRD4+3nArYnxcwscnbUeUX1E1eQVACLJeNX9R6ObDk2+96Lfhh2yuPc+o8ucNMWXAKRACIIg8iqQusbODVzAQwBMXEh0jgxs/hcjHur1evsAdc3437KNXa/yzvWxcNvEIrrATfGpv1t8xdYVrsvUCACTLKoAsArAZ16s6vS8M9i2J8OjHt29v3lTIWWrmmf+pdsWkjKdG4vPGaIuQxAEkVuBCF6v93FAvW3SsUeC3P+1kbCvoSTLo9G/FmBrph6XRfUqFR2fjYXbn8hqAcSrXsS56zbmKvAnACwDwCefCGrSqdEh32iBRZRV+I/LNjb/7Hu5drzzu/uPUZchCIJIkXER/eLGrQ0Abpt63C2yZYsd/o+X8kBBwN9kPclN63JKdlG9OONxO1t64eXfWWhUpSROVS1RNdcTakR6k2nSa8Go60sj4fY7qLsQBEHksUDipqqswjIJ4QcAlpagQt7NoVyO5JPt2co8Jz42ZlSlpC2N26l7EARBFGiBmHjyePafJF8r5YFzq2qyRpQnTXg8z8/fyHSQadLzscFN71JzEgRBTLMCmfve/Iez/aBqLL6+lAce+u19R1lEunPqcTUqfXvkQFso128DgYBdjUpvTlUqYzVjt1JTEgRBzAAFcujQfSdCmuvTU48HNZfvyOvK26U+dHjQ9x97B+TFE/cddF0aG/B9Xc9vYwNjDUyTfQCgaq6vBQKB5aP9yig1JUEQxAxQIADwdrj957v3Ny8EcAzA8d8MuReNhNs7jXrw4WjbRDzFSKT9D/p/qSQ5kvsBIAn+KjUhQRDEDFMgADA6pIwCSALgx/Y/8A5VF0EQBKFLgRAEQRBENsxUBQRBFILV0blTz3WxcNvamVn+rR5ZfEnPO+wKBAIeanFSIARBGIQsqrqEaiBMdUUKhJix1Db5u902dk8hv2Ga67HhcHsr1Z4xeL1eBcCWct0/qElDAoQhgfPeKsH0zOHwA0NU6wQpkEKFpb3j/VUmLAOEBS6RfQgAqkxVJ/6SG09IouCYHM6FddTtZw9ukTUAaADgAdCtcn9vfN7YBnJdJ0iB5BKOAj8JAFZH9Hey6F0MhGqmXiOtUJ9XE/63OYTHRgZPbwGU5F+O9dHZIttUS8HTD3ZmYYK/dTjs66HuP/uQ7axFjUieupX+tcMHfP1UI8R0MvO8sDyK2erovEsS2acAQBbVDwCoyXL1XNnOlrrtarvXuy9hbfS/sHD5tovKWbxLL/3OPDT/e/W0NxxPthT7Wy6ghbr+rFYiFp7ATotTsVBtEKRA0iy2b7uq7o/Vb8mi+r0iP6yPXHv5npjAqz5rdNnq7NuutTo6/nj11Tv/7F3yi9PWJv+/TVc9WZyKRRJDRaeUkW1sHQmf2a9EzKeqyYokppUZM4VlbfLflOCJp9wim1PqO7ltzGe0YnM17tk1WeHKNva/YfO6A4HAVZWuq+qT1SVbEOZT5lYA3fQJzGIlYmPr9vKuhkovrAcCAYFqn5gxCmTx5Q9d5VrOflEG82ptXVPH9aXeJ5lMfDuLtfbBeltn3eS0LJWAQ1hf8k2SwnpSILOfVWKwNRCGQjVB/MUqENfyvWVZDJREdgWAXeUse6KKrwXws0rVVb2jq2GVGPSUPHq1Myer8jsruRBb7+hqSPDkOsnOzpo+C0Zc/SOD7c9UogwWp2KpOjlnnVtUG84qgyYPJWpOP1Nm76ZdAPqynWSaq0ESQy0AdK/jqVHJCQQMq5vqE9XrJTuzqBHJCQhDAvjQdLgP1zu6GsbA10xtp8ntZTLxV85XR4K6lX4nTwoXAefG3bCINMoFoR8A4jWnX5lOjzy9CuQCr9fLp6uQ/W9ei01fuR43f7wJS+oX4Og7J7Bn70Fseug5NNbpCor9ZzUiHYHAi3oHWQzdCcCR0RowYXcl6yIuJAxbAE9ytALYWOjvUnvaY02OSx4MBAITo+Laps4WIcm3rBKDzkwXu+0hwO5FUJN6qmF6UI+w0hF/cVYUcd1Kv5MnsEVeyjLWn1tUUwL5ZGcfkHgwFt7cV4bm65tcL+cSwF50NawSg/16lYhsY5bAQGn1k1Kq1dvdS1nrpAHG5GsXTrZy9MqCQqe66lb6nckk7hE4WlaJwZxrdOPthRVeqBGpl4P3VGoQMlFeh79VEtkOnZcfY69LnlwKLz04XB/UpFZpBWvIOjA+u22gnvBPy/vrUiBqRP6+bFe/jmlk/667cdGFZxyx6qzvQ8snVuKmjzbijo2X4tibP853i0/HBovfy50lO5jUGMqkKIZjA74/VrIuOBf0BA4eRGqP+Dw3w7piFIgOJgST+UT1Dtmm6lJ6bpG1qhGppc7hv9cAN+M1kwTeFoDpmuZJj/Y8Ku/sjg223Vvpvn44/MAQRG83dAYnMs01VIwFokakq4AA6h1dDfETyadlO3NO82h7u7RCX4R7Bmu6BUBLUPAPAYIyEm57rBJlllboVh5QtdUtsXBm5WF1bPWAm7aPD7DcIiv6/d0i6wkEAg/OGAUSG2zbFBjEJqMeuKRZmZ88Nud9p+ei7prl6m9zXfvfQ9fgD698HYKQGsicOhXH8T+fRk1NNebPq0Z1dRV+/C+34OobjmDpBb/KdatldSuV+uEDyuFiyjwcaX8xWNX5SbdN/TGAC9SIlBAE/HY47Luq0h9arpHJmWkNVzc4WmUxdFUegd0QFDpbRgbaeo0s57hwMp+c87RsL0woyKnprR0MfpSqRNL11YIiIsVlu7oxKPgtI2Hfhpk81SGJoaFiUobIdmbZPU+xTLvyaPTfI61ghqzFpYMue1R0tsZrTn+yXFM79Y6uhngiuVPv9UyTNsTCvowWrbWpY6NsC2038P0Vq6PTU873n0zF3Hitjo7P1jr8+5uX7Du+qkl9O5/yAIAtX71hQnnsZkMQpe1oXX8bbvvM3+CJp85sBfLINm/+D23FvpKmJEYG2noDgcCCQCAgxAZ95rOUh0cx1zX5fdZGf9Tq6HyttqmjLBZbesopL4Ig9EGALuFbSjxJLuFkdXTu1JszKbNgZDusjq2eUsrBk8J2lJBmxC2y1vRUUEUJRiVLAcJpqNjnVJ80d0+n8vB6vYpkZ4Y7csii6jGfqC5LnIzFqVjiPPm0PGUdL5fyyDYQSgUDG6M8znn/k3OerkQbll2BLHZ03ej1et+WxdDj7tSitm4+vtYGAHhn9AQ+e9dP4Vx6ZhbpiZ52xOOpwPNrVi/D/rdyO1sFNemysrygRzHXHp7zP5KNdch2tkIW1cvdttA3rU3+qNGP0pm65ODwAV+/IAi6FCbnQlliQkpRHmdm2Ew7prsMALbUO7oaUCHqVvqdBaSoOViKlVZKLFHJ7+nwt6KMOcRkO3OaT1TvMPq+5hPVO/Qq3WBUejhn+3C+vWzvL6qedB1PvwK59NLvzLM6Ou6rs3d8piCro7HjBy4x+ByAumIKd8niBQCAN37/Dj60bM8555/bqU38/6IFNflGk3PqVnZdbbhlddj8CbeoXnlOA9rYijpHx5eNek5tU2eLnlFPMCr1AkB6se6gHmvBiLiScuAWWUMlPoJ8JJAwxAoJatJ6q6NzZ65/PIGdeke3wai8EbMQi1OxcI7t5X6ObGcttU2dLcZ9g/7u9HqDDsvD9djIgG9jru85PeWUd5AA4EGmSRtUbfVaAA+qmusVfQMwoewDhLxrINamzp9dbdt5y/jfqqnzCS4kbhwZ2PxCHvP0J0Do9lIKN3duqnjvvncq4/n9A0dw08caUy9izq8LpRXB/8EKr8FVGMo1wvs/gTAeMcT6ANcpSM9YHkxz9ekZZaZTm/SUua8dA9CPVGLAZXp/VIay7VI1lyXf+lAmK63UOeVJiRFLhmnShpGwsWtXleK6pfs2AtClJNWo9AyH0CPwxOiZb0Fo1Ws9pb+bkuupzuFvlXRmvtaT8dptU/VYMcd2v9nsTPW7lKNEIIw+IKAwdPTkqwNZVD3lTqmfU4EsauzcINvUW6YUSgDwi91zt9eP9t87mtny8H8NYLfP1A6saq6XwYWjBo1y3o8sLr5Mk1UjfPQtTsUiL2W6suhOXhBPCqZeIP90SJkjms9xX0x53fAePUJctrF1mdxUixG4Z6YTAthdo1jMp6p7ZFv+epXtzBKMyh4jBJExCnC1km1RdjagRqR7prgJZ22zWOYpoD4VW3v0bAol29i63XNLU/6FeFypmuuVmI7tEtIxP/nqiWPeWJa5I6HbqMFI2RSIScDnshkH1y399WtY6s2STp01zOQOLIuhqwFE92jy546G29SSPgZ8s1G2v5hJz/NTVaf/2ojy6p1iUqPSM5MVVmLuad1CJh1fYviC5l7N7RyeopiGD/j6LU7FA2AIOuIdrI6tnlLiMtSo696pgigtUFpgyxvTMjFiDAxMrwI5owQDZbm/qrlekcVQL9OkIY7k0BkhYR4y6pmp3QB1TdHtyrV+EAtv7mPoeEyPJWI+WeVEjgDOXBTicaVqrlfiNXGPPstayFsHsp1Zgpr0stfr7VE1uW9y0ODwAV9/4AA8mGbMeUygC3OcvhhApvTppeaywrrPbMEdtzdP/L3GfRmuf+bZ3ILqV1/Es8+txQ//VbfHse0aUQ0G0fHCSNh3IyAUFWQYG9w0GDQ9tN5t2/vDSfV5ak/U5fnTQLshVg7n0GU6C/xsATfar4yqp/zP6BlllyO1ScqUz2zVjPYro8FT/h5dG2IJQtECAMDB2EB71vcKRuVut03Nq0CMjPguFklkO4LwbzFxdA8P+h420kpUoy4lFm7vTk15lO89ZfElXUIvGJW785dD6NNjYZegUC3Q6XE1rjz0WjqyjfUDyPtdjrvmyuOBrif8oxCEfllU+2ZCNH5OBcIi0lOSPbV501Rxs1drFg+HlbOFg0cxexfsGyu1UHf+bXMGGZJPxgD/6wY7fvivhT3LLYY+qvLOA7FLlCvRp8Rzjp6a/DcJSVyXFPifkDQ9NRJpC6emjb7xo8AAflTbuG2dYErEY/Otv8LAXWNGNFA6OlWX10cVTBmELO/T01HLkdokPYWW3cLl6Nf3sYUsxU5jpZwKsgsivVZatojvSpMWKN0q/K3xeWNrDfL1786lZA1GlwKZvOaRp7+XLWiukHUyM6paYv3tutuCadKQVGDA4LhVkq5Dz0T2hERKqXCgpxJBlLoVyPAHxW3qfn63LIYWT9G23bFw+zkjS+tw9XVYgFmHbGcOAL0BIOuUU53Dr0o2Jk065FdNnf8QC7d9f/zAyOD9hqcSWCUGW/Waz7EMo30zr+oF9Hm8FJvapFghMHmaJI8SsBQ7Knbb2GguwT/ar4xiaX7HiqAmLZtuC2Sqwlcj0k4ApXoWHtv9ZnP3THq38SmqfNccDj8wpC+RZPnfrdCklmM1Y71pi/8iA/rChFJRub9bEHBvpTaMy+269OStiVjYV69GXH+HlBfNq+qh5vfHwu3/mPFFlrMt5Szsj5/sx+KVXdhw98/Lcfubahu7bsh0YnFT5xck8SzlkR6hqP++pFmZX853DmqSLhNdFkO92T4y6HDnTUt0Q7e7jdckDLFm3LbpC3abMvIvlV3pEXPWf8Go9HD6W9OlRAxwc+6n7XENoaB4odF+ZTQYlVuNLoRsZxZJZDtqHf4dlXhpc3p0/Ygksi9NElqvLYwv+HA0evcpQOCxQfwkMIif6NDma8pZ2FOn45BsKi668IPlERL24H/Bnmk0mnWdXTh1vPpWlMkFVm/qEgBgEekdq0POWP9qhL8s21le11mjU5uQYDqHPMkUU98XE/w90gr2si6rMRWMV3T/KzaXVrGkXajPy8aNg+8AsFbv9SMDbb2M+zdIIjPEEpnyLbdC9B7M399KtECsjf6HJiuP9MOvaGp6/pczrYE+/7cfBgB85fPyjClTWUP5k1z3dJJkZ92yqPZl/Kcz+AkwNrVJqalIJoROVPqLUkTpdahdOgVFQyn1LImhoUq+myyGztu2lEXVY23qKGgKeDjs62GvS56UB+X0WkVFWSCynd2Z5Zyn1tH5HwJwuoBPfdZ3gmBk9UdGBjf/evKx2qatX3DbXsq0PM/nvhf7abnKwrmwrtLvb1TQnK5nCVW6gslkG+ufAQvYuyraD6NSv9vG1hhZj7OJupX6HDrSKdWX5bZ6Vu8qU3r+DIM+YYvFqfQU8v2k37NlL+9qSK95eoyazSn3hmNmAJdkm55xi+qMy0T67LPPlvX+Aqo+DuDXZ5uam/9N5R13TfHK4Cwi3zp8qO1EOcqRSrSmVlwwyHZmYZpkSPQ3F0wtyOF+qzMatzTrJSKtA7Kb8anR+0szToAKSf0CZCbEqOiFaa4hSQzlfTeeTOrq+3r24+BC1Scr+f2oEWkHgIKfOdUpwOrY6hFgakgKcKbXAQtWKqU4oOhSIKrmejWTu1pQk04nkfiwKSHEdXd6s2mPbGMLz0fzNBZudzJTxy08YfIKAv9TMsm7RiJtfyifItObuqQcloEx6UOEJNZbnIqSbTQW1KT1evY+CEbl/mI/AtnOnKqQPRBRgNCq80Psr9RaQXpU7TwfvyO92RHS7ZLTakhNzwTz90MdLsF5ByKpIMtRPUJctrOWoCn7WmK9o6shDp7TahJM/NjwgTPZBsYt8LqVfifn3CPbQhuhIyWQ28ac5bTezYIJX1Y1+TfpFCVnHiyyrkAgsL+wUbN/M1B67qe3h9/D4roLCu+ciaQBwpMfym5qtj8J4MlzRrGN31zATckvApzzsdOPH40qh0opQyGpS8oyijIotYlsZxZVk5+2OJVz9iaodfh36PVsquZCSd5cHKYddSv9n5w6JZIW1Lq83PTGrORUQpq0Ppujw9mj6sKyCKtR1+hMc8PNht64G0kMrWcmf3euaaz0orWOaRZzSf14PEiQvS416HVuAOfbLU6lL9PgKY54Q740LGpEGgWwMMt0V39gAN1er3cIBeSVK4sCGT7Q/uKiDyofUKNSWLax9wEAG5T+ajjiK3gRfWT+wUeNUCCfv3P60mhVx4X/LGi02LT1Tsn24qM4s56+DU3eh0rxfpgJ2XGNSm0ii6onqEkv1zr8fW6RDakR2cIF3lKAW+zBUhWZW2QNakTaaW3098l21p/+QFsKGeVnDtIsvBwoR/4izmfNvuDpuJtdekbyPIGddY1+ZWze2GOTBXFq2rFqi550/dniowpVHunn9we5/2E92RPcImsIRiUFGeKq9Cg02c4sqqljY84sCprEdVjwfeVsTzMAHH1VOQTggjOmzpnRjKWpY1kVF37jFtkHACAYkXoXJhbclnLxncK+R8fYcf//lUT26dlqYR+ec/qY3ouXNCvzm5e89INzrG9gyxK78shbESVWnDzQl7qkrNNYqa1zjdwprjX1YRTmaKFGXYYEuaWDrVrS/6Anmd8kdpUp0aQRHKzYArFB7NXcravE4Bs626wbQLd6wj/KBYym+lIBa1ZCSVOxB6emJ0nMHVPSfUjP9NE9TPD3TLWi0tsWH0Me113ZFtoe5P6rwHlvYl5812i/Mlrv6GoY44mrBAitso5BWCnTv7oVSDasTf5LZBvTAFRPVErKJTQYjaI5o6k/Z86XANwMA3JiTQMnrUnzc3B06Lq4eUloUbZzY6bqxwHcWGgBCkldglSAYKEfiK5gT7fIGpjJ2NQmRXAsHUk/rejLyzQ9GKVgK8nh8ANDQUHfSH6KMinIqSSdGbeUQdDQ1CmoVA63zo1um6prxz+ewA5kyBbANGmjHgcAt8haxwdfqYwJwYK+H6O3qy5IgSApfHOy8pjEh2odnetNJuHVc34Sj0PVXN+VxdBXZ6ECmS+LIY8RN5JEdmExufj1pi5J01PoVJnX622FznlTo1ObFCEcldg0j/zVqPRMuT/CUqZXShSQ08bIgG8jbN6iPIv0Ck/BJLSWp+xtvbDpm4aT7cwJu1eZ+p0Oh309EL2tZXx/ME3aWO7BRZ4NpfiV2TWj2oPzBFWTuQm45u0CU7unprD2Hc/YeFHX9mIaT69nEgCw13MnCsw8mpZ69Y780lurTosCUaPSM7EB33QLx2Nmbto4M/us/tThM5Xdbza3XLd0X28ZhOg5e9AYTXoarh86IsjViHRPvcPdM3UadPebzS1696QpXHm4HqtEPqycCoQL/HUAziwd+PsCN/0+twHD57tFthHAvJnckbmJf+ztA76CoyDf2qf8+dRx/1NukX1qiuZnw2FfwQGG6b0SGvR+JMV8IAJP9gL61lhkO7PkckfUYUHcK9tCCgpM08A012OxsM+Q0WMwKj0scO4pJLPqZAE9Gn5gxkVOB6PSw4maMWW2p4oZ7VdGA/3wWJs6Nsq2kCFb3DLN9VgVqpThMluu6XWMbuiYEk57I56T5mTSnjRKeqBmRDqTY0yTNlYqmaI5t7DhPqQCYkxTBORQ7H0Hv4R9j+ZNWc5MynelFft+CuD6GdiHX/3vqOvmkQHfwWJvMBIeu0Wtkv9aSPDPAVgiCPjecFj8STH30huTkP5QeouxcGLhzX16FvDGSac2KW4Kh/P+vZrbmUBC0bkF6UGmSYqRnd9tY6O732z2pD9QvR/pg/GaePcME9DHglGpxySgZ2TAN2u8rnT1yYH27r28qzc9fVuMID3GNFcvB++Jhdv7KlXuQCCg6J0SlkXVw+BvzdS3A4GAYnEq3emtfnXdL5PFziH0pAZ7lVsTE/JdcLFj25WrxT2vThqZHa15b/6lhw7dV1AE9sLl2y6qmpvokm3sC9PdYVlUej5Rxe88+prvULEbSZWD1Fav+iJwzTAPFesZVMhzJpTOJLxefbv4qdrqteO/rXd0NcSFRItsC427KDuR2iM9HaQn9BVi6Xi9XgX6HAIeHJ9/tjgVS/XJ6hYuoEW2MQuABlVzjcpiaDSVVFDoG6sZ6y1EcaSCwuIN5ewX8ZpEwRlz9ZarmH6kN/dWsd5h48FygOBJtxNwxv15KD2AGpLE0JCqre4r9DkWp2JJ71KYWziaTKP5rPxC2l/P/cbvmUDSA3CPJIYa0nLXIoshy/j7I+2eyzRpqNA+W1EFUi68Xi8PatLPR8K+glx+F67c9oFrV+w5yCKuzcOD7R06n7UPwIcCgcC0ve/5RDEKpAxlKFiBEARhLCaqAoIgCIIUCEEQBEEKhCAIgiAFQhAEQZACIQiCIAhSIARBEAQpEIIgCIIUCEEQBDErMFMVEIXCXpc26olkj9ckypnMrieOeF/+Dm4emm3pzgmCFMi0woX8KUr0XENkYpr3CAGQTmZ3Jq0DQRDTwPk3heVRzF7vzUe8Xu/3sl2y6IqOpV7vzcm6xq13UhcgCIIgBZKiT4kDeA/AXXWOjp6pp62OTvs1l4V+BwDcZDpMXYAgCIIUyAQDAx9rAnBKEkPr65r8Pxw/Xu9QGmRR3Q/ArEZcfxcb8P0/6gIEQRDFcV6ugUSjd5+KRlFjdXQMyyK7Y/z4KnHfGwCgaq7bY4PtT1DzEwRBzHALpN7WWVdr7/j7XNfU2jsdtU1dHzX0wbx6OYDfTT7EIvIdsTApD4IgiFmhQOJm/MjdGPqRtbEz47aVi6/YukIQ+GtuW/D5lSuVOUY9Nza46V01cq0TwBEAYJq0YXiwbQc1O0EQROlUZAorFm77BERvQrarG1X4340N+r4xfq6uaavouuyliKrJ/KXo6o8dGdh82tBnD256NzCI+tRfFA9AEAQxqxQIAAQCzdVWh/mIbGebmanzNKBCAHfwpGkAAHgy8akjkc0vUJMQBEGQApmCkpyTVJoAvCbZ1K0AIIuhywEgGFn9kZHI5l9TcxAEQcweKurG+1ZEibHXm20Ajo8fU7XVa0cGSXkQBEGQBZKH4QPKe4EDuKDW4X9DgPCVWLitj5qBIAiCFIhuRsK+y6j6CYIgZi+Uzp0gCII4bxWIUHd5ly3XBZderiyqW6lcQM1JEARBCmQCr9d7TFoe1God/q9kOr/Y0SlfvXzfiLRi37vUnARBEKRAJtg3uGY5gNNukX13UaP/nsnnau1dq1yiGgQAVZM91JwEQRCkQCZ4K/LVmBqVGgCcvsbOuk08cT8ASPaQJJh4aFx5xMJtu6g5CYIgKocwWwq6+Ar/xa7L2BsA5k0+zqKuNcMD7b+hpiQIgiAFkk+J/B7AHABgg67rhiPtL1IzEgRBkALJi7XJfwm48EvBZPry8IH7SXkQBEEQBEEQBEEQBEEQBEEQBEEQBEEQhvH/Ae59eaW7TcgOAAAAAElFTkSuQmCC`
\ No newline at end of file
@@ -1,35 +1,39 @@
 package auth
 
 import (
-	"bytes"
 	"context"
-	"encoding/base64"
 	"fmt"
 	"net/http"
 	"os/exec"
 	"runtime"
 	"time"
 
-	"golang.org/x/oauth2"
-
 	"github.com/appbricks/cloud-builder/auth"
 	"github.com/appbricks/cloud-builder/config"
-	"github.com/appbricks/cloud-builder/userspace"
 	"github.com/appbricks/mycloudspace-client/api"
+	"github.com/appbricks/mycloudspace-client/audit"
 	"github.com/appbricks/mycloudspace-client/mycscloud"
 	"github.com/appbricks/mycloudspace-client/ui"
-	"github.com/mevansam/goutils/crypto"
 	"github.com/mevansam/goutils/logger"
 )
 
 var callbackPorts = []int{9080, 19080, 29080, 39080, 49080, 59080}
 
+// titles of the prompts shown when authorizing
+// a device and user which are answered by
+// non-interactive logins
+const (
+	deviceAccessPrompt       = "Device Access"
+	ownerKeyFilePrompt       = "Open Device Owner's Key"
+	ownerKeyPassphrasePrompt = "Key File Passphrase"
+)
+
 type AuthRet struct{
 	Error error
 }
 
 type TokenRet struct {
-	AWSAuth *AWSCognitoJWT
+	AWSAuth *IdentityToken
 	Error   error
 }
 
@@ -49,23 +53,30 @@ func Authenticate(
 
 		isAuthenticated bool
 		authUrl string
+
+		flow *authCodeFlow
 	)
 
 	authRet := make(AsyncAuthRet, 1)
 
+	if serviceConfig, err = ResolveServiceConfig(ctx, serviceConfig); err != nil {
+		authRet <-AuthRet{err}
+		return authRet
+	}
+	// restore the session from the token
+	// store if the context has one
+	tokenStore := tokenStoreFromContext(ctx)
+	if tokenStore != nil && authContext.GetToken() == nil {
+		if token, loadErr := tokenStore.Load(); loadErr == nil {
+			authContext.SetToken(token)
+		} else if loadErr != ErrTokenNotFound {
+			logger.ErrorMessage("Authenticate(): Failed to load saved token: %s", loadErr.Error())
+		}
+	}
 	authn, cancelFunc := auth.NewAuthenticator(
 		ctx,
 		authContext,
-		&oauth2.Config{
-			ClientID:     serviceConfig.CliendID,
-			ClientSecret: serviceConfig.ClientSecret,
-			Scopes:       []string{"openid", "profile"},
-			
-			Endpoint: oauth2.Endpoint{
-				AuthURL:  serviceConfig.AuthURL,
-				TokenURL: serviceConfig.TokenURL,
-			},
-		}, 
+		serviceConfig.OAuthConfig(),
 		callBackHandler(),
 	)
 
@@ -74,13 +85,25 @@ func Authenticate(
 		return authRet
 	}
 	if !isAuthenticated {
+		// the login flows are run by this
+		// client and not the authenticator
+		cancelFunc()
+
+		if useDeviceCodeFlow(ctx, serviceConfig) {
+			return authenticateWithDeviceCode(ctx, serviceConfig, authContext, appUI, loginMessages...)
+		}
+		ctx, cancelFunc = context.WithCancel(ctx)
 		uh := appUI.NewUIMessageWithCancel("Login to My Cloud Space", cancelFunc)
 
 		if len(loginMessages) > 0 {
 			uh.WriteNoticeMessage(loginMessages[0])
 		}
-		if authUrl, err = authn.StartOAuthFlow(callbackPorts, logoRequestHandler); err != nil {
+		if flow, err = newAuthCodeFlow(ctx, serviceConfig, authContext); err == nil {
+			authUrl, err = flow.start(callbackPorts)
+		}
+		if err != nil {
 			logger.ErrorMessage("Authentication failed: %s", err.Error())	
+			cancelFunc()
 			authRet <-AuthRet{err}
 			return authRet
 		}
@@ -107,22 +130,27 @@ func Authenticate(
 		)
 
 		go func() {
+			defer cancelFunc()
+
 			p.Start()
 			defer p.Done()
 
 			for wait := true; wait; {
-				wait, err = authn.WaitForOAuthFlowCompletion(time.Second)
+				wait, err = flow.waitForCompletion(time.Second)
 			}
 			if err != nil {
 				authRet <-AuthRet{err}
 				return
 			}
+			saveToken(tokenStore, authContext)
+
 			// update app config with cloud properties
-			cloudAPI := mycscloud.NewCloudAPI(api.NewGraphQLClient(serviceConfig.ApiURL, "", authContext))
-			authRet <-AuthRet{cloudAPI.UpdateProperties(authContext)}
+			cloudAPI := mycscloud.NewCloudAPI(api.NewGraphQLClientForService(ctx, serviceConfig, authContext))
+			authRet <-AuthRet{cloudAPI.UpdatePropertiesWithContext(ctx, authContext)}
 		}()
 
 	} else {
+		saveToken(tokenStore, authContext)
 		authRet <-AuthRet{nil}
 	}
 	return authRet
@@ -131,32 +159,11 @@ func Authenticate(
 func callBackHandler() func(w http.ResponseWriter, r *http.Request) {
 
 	return func(w http.ResponseWriter, r *http.Request) {
-		if _, err := w.Write([]byte(authSuccessHTML)); err != nil {
-			logger.DebugMessage("ERROR! Unable to return auth success open page.")
-		}
+		renderCallbackPage(w, CallbackSuccess, http.StatusOK, "")
 	}
 }
 
-func logoRequestHandler() (string, func(http.ResponseWriter, *http.Request)) {
-	return "/logo.png",
-		func(w http.ResponseWriter, r *http.Request) {
-
-			var (
-				err  error
-				data []byte
-			)
-
-			if data, err = base64.StdEncoding.DecodeString(appBricksLogoImg); err != nil {
-				logger.DebugMessage("ERROR! Decoding logo image data.")
-				return
-			}
-			if _, err = w.Write([]byte(data)); err != nil {
-				logger.DebugMessage("ERROR! Unable to return logo image.")
-			}
-		}
-}
-
-func openBrowser(url string) (err error) {
+var openBrowser = func(url string) (err error) {
 	switch runtime.GOOS {
 		case "linux":
 			err = exec.Command("xdg-open", url).Run()
@@ -182,15 +189,28 @@ func GetAuthenticatedToken(
 	var (
 		err error
 
-		awsAuth *AWSCognitoJWT
+		awsAuth *IdentityToken
 	)
 	tokenRet := make(AsyncTokenRet, 1)
 	authContext := appConfig.AuthContext()
+
+	tokenStore, storeErr := NewTokenStoreForConfig(appConfig)
+	if storeErr == nil {
+		ctx = WithTokenStore(ctx, tokenStore)
+	} else {
+		logger.DebugMessage("GetAuthenticatedToken(): Token will not be stored separately from the config: %s", storeErr.Error())
+	}
 	if forceLogin {
 		if err = authContext.Reset(); err != nil {
 			tokenRet <-TokenRet{nil, err}
 			return tokenRet
 		}
+		if tokenStore != nil {
+			if err = tokenStore.Delete(); err != nil {
+				tokenRet <-TokenRet{nil, err}
+				return tokenRet
+			}
+		}
 	}
 
 	authRet := Authenticate(ctx, serviceConfig, authContext, appUI, loginMessages...)
@@ -201,12 +221,12 @@ func GetAuthenticatedToken(
 			tokenRet <-TokenRet{nil, err}
 			return
 		}
-		if awsAuth, err = NewAWSCognitoJWT(serviceConfig, authContext); err != nil {
+		if awsAuth, err = newServiceIdentityToken(serviceConfig, authContext); err != nil {
 			logger.DebugMessage("ERROR! Failed to extract auth token: %s", err.Error())	
 			tokenRet <-TokenRet{nil, err}
 			return
 		}
-		if err = appConfig.SetLoggedInUser(awsAuth.UserID(), awsAuth.Username()); err != nil {
+		if err = setLoggedInUser(appConfig, awsAuth); err != nil {
 			tokenRet <-TokenRet{nil, err}
 			return
 		}
@@ -226,37 +246,44 @@ func ValidateAuthenticatedToken(
 		err error
 
 		isAuthenticated bool
-		awsAuth         *AWSCognitoJWT
+		awsAuth         *IdentityToken
+		userName        string
+
+		tokenStore TokenStore
 	)
 
 	authContext := appConfig.AuthContext()
 	deviceContext := appConfig.DeviceContext()
 
+	if serviceConfig, err = ResolveServiceConfig(context.Background(), serviceConfig); err != nil {
+		return false, err
+	}
+	// restores the session token from the
+	// token store or migrates the token
+	// saved with the config to the store
+	if tokenStore, err = SyncTokenStore(appConfig); err != nil {
+		logger.DebugMessage("ValidateAuthenticatedToken(): Token store is not available: %s", err.Error())
+	}
 	authn, _ := auth.NewAuthenticator(
 		context.Background(),
 		authContext,
-		&oauth2.Config{
-			ClientID:     serviceConfig.CliendID,
-			ClientSecret: serviceConfig.ClientSecret,
-			Scopes:       []string{"openid", "profile"},
-			
-			Endpoint: oauth2.Endpoint{
-				AuthURL:  serviceConfig.AuthURL,
-				TokenURL: serviceConfig.TokenURL,
-			},
-		}, 
+		serviceConfig.OAuthConfig(),
 		callBackHandler(),
 	)
 	if isAuthenticated, err = authn.IsAuthenticated(); err == nil {
-		if awsAuth, err = NewAWSCognitoJWT(serviceConfig, authContext); 
-			err == nil && awsAuth.Username() != deviceContext.GetLoggedInUserName() {
-			
-			if err = appConfig.SetLoggedInUser(awsAuth.UserID(), awsAuth.Username()); err != nil {
-				logger.ErrorMessage("Failed to set logged in user: %s", err.Error())	
-				isAuthenticated = false
+		// save the token as it may
+		// have been refreshed
+		saveToken(tokenStore, authContext)
+
+		if awsAuth, err = newServiceIdentityToken(serviceConfig, authContext); err == nil {
+			if userName, err = awsAuth.Username(); err == nil && userName != deviceContext.GetLoggedInUserName() {
+				if err = setLoggedInUser(appConfig, awsAuth); err != nil {
+					logger.ErrorMessage("Failed to set logged in user: %s", err.Error())	
+					isAuthenticated = false
+				}
 			}
-
-		} else {
+		}
+		if err != nil {
 			logger.ErrorMessage("Failed to extract auth token: %s", err.Error())	
 			isAuthenticated = false
 		}
@@ -264,6 +291,26 @@ func ValidateAuthenticatedToken(
 	return isAuthenticated, err
 }
 
+// sets the user identified by the given
+// token as the config's logged in user
+func setLoggedInUser(appConfig config.Config, awsAuth *IdentityToken) error {
+
+	var (
+		err error
+
+		userID,
+		userName string
+	)
+
+	if userID, err = awsAuth.UserID(); err != nil {
+		return err
+	}
+	if userName, err = awsAuth.Username(); err != nil {
+		return err
+	}
+	return appConfig.SetLoggedInUser(userID, userName)
+}
+
 func Login(
 	serviceConfig api.ServiceConfig,
 	appConfig config.Config,
@@ -292,12 +339,26 @@ func Login(
 					)
 				}	
 			}			
+			audit.Record(
+				appConfig, audit.EventLogin, appConfig.DeviceContext().GetLoggedInUserName(), err, 
+				map[string]string{"method": "interactive"},
+			)
 			handleLoginResult(err)
 		}()
 
 		if err = token.Error; err == nil {
 			err = AuthorizeDeviceAndUser(serviceConfig, appConfig, appUI);
-		}		
+		}
+		if err == nil {
+			// the user can be switched back to
+			// after switching to another user
+			saveProfile(appConfig)
+
+			// keep the session alive until logout
+			if sessionErr := loginSession.Start(context.Background(), serviceConfig, appConfig, appUI); sessionErr != nil {
+				logger.ErrorMessage("Login(): Session will not be refreshed: %s", sessionErr.Error())
+			}
+		}
 	}()
 }
 
@@ -309,28 +370,53 @@ func Logout(
 	var (
 		err error
 
-		awsAuth *AWSCognitoJWT
+		awsAuth *IdentityToken
 	)
 
+	loginSession.Stop()
+
+	loggedInUserName := appConfig.DeviceContext().GetLoggedInUserName()
+	defer func() {
+		if len(loggedInUserName) > 0 {
+			audit.Record(appConfig, audit.EventLogout, loggedInUserName, err, nil)
+		}
+	}()
+
 	authContext := appConfig.AuthContext()
 	if authContext.IsLoggedIn() {
-		if awsAuth, err = NewAWSCognitoJWT(serviceConfig, authContext); err != nil {
-			return err
+		if awsAuth, err = newServiceIdentityToken(serviceConfig, authContext); err != nil {
+			// the token may have expired
+			logger.DebugMessage("Logout(): Unable to read logged in user from token: %s", err.Error())
 		}
 	}
 	if err = authContext.Reset(); err != nil {
 		return err
 	}
+	if tokenStore, storeErr := NewTokenStoreForConfig(appConfig); storeErr == nil {
+		if err = tokenStore.Delete(); err != nil {
+			return err
+		}
+	}
+	if profileStore, storeErr := NewProfileStoreForConfig(appConfig); storeErr == nil {
+		if err = profileStore.Remove(appConfig.DeviceContext().GetLoggedInUserName()); err != nil && err != ErrProfileNotFound {
+			return err
+		}
+		err = nil
+	}
 	appConfig.DeviceContext().SetLoggedInUser("", "")
 	
 	if awsAuth != nil {
-		logger.DebugMessage("User \"%s\" has been logged out.", awsAuth.Username())
+		userName, _ := awsAuth.Username()
+		logger.DebugMessage("User \"%s\" has been logged out.", userName)
 	} else {
 		logger.DebugMessage("Logout complete.")
 	}
 	return nil
 }
 
+// runs a device authorization for the logged in user
+// to completion. an access request that is still
+// pending returns mycscloud.ErrAccessPending.
 func AuthorizeDeviceAndUser(
 	serviceConfig api.ServiceConfig,
 	appConfig config.Config,
@@ -338,174 +424,26 @@ func AuthorizeDeviceAndUser(
 ) error {
 
 	var (
-		err, authErr error
-
-		awsAuth *AWSCognitoJWT
-
-		user *userspace.User
-
-		userID, 
-		userName,
-		ownerUserID string
-		ownerConfig []byte
-
-		isOwnerSet bool
-
-		keyFileName, keyFilePassphrase *string
+		err error
 
-		ownerKey *crypto.RSAKey
+		authorization *DeviceAuthorization
+		outcome       AuthorizationOutcome
 	)
 
-	defer func() {
-		if authErr != nil {
-			appUI.ShowErrorMessage(fmt.Sprintf("Device authorization failed: %s", authErr.Error()))
-		}
-	}()
-
-	authContext := appConfig.AuthContext()
-	gqlClient := api.NewGraphQLClient(serviceConfig.ApiURL, "", authContext)
-
-	deviceAPI := mycscloud.NewDeviceAPI(gqlClient)
-	deviceContext := appConfig.DeviceContext()
-
-	// ensure that the device has an owner
-	if ownerUserID, isOwnerSet = deviceContext.GetOwnerUserID(); !isOwnerSet {
-		err = fmt.Errorf("no device owner configured")
+	if authorization, err = NewDeviceAuthorization(serviceConfig, appConfig, appUI); err != nil {
 		return err
 	}
-
-	// validate and parse JWT token
-	if awsAuth, err = NewAWSCognitoJWT(serviceConfig, authContext); err != nil {
+	if outcome, err = authorization.Run(); err != nil {
 		return err
 	}
-	userID = awsAuth.UserID()
-	userName = awsAuth.Username()
-
-	// authenticate device and user
-	if authErr = deviceAPI.UpdateDeviceContext(deviceContext); authErr != nil {
-		err = authErr
-		
-		authErrStr := authErr.Error()
-		if authErrStr == "unauthorized(pending)" {
-			appUI.ShowNoticeMessage(
-				"Device Access",
-				fmt.Sprintf("User \"%s\" is not authorized to use this device. A request to grant access to this device is still pending.", userName),
-			)
-			authErr = nil
-
-		} else if authErrStr == "unauthorized" {
-			requestAccess := make(chan bool)
-			defer close(requestAccess)
-
-			uh := appUI.NewUIMessage("Device Access")
-			uh.WriteNoticeMessage(
-				fmt.Sprintf(
-					"User \"%s\" is not authorized to use this device.\n\n" +
-					"Do you wish to request access to this device", userName,
-				),
-			)
-			uh.ShowMessageWithYesNoInput(func(yes bool) {
-				requestAccess <-yes
-			})
-			if <-requestAccess {
-				if user, _ = deviceContext.GetGuestUser(userName); user == nil {
-					if user, err = deviceContext.NewGuestUser(userID, userName); err != nil {
-						err = fmt.Errorf("failed to add new guest user to device: %s", err.Error())
-						return err
-					}
-				} else {
-					user.Active = false
-				}
-				if _, _, err = deviceAPI.AddDeviceUser(deviceContext.GetDevice().DeviceID, ""); err != nil {
-					err = fmt.Errorf("failed to add new guest user to device: %s", err.Error())
-					return err
-				}
-				appUI.ShowNoteMessage(
-					"Device Access",
-					fmt.Sprintf("A request to grant user \"%s\" access to this device has been submitted.", user.Name),
-				)
-				authErr = nil
-
-			} else {
-				err = fmt.Errorf("access request declined")
-			}
-		}
 
-		if resetErr := authContext.Reset(); resetErr != nil {
-			logger.ErrorMessage(
-				"Failed to reset auth context as device authorization failed: %s", 
-				resetErr.Error(),
-			)
+	switch outcome {
+	case PendingApproval:
+		if !authorization.accessRequested {
+			return mycscloud.ErrAccessPending
 		}
-		return err
+	case NeedsOwnerKey:
+		return fmt.Errorf("the device owner's key is required to authorize the device")
 	}
-
-	// if logged in user is the owner ensure 
-	// owner is initialized and config is latest
-	if userID == ownerUserID {
-		owner := deviceContext.GetOwner()
-
-		if len(owner.RSAPrivateKey) == 0 {
-			input := make(chan *string, 1)
-			defer close(input)
-			
-			uh := appUI.NewUIMessage("Open Device Owner's Key")			
-			uh.ShowMessageWithFileInput(func(keyFileName *string) {
-				input <-keyFileName
-			})
-			if keyFileName = <-input; keyFileName == nil {
-				err = fmt.Errorf("no key file provided")
-				return err
-			}
-
-			uh = appUI.NewUIMessage("Key File Passphrase")
-			uh.WriteInfoMessage("Enter the passphrase needed to open the key file.")
-			uh.ShowMessageWithSecureInput(func(keyFilePassphrase *string) {
-				input <-keyFilePassphrase
-			})
-			if keyFilePassphrase = <-input; keyFilePassphrase == nil {
-				err = fmt.Errorf("key file needs a passphrase")
-				return err
-			}
-
-			if ownerKey, err = crypto.NewRSAKeyFromFile(*keyFileName, []byte(*keyFilePassphrase)); err == nil {
-				err = owner.SetKey(ownerKey, false)				
-			}
-			if err != nil {
-				err = fmt.Errorf("failed to load user's private key: %s", err.Error())
-				return err
-			}
-		}
-		
-		if targetContext := appConfig.TargetContext(); targetContext != nil {
-			if appConfig.GetConfigAsOf() < awsAuth.ConfigTimestamp() {
-				userAPI := mycscloud.NewUserAPI(gqlClient)
-		
-				if ownerConfig, err = userAPI.GetUserConfig(owner); err != nil {
-					err = fmt.Errorf(
-						"failed to sync target context with remote: %s", 
-						err.Error(),
-					)
-					return err
-				}
-				if err = appConfig.TargetContext().Reset(); err != nil {
-					err = fmt.Errorf(
-						"failed to sync target context with remote: %s", 
-						err.Error(),
-					)
-					return err
-				}
-				if err = appConfig.TargetContext().Load(bytes.NewReader(ownerConfig)); err != nil {
-					err = fmt.Errorf(
-						"failed to sync target context with remote: %s", 
-						err.Error(),
-					)
-					return err
-				}
-				appConfig.SetConfigAsOf(awsAuth.ConfigTimestamp())
-			}
-		}
-	} 
-
 	return nil
 }
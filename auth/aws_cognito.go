@@ -2,133 +2,93 @@ package auth
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"strconv"
+	"time"
 
 	"github.com/lestrrat-go/jwx/jwk"
-	"github.com/lestrrat-go/jwx/jwt"
-	"github.com/mevansam/goutils/logger"
+	"golang.org/x/oauth2"
 
 	"github.com/appbricks/cloud-builder/config"
 	"github.com/appbricks/mycloudspace-client/api"
 )
 
-type AWSCognitoJWT struct {
-	jwkSet    jwk.Set
-	jwtToken  jwt.Token
+// claims of id tokens issued
+// by a Cognito user pool
+var CognitoClaimMapping = api.ClaimMapping{
+	UserID:          "custom:userID",
+	Username:        "cognito:username",
+	Preferences:     "custom:preferences",
+	KeyTimestamp:    "custom:keyTimestamp",
+	ConfigTimestamp: "custom:configTimestamp",
+	TokenUse:        "token_use",
 }
 
-type Preferences struct {
-	PreferredName   string `json:"preferredName,omitempty"`
-	EnableBiometric bool   `json:"enableBiometric"`
-	EnableMFA       bool   `json:"enableMFA"`
-	EnableTOTP      bool   `json:"enableTOTP"`
-	RememberFor24h  bool   `json:"rememberFor24h"`
-}
-
-func NewAWSCognitoJWT(serviceConfig api.ServiceConfig, authContext config.AuthContext) (*AWSCognitoJWT, error) {
+// identity provider for a
+// Cognito user pool
+type CognitoProvider struct {
+	region,
+	userPoolID,
+	clientID string
 
-	var (
-		err error
-	)
-	awsJWT := &AWSCognitoJWT{}
-
-	if awsJWT.jwkSet, err = jwk.Fetch(
-		context.Background(), 
-		fmt.Sprintf(
-			"https://cognito-idp.%s.amazonaws.com/%s/.well-known/jwks.json", 
-			serviceConfig.Region, 
-			serviceConfig.UserPoolID,
-		),
-	); err != nil {
-		return nil, err
-	}
-	if awsJWT.jwtToken, err = jwt.Parse(
-		[]byte(authContext.GetToken().Extra("id_token").(string)),
-		jwt.WithKeySet(awsJWT.jwkSet),
-	); err != nil {
-		return nil, err
-	}
-	
-	return awsJWT, nil
+	endpoint     oauth2.Endpoint
+	claimMapping api.ClaimMapping
+	clockSkew    time.Duration
 }
 
-func (awsJWT *AWSCognitoJWT) UserID() string {
-	username, _ := awsJWT.jwtToken.Get("custom:userID")
-	return username.(string)
+// Deprecated: use IdentityToken
+type AWSCognitoJWT = IdentityToken
+
+// returns the identity provider for the
+// given service's Cognito user pool
+func NewCognitoProvider(serviceConfig api.ServiceConfig) *CognitoProvider {
+
+	// custom claims are merged over the Cognito
+	// claims so the token use is always validated
+	claimMapping := CognitoClaimMapping.Merge(serviceConfig.Claims)
+	return &CognitoProvider{
+		region:     serviceConfig.Region,
+		userPoolID: serviceConfig.UserPoolID,
+		clientID:   serviceConfig.CliendID,
+
+		endpoint: oauth2.Endpoint{
+			AuthURL:       serviceConfig.AuthURL,
+			TokenURL:      serviceConfig.TokenURL,
+			DeviceAuthURL: serviceConfig.DeviceAuthURL,
+		},
+		claimMapping: claimMapping,
+		clockSkew:    clockSkew(serviceConfig),
+	}
 }
 
-func (awsJWT *AWSCognitoJWT) Username() string {
-	username, _ := awsJWT.jwtToken.Get("cognito:username")
-	return username.(string)
+// Deprecated: use NewIdentityToken
+func NewAWSCognitoJWT(serviceConfig api.ServiceConfig, authContext config.AuthContext) (*AWSCognitoJWT, error) {
+	return NewIdentityToken(context.Background(), NewIdentityProvider(serviceConfig), authContext)
 }
 
-func (awsJWT *AWSCognitoJWT) Preferences() *Preferences {
-
-	var (
-		err error
-		ok  bool
-
-		value   interface{}
-		data    string
+func (p *CognitoProvider) Issuer() string {
+	return fmt.Sprintf(
+		"https://cognito-idp.%s.amazonaws.com/%s",
+		p.region,
+		p.userPoolID,
 	)
-	prefs := &Preferences{}
-	
-	if value, _ = awsJWT.jwtToken.Get("custom:preferences"); value == nil {
-		return prefs
-	}
-	if data, ok = value.(string); !ok {
-		logger.ErrorMessage(
-			"JWT Token claim custom:preferences is not the expected type: %# v", 
-			value,
-		)
-		return prefs
-	}
-	if err = json.Unmarshal([]byte(data), prefs); err != nil {
-		logger.ErrorMessage(
-			"Unable to parse JWT Token claim custom:preferences with value '%s': %s", 
-			data, err.Error(),
-		)
-	}
-	return prefs
 }
 
-func (awsJWT *AWSCognitoJWT) KeyTimestamp() int64 {
-	return awsJWT.getTimesampClaim("custom:keyTimestamp")
+func (p *CognitoProvider) Endpoint(ctx context.Context) (oauth2.Endpoint, error) {
+	return p.endpoint, nil
 }
 
-func (awsJWT *AWSCognitoJWT) ConfigTimestamp() int64 {
-	return awsJWT.getTimesampClaim("custom:configTimestamp")
+func (p *CognitoProvider) Audience() string {
+	return p.clientID
 }
 
-func (awsJWT *AWSCognitoJWT) getTimesampClaim(claimKey string) int64 {
+func (p *CognitoProvider) KeySet(ctx context.Context, keyID string) (jwk.Set, error) {
+	return keySets.get(ctx, p.Issuer()+"/.well-known/jwks.json", keyID)
+}
 
-	var (
-		err error
-		ok  bool
+func (p *CognitoProvider) ClaimMapping() api.ClaimMapping {
+	return p.claimMapping
+}
 
-		value   interface{}
-		ts      string
-		tsValue int64
-	)
-	
-	if value, _ = awsJWT.jwtToken.Get(claimKey); value == nil {
-		return 0
-	}	
-	if ts, ok = value.(string); !ok {
-		logger.ErrorMessage(
-			"JWT Token claim %s is not the expected type: %# v", 
-			claimKey, value,
-		)
-		return 0
-	}
-	if tsValue, err = strconv.ParseInt(ts, 10, 64); err != nil {
-		logger.ErrorMessage(
-			"Unable to parse JWT Token claim %s with value '%s': %s", 
-			claimKey, ts, err.Error(),
-		)
-		return 0
-	}
-	return tsValue
+func (p *CognitoProvider) ClockSkew() time.Duration {
+	return p.clockSkew
 }
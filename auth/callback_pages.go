@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"sync"
+
+	"github.com/mevansam/goutils/logger"
+)
+
+// pages shown in the browser once an
+// authorization code login completes
+type CallbackPage string
+
+const (
+	CallbackSuccess   CallbackPage = "success"
+	CallbackError     CallbackPage = "error"
+	CallbackCancelled CallbackPage = "cancelled"
+)
+
+var callbackPages = []CallbackPage{CallbackSuccess, CallbackError, CallbackCancelled}
+
+// data with which a callback page is rendered
+type CallbackPageData struct {
+	// data URI of the logo image
+	Logo template.URL
+
+	// short code of the error the login failed
+	// with. the error's details are only logged
+	// as they may reveal internal information.
+	Error string
+}
+
+// error code shown for failures other
+// than the standard OAuth errors
+const callbackErrorCode = "authentication_failed"
+
+// OAuth error codes that may be returned to the
+// callback. other codes are not shown as they
+// have not been issued by a compliant provider.
+var oauthErrorCodes = map[string]bool{
+	"invalid_request":            true,
+	"unauthorized_client":        true,
+	"access_denied":              true,
+	"unsupported_response_type":  true,
+	"invalid_scope":              true,
+	"server_error":               true,
+	"temporarily_unavailable":    true,
+	"interaction_required":       true,
+	"login_required":             true,
+	"account_selection_required": true,
+	"consent_required":           true,
+}
+
+var (
+	callbackPageTemplates map[CallbackPage]*template.Template
+	callbackLogo          template.URL
+
+	callbackPagesMx sync.Mutex
+)
+
+// overrides the templates of the pages shown in the
+// browser once a login completes. the given file system
+// may contain any of "layout.html", "success.html",
+// "error.html" and "cancelled.html" and a "logo.png".
+// templates are parsed after the default templates so
+// an override only needs to redefine the templates
+// it changes i.e. "layout", "style", "title" or
+// "content". pages are rendered with CallbackPageData.
+// a nil file system restores the default templates.
+func SetCallbackTemplates(templates fs.FS) error {
+
+	var (
+		err error
+
+		pages map[CallbackPage]*template.Template
+		logo  template.URL
+		data  []byte
+	)
+
+	if pages, err = parseCallbackTemplates(templates); err != nil {
+		return err
+	}
+	if templates != nil {
+		if data, err = fs.ReadFile(templates, "logo.png"); err == nil {
+			logo = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(data))
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	callbackPagesMx.Lock()
+	defer callbackPagesMx.Unlock()
+
+	callbackPageTemplates = pages
+	callbackLogo = logo
+	return nil
+}
+
+// renders the given callback page with the given
+// error code to the response. all assets are
+// inlined so the page renders without network
+// access.
+func renderCallbackPage(
+	w http.ResponseWriter,
+	page CallbackPage,
+	status int,
+	errCode string,
+) {
+
+	var (
+		err error
+
+		html bytes.Buffer
+	)
+
+	callbackPagesMx.Lock()
+	if callbackPageTemplates == nil {
+		if callbackPageTemplates, err = parseCallbackTemplates(nil); err != nil {
+			callbackPagesMx.Unlock()
+			logger.ErrorMessage("renderCallbackPage(): Failed to parse callback page templates: %s", err.Error())
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+	}
+	tmpl := callbackPageTemplates[page]
+	data := CallbackPageData{
+		Logo: callbackLogo,
+	}
+	if len(errCode) > 0 {
+		if oauthErrorCodes[errCode] {
+			data.Error = errCode
+		} else {
+			data.Error = callbackErrorCode
+		}
+	}
+	callbackPagesMx.Unlock()
+
+	if len(data.Logo) == 0 {
+		data.Logo = template.URL("data:image/png;base64," + appBricksLogoImg)
+	}
+	// the page is rendered to a buffer so that a
+	// template error does not result in a
+	// partially written response
+	if err = tmpl.ExecuteTemplate(&html, "layout", data); err != nil {
+		logger.ErrorMessage("renderCallbackPage(): Failed to render callback page '%s': %s", page, err.Error())
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if _, err = w.Write(html.Bytes()); err != nil {
+		logger.DebugMessage("renderCallbackPage(): Unable to return callback page '%s': %s", page, err.Error())
+	}
+}
+
+// parses the default callback page templates
+// and the given overrides of them
+func parseCallbackTemplates(overrides fs.FS) (map[CallbackPage]*template.Template, error) {
+
+	var (
+		err error
+	)
+
+	pages := make(map[CallbackPage]*template.Template)
+	for _, page := range callbackPages {
+		name := string(page) + ".html"
+
+		tmpl := template.New(name)
+		if tmpl, err = tmpl.ParseFS(callbackTemplates, "templates/callback/layout.html", "templates/callback/"+name); err != nil {
+			return nil, err
+		}
+		if overrides != nil {
+			for _, override := range []string{"layout.html", name} {
+				if _, err = fs.Stat(overrides, override); err != nil {
+					if errors.Is(err, fs.ErrNotExist) {
+						continue
+					}
+					return nil, err
+				}
+				if tmpl, err = tmpl.ParseFS(overrides, override); err != nil {
+					return nil, fmt.Errorf("invalid callback page template '%s': %s", override, err.Error())
+				}
+			}
+		}
+		pages[page] = tmpl
+	}
+	return pages, nil
+}
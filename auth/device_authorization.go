@@ -0,0 +1,580 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hasura/go-graphql-client"
+
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/appbricks/cloud-builder/userspace"
+	"github.com/appbricks/mycloudspace-client/api"
+	"github.com/appbricks/mycloudspace-client/audit"
+	"github.com/appbricks/mycloudspace-client/internal/securefile"
+	"github.com/appbricks/mycloudspace-client/mycscloud"
+	"github.com/appbricks/mycloudspace-client/ui"
+	"github.com/mevansam/goutils/crypto"
+	"github.com/mevansam/goutils/logger"
+)
+
+// states of a device authorization
+type AuthorizationState string
+
+const (
+	// the logged in user is read from the
+	// session token and the device is checked
+	// to have an owner
+	StateValidateUser AuthorizationState = "validate-user"
+	// the user's access to the device
+	// is checked with MyCS cloud
+	StateAuthorizeDevice AuthorizationState = "authorize-device"
+	// the user is not authorized and is asked
+	// whether access should be requested
+	StateRequestAccess AuthorizationState = "request-access"
+	// the user is the device owner and the
+	// owner's key needs to be loaded
+	StateLoadOwnerKey AuthorizationState = "load-owner-key"
+	// the user is the device owner and the
+	// device's config is synced with the
+	// owner's remote config
+	StateSyncConfig AuthorizationState = "sync-config"
+	// the authorization has completed
+	StateDone AuthorizationState = "done"
+)
+
+// outcomes of a device authorization
+type AuthorizationOutcome int
+
+const (
+	// the authorization has not completed
+	AuthorizationInProgress AuthorizationOutcome = iota
+	// the user is authorized to use the device
+	Authorized
+	// the user is not authorized to use the
+	// device and a request for access is
+	// waiting to be approved by the owner
+	PendingApproval
+	// the user is the device owner but the
+	// owner's key was not provided. the
+	// authorization can be resumed once
+	// the key is available.
+	NeedsOwnerKey
+	// the user is the device owner and the
+	// device's config has been updated with
+	// the owner's latest remote config
+	ConfigSynced
+)
+
+func (o AuthorizationOutcome) String() string {
+	switch o {
+	case AuthorizationInProgress:
+		return "in progress"
+	case Authorized:
+		return "authorized"
+	case PendingApproval:
+		return "pending approval"
+	case NeedsOwnerKey:
+		return "needs owner key"
+	case ConfigSynced:
+		return "config synced"
+	default:
+		return fmt.Sprintf("AuthorizationOutcome(%d)", int(o))
+	}
+}
+
+// progress of a device authorization that is saved
+// so that an authorization which has stopped can be
+// resumed from its last state
+type authorizationProgress struct {
+	State    AuthorizationState `json:"state"`
+	UserID   string             `json:"userID"`
+	DeviceID string             `json:"deviceID"`
+	Updated  time.Time          `json:"updated"`
+}
+
+// saved progress older than this is
+// discarded and the authorization is
+// started from the beginning
+const authorizationProgressTTL = time.Hour
+
+// authorizes the logged in user's access to the
+// device as a sequence of states. the authorization
+// can be run to completion or stepped through one
+// state at a time. if a state fails or needs input
+// that is not provided the authorization stops in
+// that state and can be resumed later. if the user
+// is not authorized the user's session is discarded
+// and the authorization starts over once the user
+// has logged in again.
+type DeviceAuthorization struct {
+	serviceConfig api.ServiceConfig
+	appConfig     config.Config
+	appUI         ui.UI
+
+	gqlClient *graphql.Client
+	deviceAPI *mycscloud.DeviceAPI
+
+	state   AuthorizationState
+	outcome AuthorizationOutcome
+
+	// whether access to the device was
+	// requested by this authorization
+	accessRequested bool
+	// state of a previous authorization which
+	// is resumed once the device has been
+	// authorized again
+	resumeState AuthorizationState
+
+	awsAuth *IdentityToken
+	userID,
+	userName string
+}
+
+// returns a device authorization for the user logged
+// in to the given config. if a previous authorization
+// of the same user and device stopped before it
+// completed the new authorization resumes from the
+// state the previous one stopped in.
+func NewDeviceAuthorization(
+	serviceConfig api.ServiceConfig,
+	appConfig config.Config,
+	appUI ui.UI,
+) (*DeviceAuthorization, error) {
+
+	var (
+		err error
+	)
+
+	if serviceConfig, err = ResolveServiceConfig(context.Background(), serviceConfig); err != nil {
+		return nil, err
+	}
+	gqlClient := api.NewGraphQLClientForService(context.Background(), serviceConfig, appConfig.AuthContext())
+
+	deviceAPI := mycscloud.NewDeviceAPI(gqlClient)
+	if cache, cacheErr := mycscloud.NewCacheForConfig(appConfig); cacheErr == nil {
+		deviceAPI.WithCache(cache)
+	} else {
+		logger.DebugMessage("NewDeviceAuthorization(): Device authorization will not be cached: %s", cacheErr.Error())
+	}
+
+	return &DeviceAuthorization{
+		serviceConfig: serviceConfig,
+		appConfig:     appConfig,
+		appUI:         appUI,
+
+		gqlClient: gqlClient,
+		deviceAPI: deviceAPI,
+
+		state: StateValidateUser,
+	}, nil
+}
+
+// returns the state the authorization will
+// execute when it is next stepped
+func (a *DeviceAuthorization) State() AuthorizationState {
+	return a.state
+}
+
+// returns the outcome of the authorization
+func (a *DeviceAuthorization) Outcome() AuthorizationOutcome {
+	return a.outcome
+}
+
+// runs the authorization from its current state
+// until it completes, fails or stops as it needs
+// input that was not provided
+func (a *DeviceAuthorization) Run() (AuthorizationOutcome, error) {
+
+	var (
+		err error
+
+		outcome AuthorizationOutcome
+	)
+
+	for outcome == AuthorizationInProgress {
+		if outcome, err = a.Step(); err != nil {
+			return outcome, err
+		}
+	}
+	return outcome, nil
+}
+
+// executes the authorization's current state and
+// transitions to the next state. the outcome is
+// AuthorizationInProgress until the authorization
+// completes or stops for input. if an error is
+// returned the authorization remains in the
+// failed state.
+func (a *DeviceAuthorization) Step() (AuthorizationOutcome, error) {
+
+	var (
+		err error
+	)
+
+	if a.state == StateDone {
+		return a.outcome, fmt.Errorf("device authorization has completed")
+	}
+	a.outcome = AuthorizationInProgress
+
+	switch a.state {
+	case StateValidateUser:
+		err = a.validateUser()
+	case StateAuthorizeDevice:
+		err = a.authorizeDevice()
+	case StateRequestAccess:
+		err = a.requestAccess()
+	case StateLoadOwnerKey:
+		err = a.loadOwnerKey()
+	case StateSyncConfig:
+		err = a.syncConfig()
+	default:
+		return a.outcome, fmt.Errorf("unknown device authorization state '%s'", a.state)
+	}
+	if err != nil {
+		logger.DebugMessage("DeviceAuthorization.Step(): Device authorization failed in state '%s': %s", a.state, err.Error())
+	}
+	a.saveProgress()
+	return a.outcome, err
+}
+
+// discards the progress of the authorization
+// so that it starts from the beginning
+func (a *DeviceAuthorization) Reset() error {
+
+	a.state = StateValidateUser
+	a.outcome = AuthorizationInProgress
+	a.accessRequested = false
+	a.resumeState = ""
+
+	if err := os.Remove(a.progressFile()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (a *DeviceAuthorization) validateUser() error {
+
+	var (
+		err error
+	)
+
+	deviceContext := a.appConfig.DeviceContext()
+	if _, isOwnerSet := deviceContext.GetOwnerUserID(); !isOwnerSet {
+		return fmt.Errorf("no device owner configured")
+	}
+
+	// validate and parse JWT token
+	if a.awsAuth, err = newServiceIdentityToken(a.serviceConfig, a.appConfig.AuthContext()); err != nil {
+		return err
+	}
+	if a.userID, err = a.awsAuth.UserID(); err != nil {
+		return err
+	}
+	if a.userName, err = a.awsAuth.Username(); err != nil {
+		return err
+	}
+
+	// the device is always authorized again as the
+	// user's access may have changed since a previous
+	// authorization stopped. the saved progress is
+	// only resumed once the device is authorized.
+	a.state = StateAuthorizeDevice
+	a.resumeState = ""
+	if progress := a.loadProgress(); progress != nil {
+		if progress.State == StateLoadOwnerKey || progress.State == StateSyncConfig {
+			a.resumeState = progress.State
+		}
+	}
+	return nil
+}
+
+func (a *DeviceAuthorization) authorizeDevice() error {
+
+	var (
+		err error
+	)
+
+	deviceContext := a.appConfig.DeviceContext()
+
+	if err = a.deviceAPI.UpdateDeviceContext(deviceContext); err != nil {
+		if errors.Is(err, mycscloud.ErrUnauthorized) {
+			a.state = StateRequestAccess
+			return nil
+		}
+		if errors.Is(err, mycscloud.ErrAccessPending) {
+			a.appUI.ShowNoticeMessage(
+				"Device Access",
+				fmt.Sprintf("User \"%s\" is not authorized to use this device. A request to grant access to this device is still pending.", a.userName),
+			)
+			a.complete(PendingApproval)
+			a.resetAuthContext()
+			return nil
+		}
+		a.appUI.ShowErrorMessage(fmt.Sprintf("Device authorization failed: %s", err.Error()))
+		a.resetAuthContext()
+		a.state = StateValidateUser
+		return err
+	}
+	if isStale, cachedAt := a.deviceAPI.IsStale(); isStale {
+		logger.WarnMessage(
+			"DeviceAuthorization.authorizeDevice(): Service could not be reached so device was authorized using the authorization cached at %s.",
+			cachedAt.Format(time.RFC3339),
+		)
+	}
+
+	// if logged in user is the owner ensure
+	// owner is initialized and config is latest
+	if ownerUserID, _ := deviceContext.GetOwnerUserID(); a.userID == ownerUserID {
+		if len(a.resumeState) > 0 {
+			logger.DebugMessage("DeviceAuthorization.authorizeDevice(): Resuming device authorization in state '%s'.", a.resumeState)
+			a.state = a.resumeState
+			a.resumeState = ""
+			return nil
+		}
+		if len(deviceContext.GetOwner().RSAPrivateKey) == 0 {
+			a.state = StateLoadOwnerKey
+		} else {
+			a.state = StateSyncConfig
+		}
+		return nil
+	}
+	a.complete(Authorized)
+	return nil
+}
+
+func (a *DeviceAuthorization) requestAccess() error {
+
+	var (
+		err error
+
+		user *userspace.User
+	)
+
+	deviceContext := a.appConfig.DeviceContext()
+
+	requestAccess := make(chan bool)
+	defer close(requestAccess)
+
+	uh := a.appUI.NewUIMessage(deviceAccessPrompt)
+	uh.WriteNoticeMessage(
+		fmt.Sprintf(
+			"User \"%s\" is not authorized to use this device.\n\n"+
+				"Do you wish to request access to this device", a.userName,
+		),
+	)
+	uh.ShowMessageWithYesNoInput(func(yes bool) {
+		requestAccess <- yes
+	})
+
+	defer func() {
+		if err != nil {
+			a.appUI.ShowErrorMessage(fmt.Sprintf("Device authorization failed: %s", err.Error()))
+			a.state = StateValidateUser
+		}
+		a.resetAuthContext()
+	}()
+
+	if !<-requestAccess {
+		err = fmt.Errorf("access request declined")
+		return err
+	}
+
+	if user, _ = deviceContext.GetGuestUser(a.userName); user == nil {
+		if user, err = deviceContext.NewGuestUser(a.userID, a.userName); err != nil {
+			err = fmt.Errorf("failed to add new guest user to device: %s", err.Error())
+			return err
+		}
+	} else {
+		user.Active = false
+	}
+	_, _, err = a.deviceAPI.AddDeviceUser(deviceContext.GetDevice().DeviceID, "")
+	audit.Record(a.appConfig, audit.EventDeviceAccessRequest, a.userName, err, nil)
+	if err != nil {
+		err = fmt.Errorf("failed to add new guest user to device: %s", err.Error())
+		return err
+	}
+	a.appUI.ShowNoteMessage(
+		"Device Access",
+		fmt.Sprintf("A request to grant user \"%s\" access to this device has been submitted.", user.Name),
+	)
+	a.accessRequested = true
+	a.complete(PendingApproval)
+	return nil
+}
+
+func (a *DeviceAuthorization) loadOwnerKey() error {
+
+	var (
+		err error
+
+		keyFileName,
+		keyFilePassphrase *string
+
+		ownerKey *crypto.RSAKey
+	)
+
+	owner := a.appConfig.DeviceContext().GetOwner()
+	if len(owner.RSAPrivateKey) > 0 {
+		// key was loaded since the
+		// authorization stopped
+		a.state = StateSyncConfig
+		return nil
+	}
+
+	input := make(chan *string, 1)
+	defer close(input)
+
+	uh := a.appUI.NewUIMessage(ownerKeyFilePrompt)
+	uh.ShowMessageWithFileInput(func(keyFileName *string) {
+		input <- keyFileName
+	})
+	if keyFileName = <-input; keyFileName == nil {
+		// stop until the key is available
+		a.outcome = NeedsOwnerKey
+		return nil
+	}
+
+	uh = a.appUI.NewUIMessage(ownerKeyPassphrasePrompt)
+	uh.WriteInfoMessage("Enter the passphrase needed to open the key file.")
+	uh.ShowMessageWithSecureInput(func(keyFilePassphrase *string) {
+		input <- keyFilePassphrase
+	})
+	if keyFilePassphrase = <-input; keyFilePassphrase == nil {
+		a.outcome = NeedsOwnerKey
+		return nil
+	}
+
+	if ownerKey, err = crypto.NewRSAKeyFromFile(*keyFileName, []byte(*keyFilePassphrase)); err == nil {
+		err = owner.SetKey(ownerKey, false)
+	}
+	audit.Record(a.appConfig, audit.EventOwnerKeyLoad, a.userName, err, map[string]string{"keyFile": *keyFileName})
+	if err != nil {
+		return fmt.Errorf("failed to load user's private key: %s", err.Error())
+	}
+
+	a.state = StateSyncConfig
+	return nil
+}
+
+func (a *DeviceAuthorization) syncConfig() error {
+
+	var (
+		err error
+
+		configTimestamp int64
+		ownerConfig     []byte
+	)
+
+	targetContext := a.appConfig.TargetContext()
+	if targetContext == nil {
+		a.complete(Authorized)
+		return nil
+	}
+	if configTimestamp, err = a.awsAuth.ConfigTimestamp(); err != nil {
+		return err
+	}
+	if a.appConfig.GetConfigAsOf() >= configTimestamp {
+		a.complete(Authorized)
+		return nil
+	}
+
+	userAPI := mycscloud.NewUserAPI(a.gqlClient)
+	if ownerConfig, err = userAPI.GetUserConfig(a.appConfig.DeviceContext().GetOwner()); err != nil {
+		return fmt.Errorf("failed to sync target context with remote: %s", err.Error())
+	}
+	if err = targetContext.Reset(); err != nil {
+		return fmt.Errorf("failed to sync target context with remote: %s", err.Error())
+	}
+	if err = targetContext.Load(bytes.NewReader(ownerConfig)); err != nil {
+		return fmt.Errorf("failed to sync target context with remote: %s", err.Error())
+	}
+	a.appConfig.SetConfigAsOf(configTimestamp)
+
+	a.complete(ConfigSynced)
+	return nil
+}
+
+func (a *DeviceAuthorization) complete(outcome AuthorizationOutcome) {
+	a.state = StateDone
+	a.outcome = outcome
+}
+
+// the session of a user who is not authorized
+// to use the device is discarded
+func (a *DeviceAuthorization) resetAuthContext() {
+	if err := a.appConfig.AuthContext().Reset(); err != nil {
+		logger.ErrorMessage(
+			"Failed to reset auth context as device authorization failed: %s",
+			err.Error(),
+		)
+	}
+}
+
+func (a *DeviceAuthorization) progressFile() string {
+	return filepath.Join(filepath.Dir(a.appConfig.GetConfigFile()), "device-authorization")
+}
+
+// returns the saved progress if it is for
+// the current user and device and has not
+// expired
+func (a *DeviceAuthorization) loadProgress() *authorizationProgress {
+
+	data, err := os.ReadFile(a.progressFile())
+	if err != nil {
+		return nil
+	}
+	progress := &authorizationProgress{}
+	if err = json.Unmarshal(data, progress); err != nil {
+		logger.DebugMessage("DeviceAuthorization.loadProgress(): Discarding unreadable progress: %s", err.Error())
+		return nil
+	}
+	deviceID, _ := a.appConfig.DeviceContext().GetDeviceID()
+	if progress.UserID != a.userID || progress.DeviceID != deviceID ||
+		time.Since(progress.Updated) > authorizationProgressTTL {
+		return nil
+	}
+	return progress
+}
+
+// saves the authorization's progress if it has
+// stopped in a state that can be resumed and
+// removes it otherwise
+func (a *DeviceAuthorization) saveProgress() {
+
+	var (
+		err error
+
+		data []byte
+	)
+
+	if a.state == StateAuthorizeDevice && len(a.resumeState) > 0 {
+		// progress is kept until the device
+		// has been authorized again
+		return
+	}
+	if a.state != StateLoadOwnerKey && a.state != StateSyncConfig {
+		if err = os.Remove(a.progressFile()); err != nil && !os.IsNotExist(err) {
+			logger.ErrorMessage("DeviceAuthorization.saveProgress(): Failed to remove progress: %s", err.Error())
+		}
+		return
+	}
+
+	deviceID, _ := a.appConfig.DeviceContext().GetDeviceID()
+	if data, err = json.Marshal(&authorizationProgress{
+		State:    a.state,
+		UserID:   a.userID,
+		DeviceID: deviceID,
+		Updated:  time.Now(),
+	}); err == nil {
+		err = securefile.WriteFileAtomic(a.progressFile(), data)
+	}
+	if err != nil {
+		logger.ErrorMessage("DeviceAuthorization.saveProgress(): Failed to save progress: %s", err.Error())
+	}
+}
@@ -0,0 +1,285 @@
+package auth_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/appbricks/mycloudspace-client/api"
+	"github.com/appbricks/mycloudspace-client/auth"
+	"github.com/appbricks/mycloudspace-client/mycscloud"
+
+	mycs_mocks "github.com/appbricks/mycloudspace-client/test/mocks"
+	test_server "github.com/mevansam/goutils/test/mocks"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// config without a target context
+// which does not need to be synced
+type authorizationConfig struct {
+	sessionConfig
+}
+
+func (c *authorizationConfig) TargetContext() config.TargetContext {
+	return nil
+}
+
+var _ = Describe("Device Authorization", func() {
+
+	var (
+		err error
+
+		oidcServer *mycs_mocks.MockOIDCServer
+		apiServer  *test_server.MockHttpServer
+		appConfig  *authorizationConfig
+		mockUI     *mycs_mocks.MockUI
+
+		serviceConfig api.ServiceConfig
+
+		configDir string
+	)
+
+	BeforeEach(func() {
+		oidcServer, err = mycs_mocks.NewMockOIDCServer()
+		Expect(err).ToNot(HaveOccurred())
+		oidcServer.Start()
+
+		apiServer = test_server.NewMockHttpServer(9294)
+		apiServer.Start()
+
+		configDir, err = os.MkdirTemp("", "mycs-authorization-")
+		Expect(err).ToNot(HaveOccurred())
+
+		deviceContext := config.NewDeviceContext()
+		_, err = deviceContext.NewDevice()
+		Expect(err).ToNot(HaveOccurred())
+		deviceContext.SetDeviceID("zyxw", "1234", "Family Laptop")
+		_, err = deviceContext.NewOwnerUser("0000", "owner")
+		Expect(err).ToNot(HaveOccurred())
+
+		appConfig = &authorizationConfig{
+			sessionConfig{
+				configFile:    filepath.Join(configDir, "config.yml"),
+				authContext:   config.NewAuthContext(),
+				deviceContext: deviceContext,
+			},
+		}
+		mockUI = mycs_mocks.NewMockUI()
+
+		serviceConfig = api.ServiceConfig{
+			CliendID:  "mock client id",
+			IssuerURL: oidcServer.IssuerURL(),
+			ApiURL:    "http://localhost:9294/",
+		}
+	})
+
+	AfterEach(func() {
+		apiServer.Stop()
+		oidcServer.Stop()
+		os.RemoveAll(configDir)
+	})
+
+	login := func(userID, userName string) {
+		idToken, err := oidcServer.NewIDToken(map[string]interface{}{
+			"sub":                userID,
+			"preferred_username": userName,
+			"aud":                "mock client id",
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		appConfig.authContext.SetToken(
+			(&oauth2.Token{
+				AccessToken: "access token for " + userName,
+				TokenType:   "Bearer",
+				Expiry:      time.Now().Add(time.Hour),
+			}).WithExtra(map[string]interface{}{
+				"id_token": idToken,
+			}),
+		)
+		Expect(appConfig.SetLoggedInUser(userID, userName)).To(Succeed())
+	}
+
+	step := func(authorization *auth.DeviceAuthorization, nextState auth.AuthorizationState) auth.AuthorizationOutcome {
+		outcome, err := authorization.Step()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(authorization.State()).To(Equal(nextState))
+		return outcome
+	}
+
+	It("requests access for a user who is not authorized", func() {
+		login("eb018175-a0cd-4472-809f-a635afb03b16", "build-agent")
+		mockUI.AddYesNoInput(true)
+
+		apiServer.PushRequest().
+			ExpectJSONRequest(authDeviceRequest).
+			RespondWith(authDeviceUnauthorizedResponse)
+		apiServer.PushRequest().
+			ExpectJSONRequest(addBuildAgentRequest).
+			RespondWith(addBuildAgentResponse)
+
+		authorization, err := auth.NewDeviceAuthorization(serviceConfig, appConfig, mockUI)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(authorization.State()).To(Equal(auth.StateValidateUser))
+
+		Expect(step(authorization, auth.StateAuthorizeDevice)).To(Equal(auth.AuthorizationInProgress))
+		Expect(step(authorization, auth.StateRequestAccess)).To(Equal(auth.AuthorizationInProgress))
+		Expect(step(authorization, auth.StateDone)).To(Equal(auth.PendingApproval))
+		Expect(authorization.Outcome()).To(Equal(auth.PendingApproval))
+
+		// the user's session is discarded until
+		// the access request is approved
+		Expect(appConfig.authContext.IsLoggedIn()).To(BeFalse())
+		_, exists := appConfig.deviceContext.GetGuestUser("build-agent")
+		Expect(exists).To(BeTrue())
+		Expect(mockUI.Text()).To(ContainSubstring("A request to grant user \"build-agent\" access to this device has been submitted."))
+
+		_, err = authorization.Step()
+		Expect(err).To(HaveOccurred())
+		Expect(apiServer.Done()).To(BeTrue())
+	})
+
+	It("returns a pending approval outcome for an access request that has not been approved", func() {
+		_, err = appConfig.deviceContext.NewGuestUser("1111", "alice")
+		Expect(err).ToNot(HaveOccurred())
+		login("1111", "alice")
+
+		apiServer.PushRequest().
+			ExpectJSONRequest(authDeviceRequest).
+			RespondWith(authDevicePendingResponse)
+		apiServer.PushRequest().
+			ExpectJSONRequest(authDeviceRequest).
+			RespondWith(authDevicePendingResponse)
+
+		authorization, err := auth.NewDeviceAuthorization(serviceConfig, appConfig, mockUI)
+		Expect(err).ToNot(HaveOccurred())
+		outcome, err := authorization.Run()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(outcome).To(Equal(auth.PendingApproval))
+		Expect(appConfig.authContext.IsLoggedIn()).To(BeFalse())
+
+		login("1111", "alice")
+		err = auth.AuthorizeDeviceAndUser(serviceConfig, appConfig, mockUI)
+		Expect(errors.Is(err, mycscloud.ErrAccessPending)).To(BeTrue())
+		Expect(apiServer.Done()).To(BeTrue())
+	})
+
+	It("stops when the owner's key is needed and resumes where it stopped", func() {
+		login("0000", "owner")
+
+		apiServer.PushRequest().
+			ExpectJSONRequest(authDeviceRequest).
+			RespondWith(authDeviceOwnerResponse)
+
+		authorization, err := auth.NewDeviceAuthorization(serviceConfig, appConfig, mockUI)
+		Expect(err).ToNot(HaveOccurred())
+		outcome, err := authorization.Run()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(outcome).To(Equal(auth.NeedsOwnerKey))
+		Expect(authorization.State()).To(Equal(auth.StateLoadOwnerKey))
+		Expect(apiServer.Done()).To(BeTrue())
+
+		// a new authorization authorizes the device
+		// again before it resumes where it stopped
+		keyFileName := filepath.Join(configDir, "missing-key.pem")
+		passphrase := "passphrase"
+		mockUI.AddInput(&keyFileName)
+		mockUI.AddInput(&passphrase)
+
+		apiServer.PushRequest().
+			ExpectJSONRequest(authDeviceRequest).
+			RespondWith(authDeviceOwnerResponse)
+
+		authorization, err = auth.NewDeviceAuthorization(serviceConfig, appConfig, mockUI)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(step(authorization, auth.StateAuthorizeDevice)).To(Equal(auth.AuthorizationInProgress))
+		Expect(step(authorization, auth.StateLoadOwnerKey)).To(Equal(auth.AuthorizationInProgress))
+		Expect(apiServer.Done()).To(BeTrue())
+
+		_, err = authorization.Step()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(HavePrefix("failed to load user's private key"))
+		Expect(authorization.State()).To(Equal(auth.StateLoadOwnerKey))
+
+		// once the key is available the
+		// authorization completes
+		appConfig.deviceContext.GetOwner().RSAPrivateKey = "owner's key"
+		Expect(step(authorization, auth.StateSyncConfig)).To(Equal(auth.AuthorizationInProgress))
+		Expect(step(authorization, auth.StateDone)).To(Equal(auth.Authorized))
+		Expect(appConfig.authContext.IsLoggedIn()).To(BeTrue())
+
+		// progress is discarded once
+		// the authorization completes
+		authorization, err = auth.NewDeviceAuthorization(serviceConfig, appConfig, mockUI)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(step(authorization, auth.StateAuthorizeDevice)).To(Equal(auth.AuthorizationInProgress))
+	})
+
+	It("does not resume saved progress if the device is no longer authorized", func() {
+		login("0000", "owner")
+		mockUI.AddYesNoInput(false)
+
+		apiServer.PushRequest().
+			ExpectJSONRequest(authDeviceRequest).
+			RespondWith(authDeviceOwnerResponse)
+		apiServer.PushRequest().
+			ExpectJSONRequest(authDeviceRequest).
+			RespondWith(authDeviceUnauthorizedResponse)
+
+		authorization, err := auth.NewDeviceAuthorization(serviceConfig, appConfig, mockUI)
+		Expect(err).ToNot(HaveOccurred())
+		outcome, err := authorization.Run()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(outcome).To(Equal(auth.NeedsOwnerKey))
+		Expect(authorization.State()).To(Equal(auth.StateLoadOwnerKey))
+
+		authorization, err = auth.NewDeviceAuthorization(serviceConfig, appConfig, mockUI)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(step(authorization, auth.StateAuthorizeDevice)).To(Equal(auth.AuthorizationInProgress))
+		Expect(step(authorization, auth.StateRequestAccess)).To(Equal(auth.AuthorizationInProgress))
+		Expect(apiServer.Done()).To(BeTrue())
+
+		_, err = authorization.Step()
+		Expect(err).To(HaveOccurred())
+		Expect(authorization.State()).To(Equal(auth.StateValidateUser))
+		Expect(appConfig.authContext.IsLoggedIn()).To(BeFalse())
+	})
+})
+
+const authDevicePendingResponse = `{
+	"data": {
+		"authDevice": {
+			"accessType": "pending"
+		}
+	}
+}`
+const authDeviceOwnerResponse = `{
+	"data": {
+		"authDevice": {
+			"accessType": "admin",
+			"device": {
+				"deviceID": "1234",
+				"deviceName": "Family Laptop",
+				"deviceType": "MacBook",
+				"managedDevices": [],
+				"users": {
+					"deviceUsers": [
+						{
+							"user": {
+								"userID": "0000",
+								"userName": "owner"
+							},
+							"isOwner": true,
+							"status": "active"
+						}
+					]
+				}
+			}
+		}
+	}
+}`
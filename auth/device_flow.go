@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+
+	"golang.org/x/oauth2"
+
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/appbricks/mycloudspace-client/api"
+	"github.com/appbricks/mycloudspace-client/mycscloud"
+	"github.com/appbricks/mycloudspace-client/ui"
+	"github.com/mevansam/goutils/logger"
+)
+
+// the flow used to login a user
+type LoginFlow int
+
+const (
+	// the device authorization flow is used if the
+	// host does not have a display and the identity
+	// provider supports it otherwise the browser
+	// flow is used
+	LoginFlowAuto LoginFlow = iota
+	// the user logs in via a browser which is
+	// redirected to a local callback server
+	LoginFlowBrowser
+	// the user logs in on another device using the
+	// code shown by the app (RFC 8628)
+	LoginFlowDeviceCode
+)
+
+type loginFlowContextKey struct{}
+
+// returns a context which selects the
+// flow used by Authenticate to login
+func WithLoginFlow(ctx context.Context, flow LoginFlow) context.Context {
+	return context.WithValue(ctx, loginFlowContextKey{}, flow)
+}
+
+// returns whether the device authorization
+// flow should be used to login
+func useDeviceCodeFlow(ctx context.Context, serviceConfig api.ServiceConfig) bool {
+
+	flow, _ := ctx.Value(loginFlowContextKey{}).(LoginFlow)
+	switch flow {
+	case LoginFlowBrowser:
+		return false
+	case LoginFlowDeviceCode:
+		return true
+	default:
+		return len(serviceConfig.DeviceAuthURL) > 0 && !hasDisplay()
+	}
+}
+
+// returns whether a browser can be
+// opened on the host's display
+func hasDisplay() bool {
+	switch runtime.GOOS {
+		case "linux":
+			return len(os.Getenv("DISPLAY")) > 0 || len(os.Getenv("WAYLAND_DISPLAY")) > 0
+		case "darwin":
+			return len(os.Getenv("SSH_CONNECTION")) == 0 && len(os.Getenv("SSH_TTY")) == 0
+		case "windows":
+			return true
+		default:
+			return false
+	}
+}
+
+// logs in the user using the OAuth 2.0 device
+// authorization grant. the user completes the
+// login in a browser on another device using
+// the code displayed by the app.
+func authenticateWithDeviceCode(
+	ctx context.Context,
+	serviceConfig api.ServiceConfig,
+	authContext config.AuthContext,
+	appUI ui.UI,
+	loginMessages ...string,
+) AsyncAuthRet {
+
+	var (
+		err error
+
+		deviceAuth *oauth2.DeviceAuthResponse
+	)
+
+	authRet := make(AsyncAuthRet, 1)
+
+	oauthConfig := serviceConfig.OAuthConfig()
+	if len(oauthConfig.Endpoint.DeviceAuthURL) == 0 {
+		authRet <-AuthRet{fmt.Errorf("identity provider does not support device authorization")}
+		return authRet
+	}
+
+	ctx, cancelFunc := context.WithCancel(ctx)
+	uh := appUI.NewUIMessageWithCancel("Login to My Cloud Space", cancelFunc)
+
+	if len(loginMessages) > 0 {
+		uh.WriteNoticeMessage(loginMessages[0])
+	}
+	if deviceAuth, err = oauthConfig.DeviceAuth(ctx); err != nil {
+		logger.ErrorMessage("Device authorization request failed: %s", err.Error())
+		cancelFunc()
+		authRet <-AuthRet{err}
+		return authRet
+	}
+	logger.TraceMessage("Device authorization response: %# v", deviceAuth)
+
+	uh.WriteNoteMessage(
+		"To login to your My Cloud Space account open a browser window on any device, " +
+		"navigate to the following URL and enter the code shown below. Once authenticated " +
+		"the MyCS app will be ready for use.",
+	)
+	uh.WriteText(fmt.Sprintf("\n=> %s\n\nCode: %s\n", deviceAuth.VerificationURI, deviceAuth.UserCode))
+	if len(deviceAuth.VerificationURIComplete) > 0 {
+		uh.WriteText(fmt.Sprintf("\nor navigate to the following URL which includes the code.\n\n=> %s\n", deviceAuth.VerificationURIComplete))
+	}
+
+	p := uh.ShowMessageWithProgressIndicator(
+		"Waiting for authentication to complete.", "",
+		"Authentication is complete. You are now signed in.", 0,
+	)
+
+	go func() {
+		defer cancelFunc()
+
+		var (
+			err error
+
+			token *oauth2.Token
+		)
+
+		p.Start()
+		defer p.Done()
+
+		// polls the token endpoint at the interval requested
+		// by the provider until the user completes the login,
+		// the code expires or the login is cancelled
+		if token, err = oauthConfig.DeviceAccessToken(ctx, deviceAuth); err != nil {
+			logger.ErrorMessage("Device authorization failed: %s", err.Error())
+			authRet <-AuthRet{err}
+			return
+		}
+		if len(api.IDToken(token)) == 0 {
+			authRet <-AuthRet{fmt.Errorf("device authorization did not return an id token")}
+			return
+		}
+		authContext.SetToken(token)
+		saveToken(tokenStoreFromContext(ctx), authContext)
+
+		// update app config with cloud properties
+		cloudAPI := mycscloud.NewCloudAPI(api.NewGraphQLClientForService(ctx, serviceConfig, authContext))
+		authRet <-AuthRet{cloudAPI.UpdatePropertiesWithContext(ctx, authContext)}
+	}()
+
+	return authRet
+}
@@ -0,0 +1,119 @@
+package auth_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/appbricks/mycloudspace-client/api"
+	"github.com/appbricks/mycloudspace-client/auth"
+
+	mycs_mocks "github.com/appbricks/mycloudspace-client/test/mocks"
+	test_server "github.com/mevansam/goutils/test/mocks"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Device Authorization Flow", func() {
+
+	var (
+		err error
+
+		oidcServer  *mycs_mocks.MockOIDCServer
+		apiServer   *test_server.MockHttpServer
+		authContext config.AuthContext
+		mockUI      *mycs_mocks.MockUI
+
+		serviceConfig api.ServiceConfig
+	)
+
+	BeforeEach(func() {
+		oidcServer, err = mycs_mocks.NewMockOIDCServer()
+		Expect(err).ToNot(HaveOccurred())
+		oidcServer.SetUserClaims(map[string]interface{}{
+			"sub":                "eb018175-a0cd-4472-809f-a635afb03b16",
+			"preferred_username": "ken",
+		})
+		oidcServer.Start()
+
+		apiServer = test_server.NewMockHttpServer(9290)
+		apiServer.Start()
+
+		authContext = config.NewAuthContext()
+		mockUI = mycs_mocks.NewMockUI()
+
+		serviceConfig = api.ServiceConfig{
+			CliendID:  "mock client id",
+			IssuerURL: oidcServer.IssuerURL(),
+			ApiURL:    "http://localhost:9290/",
+		}
+	})
+
+	AfterEach(func() {
+		apiServer.Stop()
+		oidcServer.Stop()
+	})
+
+	It("logs in a user with the code shown by the app", func() {
+		oidcServer.SetDeviceAuthPendingPolls(1)
+
+		apiServer.PushRequest().
+			ExpectJSONRequest(mycsCloudPropsRequest).
+			RespondWith(mycsCloudPropsResponse)
+
+		authRet := auth.Authenticate(
+			auth.WithLoginFlow(context.Background(), auth.LoginFlowDeviceCode),
+			serviceConfig, authContext, mockUI,
+		)
+		Expect(mockUI.WaitForText(mycs_mocks.MockUserCode, time.Second)).To(BeTrue())
+
+		select {
+		case ret := <-authRet:
+			Expect(ret.Error).ToNot(HaveOccurred())
+		case <-time.After(10 * time.Second):
+			Fail("timed out waiting for device authorization to complete")
+		}
+		Expect(oidcServer.TokenPolls()).To(Equal(2))
+		Expect(authContext.IsLoggedIn()).To(BeTrue())
+		Expect(api.IDToken(authContext.GetToken())).ToNot(BeEmpty())
+
+		keyID, keyData := authContext.GetPublicKey()
+		Expect(keyID).To(Equal("test public key id"))
+		Expect(keyData).To(Equal("test public key"))
+	})
+
+	It("fails if the login is cancelled", func() {
+		oidcServer.SetDeviceAuthPendingPolls(100)
+
+		authRet := auth.Authenticate(
+			auth.WithLoginFlow(context.Background(), auth.LoginFlowDeviceCode),
+			serviceConfig, authContext, mockUI,
+		)
+		Expect(mockUI.WaitForText(mycs_mocks.MockUserCode, time.Second)).To(BeTrue())
+
+		messages := mockUI.Messages()
+		Expect(messages).ToNot(BeEmpty())
+		messages[0].Cancel()
+
+		select {
+		case ret := <-authRet:
+			Expect(ret.Error).To(HaveOccurred())
+		case <-time.After(10 * time.Second):
+			Fail("timed out waiting for device authorization to be cancelled")
+		}
+		Expect(authContext.IsLoggedIn()).To(BeFalse())
+	})
+})
+
+const mycsCloudPropsRequest = `{
+	"query": "{mycsCloudProps{publicKeyID,publicKey}}"
+}`
+const mycsCloudPropsResponse = `{
+	"data": {
+		"mycsCloudProps": {
+			"publicKeyID": "test public key id",
+			"publicKey": "test public key"
+		}
+	}
+}`
@@ -0,0 +1,25 @@
+package auth
+
+import "fmt"
+
+// replaces the function used to open the
+// login page in the system browser
+func SetOpenBrowser(open func(url string) error) (restore func()) {
+	openBrowser, open = open, openBrowser
+	return func() {
+		openBrowser = open
+	}
+}
+
+// records the given number of key ids as not found in
+// the key set at the given URL and returns the number
+// of misses remembered by the key set cache
+func AddKeySetMisses(url string, n int) int {
+	keySets.mx.Lock()
+	defer keySets.mx.Unlock()
+
+	for i := 0; i < n; i++ {
+		keySets.addMiss(fmt.Sprintf("%s#random-key-%d", url, i))
+	}
+	return len(keySets.misses)
+}
@@ -0,0 +1,249 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwk"
+	"golang.org/x/oauth2"
+
+	"github.com/appbricks/mycloudspace-client/api"
+	"github.com/mevansam/goutils/logger"
+)
+
+// claims of id tokens issued by providers
+// that follow the OIDC standard claims
+var DefaultClaimMapping = api.ClaimMapping{
+	UserID:          "sub",
+	Username:        "preferred_username",
+	Preferences:     "preferences",
+	KeyTimestamp:    "key_timestamp",
+	ConfigTimestamp: "config_timestamp",
+}
+
+// an OAuth/OIDC identity provider which
+// issues the tokens used to access the
+// MyCS cloud services
+type IdentityProvider interface {
+	// the issuer of the provider's tokens
+	Issuer() string
+	// the provider's OAuth endpoints
+	Endpoint(ctx context.Context) (oauth2.Endpoint, error)
+	// the client the provider's id tokens are
+	// issued to. if empty the token audience
+	// is not validated.
+	Audience() string
+	// the keys used to sign the provider's tokens.
+	// the returned set is refreshed if it does
+	// not contain the key with the given id.
+	KeySet(ctx context.Context, keyID string) (jwk.Set, error)
+	// the names of the claims in the
+	// provider's id tokens
+	ClaimMapping() api.ClaimMapping
+	// the clock skew allowed when
+	// validating token timestamps
+	ClockSkew() time.Duration
+}
+
+// the clock skew allowed when validating
+// token timestamps if the service config
+// does not specify one
+const DefaultClockSkew = 2 * time.Minute
+
+// OIDC provider metadata returned by the
+// provider's discovery endpoint
+type OIDCDiscovery struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserInfoEndpoint      string   `json:"userinfo_endpoint,omitempty"`
+	DeviceEndpoint        string   `json:"device_authorization_endpoint,omitempty"`
+	EndSessionEndpoint    string   `json:"end_session_endpoint,omitempty"`
+	JWKSURI               string   `json:"jwks_uri"`
+	ScopesSupported       []string `json:"scopes_supported,omitempty"`
+}
+
+// identity provider that retrieves its endpoints
+// and signing keys via OIDC discovery
+type OIDCProvider struct {
+	issuerURL,
+	clientID string
+	claimMapping api.ClaimMapping
+	clockSkew    time.Duration
+}
+
+// discovery documents are cached by issuer as
+// they are not expected to change while the
+// client is running
+var (
+	discoveryCache = make(map[string]*OIDCDiscovery)
+	discoveryMx    sync.Mutex
+)
+
+// returns an identity provider for the issuer at
+// the given URL whose id token claims are mapped
+// using the given claim mapping
+func NewOIDCProvider(issuerURL string, claimMapping api.ClaimMapping) *OIDCProvider {
+	return &OIDCProvider{
+		issuerURL:    strings.TrimSuffix(issuerURL, "/"),
+		claimMapping: claimMapping,
+		clockSkew:    DefaultClockSkew,
+	}
+}
+
+// returns the identity provider configured for the
+// given service. if an issuer URL has not been
+// configured then the service's Cognito user pool
+// is the identity provider.
+func NewIdentityProvider(serviceConfig api.ServiceConfig) IdentityProvider {
+
+	if len(serviceConfig.IssuerURL) == 0 {
+		return NewCognitoProvider(serviceConfig)
+	}
+	provider := NewOIDCProvider(
+		serviceConfig.IssuerURL,
+		DefaultClaimMapping.Merge(serviceConfig.Claims),
+	)
+	provider.clientID = serviceConfig.CliendID
+	provider.clockSkew = clockSkew(serviceConfig)
+	return provider
+}
+
+func clockSkew(serviceConfig api.ServiceConfig) time.Duration {
+	if serviceConfig.ClockSkew > 0 {
+		return serviceConfig.ClockSkew
+	}
+	return DefaultClockSkew
+}
+
+func (p *OIDCProvider) Issuer() string {
+	return p.issuerURL
+}
+
+func (p *OIDCProvider) Audience() string {
+	return p.clientID
+}
+
+func (p *OIDCProvider) ClaimMapping() api.ClaimMapping {
+	return p.claimMapping
+}
+
+func (p *OIDCProvider) ClockSkew() time.Duration {
+	return p.clockSkew
+}
+
+func (p *OIDCProvider) Endpoint(ctx context.Context) (oauth2.Endpoint, error) {
+
+	var (
+		err error
+
+		discovery *OIDCDiscovery
+	)
+
+	if discovery, err = p.Discover(ctx); err != nil {
+		return oauth2.Endpoint{}, err
+	}
+	return oauth2.Endpoint{
+		AuthURL:       discovery.AuthorizationEndpoint,
+		TokenURL:      discovery.TokenEndpoint,
+		DeviceAuthURL: discovery.DeviceEndpoint,
+	}, nil
+}
+
+func (p *OIDCProvider) KeySet(ctx context.Context, keyID string) (jwk.Set, error) {
+
+	var (
+		err error
+
+		discovery *OIDCDiscovery
+	)
+
+	if discovery, err = p.Discover(ctx); err != nil {
+		return nil, err
+	}
+	return keySets.get(ctx, discovery.JWKSURI, keyID)
+}
+
+// returns the provider metadata published
+// at the issuer's discovery endpoint
+func (p *OIDCProvider) Discover(ctx context.Context) (*OIDCDiscovery, error) {
+	discoveryMx.Lock()
+	defer discoveryMx.Unlock()
+
+	var (
+		err error
+
+		req  *http.Request
+		resp *http.Response
+	)
+
+	if discovery, exists := discoveryCache[p.issuerURL]; exists {
+		return discovery, nil
+	}
+
+	discoveryURL := p.issuerURL + "/.well-known/openid-configuration"
+	if req, err = http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil); err != nil {
+		return nil, err
+	}
+	if resp, err = http.DefaultClient.Do(req); err != nil {
+		logger.ErrorMessage("OIDCProvider.Discover(): Request to %s failed: %s", discoveryURL, err.Error())
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc discovery at %s returned status '%s'", discoveryURL, resp.Status)
+	}
+	discovery := &OIDCDiscovery{}
+	if err = json.NewDecoder(resp.Body).Decode(discovery); err != nil {
+		return nil, fmt.Errorf("oidc discovery at %s returned an invalid response: %s", discoveryURL, err.Error())
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != p.issuerURL {
+		return nil, fmt.Errorf(
+			"oidc discovery issuer '%s' does not match expected issuer '%s'",
+			discovery.Issuer, p.issuerURL,
+		)
+	}
+	if len(discovery.JWKSURI) == 0 {
+		return nil, fmt.Errorf("oidc discovery at %s did not return a jwks uri", discoveryURL)
+	}
+	logger.TraceMessage("OIDCProvider.Discover(): Discovered provider metadata: %# v", discovery)
+
+	discoveryCache[p.issuerURL] = discovery
+	return discovery, nil
+}
+
+// returns the service config with the OAuth endpoints
+// of its identity provider set if the endpoints
+// have not been explicitly configured
+func ResolveServiceConfig(ctx context.Context, serviceConfig api.ServiceConfig) (api.ServiceConfig, error) {
+
+	var (
+		err error
+
+		endpoint oauth2.Endpoint
+	)
+
+	if len(serviceConfig.IssuerURL) == 0 ||
+		(len(serviceConfig.AuthURL) > 0 && len(serviceConfig.TokenURL) > 0 && len(serviceConfig.DeviceAuthURL) > 0) {
+		return serviceConfig, nil
+	}
+	if endpoint, err = NewIdentityProvider(serviceConfig).Endpoint(ctx); err != nil {
+		return serviceConfig, err
+	}
+	if len(serviceConfig.AuthURL) == 0 {
+		serviceConfig.AuthURL = endpoint.AuthURL
+	}
+	if len(serviceConfig.TokenURL) == 0 {
+		serviceConfig.TokenURL = endpoint.TokenURL
+	}
+	if len(serviceConfig.DeviceAuthURL) == 0 {
+		serviceConfig.DeviceAuthURL = endpoint.DeviceAuthURL
+	}
+	return serviceConfig, nil
+}
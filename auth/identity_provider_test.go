@@ -0,0 +1,279 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/appbricks/mycloudspace-client/api"
+	"github.com/appbricks/mycloudspace-client/auth"
+
+	mycs_mocks "github.com/appbricks/mycloudspace-client/test/mocks"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Identity Provider", func() {
+
+	var (
+		err error
+
+		oidcServer  *mycs_mocks.MockOIDCServer
+		authContext config.AuthContext
+	)
+
+	BeforeEach(func() {
+		oidcServer, err = mycs_mocks.NewMockOIDCServer()
+		Expect(err).ToNot(HaveOccurred())
+		oidcServer.Start()
+
+		authContext = config.NewAuthContext()
+	})
+
+	AfterEach(func() {
+		oidcServer.Stop()
+	})
+
+	setIDToken := func(claims map[string]interface{}) {
+		idToken, err := oidcServer.NewIDToken(claims)
+		Expect(err).ToNot(HaveOccurred())
+		authContext.SetToken(
+			(&oauth2.Token{}).WithExtra(
+				map[string]interface{}{
+					"id_token": idToken,
+				},
+			),
+		)
+	}
+
+	It("discovers the endpoints of an OIDC provider", func() {
+		serviceConfig, err := auth.ResolveServiceConfig(
+			context.Background(),
+			api.ServiceConfig{
+				CliendID:  "mock client id",
+				IssuerURL: oidcServer.IssuerURL(),
+			},
+		)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(serviceConfig.AuthURL).To(Equal(oidcServer.IssuerURL() + "/oauth2/authorize"))
+		Expect(serviceConfig.TokenURL).To(Equal(oidcServer.IssuerURL() + "/oauth2/token"))
+
+		// explicitly configured endpoints are not overridden
+		serviceConfig, err = auth.ResolveServiceConfig(
+			context.Background(),
+			api.ServiceConfig{
+				IssuerURL: oidcServer.IssuerURL(),
+				AuthURL:   "https://auth.local/authorize",
+				TokenURL:  "https://auth.local/token",
+			},
+		)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(serviceConfig.AuthURL).To(Equal("https://auth.local/authorize"))
+		Expect(serviceConfig.TokenURL).To(Equal("https://auth.local/token"))
+	})
+
+	It("reads the identity claims of an id token using the default claim mapping", func() {
+		setIDToken(map[string]interface{}{
+			"sub":                "eb018175-a0cd-4472-809f-a635afb03b16",
+			"preferred_username": "ken",
+			"preferences":        `{"preferredName":"Ken","enableMFA":true}`,
+			"key_timestamp":      "1630519684375",
+			"config_timestamp":   1630519699999,
+		})
+
+		provider := auth.NewIdentityProvider(api.ServiceConfig{IssuerURL: oidcServer.IssuerURL()})
+		Expect(provider.Issuer()).To(Equal(oidcServer.IssuerURL()))
+
+		idToken, err := auth.NewIdentityToken(context.Background(), provider, authContext)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(idToken.UserID()).To(Equal("eb018175-a0cd-4472-809f-a635afb03b16"))
+		Expect(idToken.Username()).To(Equal("ken"))
+		Expect(idToken.Preferences().PreferredName).To(Equal("Ken"))
+		Expect(idToken.Preferences().EnableMFA).To(BeTrue())
+		Expect(idToken.KeyTimestamp()).To(Equal(int64(1630519684375)))
+		Expect(idToken.ConfigTimestamp()).To(Equal(int64(1630519699999)))
+	})
+
+	It("reads the identity claims of an id token using a custom claim mapping", func() {
+		setIDToken(map[string]interface{}{
+			"sub":                 "eb018175-a0cd-4472-809f-a635afb03b16",
+			"custom:userID":       "0000",
+			"cognito:username":    "owner",
+			"custom:keyTimestamp": "1630519684375",
+			"token_use":           "id",
+		})
+
+		provider := auth.NewIdentityProvider(api.ServiceConfig{
+			IssuerURL: oidcServer.IssuerURL(),
+			Claims:    &auth.CognitoClaimMapping,
+		})
+		idToken, err := auth.NewIdentityToken(context.Background(), provider, authContext)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(idToken.UserID()).To(Equal("0000"))
+		Expect(idToken.Username()).To(Equal("owner"))
+		Expect(idToken.KeyTimestamp()).To(Equal(int64(1630519684375)))
+		Expect(idToken.ConfigTimestamp()).To(Equal(int64(0)))
+	})
+
+	It("merges a custom claim mapping over the provider's default claim mapping", func() {
+		claims := &api.ClaimMapping{Username: "email"}
+
+		cognitoProvider := auth.NewIdentityProvider(api.ServiceConfig{Claims: claims})
+		Expect(cognitoProvider.ClaimMapping()).To(Equal(api.ClaimMapping{
+			UserID:          "custom:userID",
+			Username:        "email",
+			Preferences:     "custom:preferences",
+			KeyTimestamp:    "custom:keyTimestamp",
+			ConfigTimestamp: "custom:configTimestamp",
+			TokenUse:        "token_use",
+		}))
+
+		oidcProvider := auth.NewIdentityProvider(api.ServiceConfig{
+			IssuerURL: oidcServer.IssuerURL(),
+			Claims:    claims,
+		})
+		Expect(oidcProvider.ClaimMapping()).To(Equal(api.ClaimMapping{
+			UserID:          "sub",
+			Username:        "email",
+			Preferences:     "preferences",
+			KeyTimestamp:    "key_timestamp",
+			ConfigTimestamp: "config_timestamp",
+		}))
+	})
+
+	It("rejects id tokens not signed by the provider", func() {
+		otherServer, err := mycs_mocks.NewMockOIDCServer()
+		Expect(err).ToNot(HaveOccurred())
+		otherServer.Start()
+		defer otherServer.Stop()
+
+		idToken, err := otherServer.NewIDToken(map[string]interface{}{"sub": "0000"})
+		Expect(err).ToNot(HaveOccurred())
+		authContext.SetToken((&oauth2.Token{}).WithExtra(map[string]interface{}{"id_token": idToken}))
+
+		provider := auth.NewIdentityProvider(api.ServiceConfig{IssuerURL: oidcServer.IssuerURL()})
+		_, err = auth.NewIdentityToken(context.Background(), provider, authContext)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("returns an error when reading a missing claim", func() {
+		setIDToken(map[string]interface{}{
+			"sub":           "eb018175-a0cd-4472-809f-a635afb03b16",
+			"key_timestamp": true,
+		})
+
+		provider := auth.NewIdentityProvider(api.ServiceConfig{IssuerURL: oidcServer.IssuerURL()})
+		idToken, err := auth.NewIdentityToken(context.Background(), provider, authContext)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = idToken.Username()
+		Expect(errors.Is(err, auth.ErrClaimNotFound)).To(BeTrue())
+		_, err = idToken.KeyTimestamp()
+		Expect(err).To(HaveOccurred())
+		Expect(idToken.ConfigTimestamp()).To(Equal(int64(0)))
+	})
+
+	It("validates the expiry, issuer, audience and token use of an id token", func() {
+		provider := auth.NewIdentityProvider(api.ServiceConfig{
+			CliendID:  "mock client id",
+			IssuerURL: oidcServer.IssuerURL(),
+			ClockSkew: time.Minute,
+		})
+		validate := func(claims map[string]interface{}) error {
+			setIDToken(claims)
+			_, err := auth.NewIdentityToken(context.Background(), provider, authContext)
+			return err
+		}
+
+		Expect(validate(map[string]interface{}{
+			"sub": "0000",
+			"aud": "mock client id",
+		})).To(Succeed())
+		// expiry within the clock skew
+		Expect(validate(map[string]interface{}{
+			"sub": "0000",
+			"aud": "mock client id",
+			"exp": time.Now().Add(-30 * time.Second),
+		})).To(Succeed())
+
+		Expect(validate(map[string]interface{}{
+			"sub": "0000",
+			"aud": "mock client id",
+			"exp": time.Now().Add(-2 * time.Minute),
+		})).ToNot(Succeed())
+		Expect(validate(map[string]interface{}{
+			"sub": "0000",
+			"aud": "mock client id",
+			"iss": "https://other.issuer",
+		})).ToNot(Succeed())
+		Expect(validate(map[string]interface{}{
+			"sub": "0000",
+			"aud": "other client id",
+		})).ToNot(Succeed())
+
+		provider = auth.NewIdentityProvider(api.ServiceConfig{
+			IssuerURL: oidcServer.IssuerURL(),
+			Claims:    &auth.CognitoClaimMapping,
+		})
+		Expect(validate(map[string]interface{}{
+			"sub":       "0000",
+			"token_use": "access",
+		})).ToNot(Succeed())
+		Expect(validate(map[string]interface{}{
+			"sub":       "0000",
+			"token_use": "id",
+		})).To(Succeed())
+	})
+
+	It("caches the provider's key set and refreshes it when the signing key is rotated", func() {
+		provider := auth.NewIdentityProvider(api.ServiceConfig{IssuerURL: oidcServer.IssuerURL()})
+
+		for i := 0; i < 3; i++ {
+			setIDToken(map[string]interface{}{"sub": "0000"})
+			_, err = auth.NewIdentityToken(context.Background(), provider, authContext)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		Expect(oidcServer.KeySetRequests()).To(Equal(1))
+
+		Expect(oidcServer.RotateSigningKey("mock-key-2")).To(Succeed())
+		setIDToken(map[string]interface{}{"sub": "0000"})
+		_, err = auth.NewIdentityToken(context.Background(), provider, authContext)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(oidcServer.KeySetRequests()).To(Equal(2))
+
+		// tokens signed with unknown keys do not
+		// cause the key set to be re-fetched again
+		otherServer, err := mycs_mocks.NewMockOIDCServer()
+		Expect(err).ToNot(HaveOccurred())
+		otherServer.Start()
+		defer otherServer.Stop()
+		Expect(otherServer.RotateSigningKey("unknown-key")).To(Succeed())
+
+		for i := 0; i < 2; i++ {
+			idToken, err := otherServer.NewIDToken(map[string]interface{}{"sub": "0000"})
+			Expect(err).ToNot(HaveOccurred())
+			authContext.SetToken((&oauth2.Token{}).WithExtra(map[string]interface{}{"id_token": idToken}))
+
+			_, err = auth.NewIdentityToken(context.Background(), provider, authContext)
+			Expect(err).To(HaveOccurred())
+		}
+		Expect(oidcServer.KeySetRequests()).To(Equal(3))
+	})
+
+	It("limits the number of unknown key ids remembered", func() {
+		Expect(auth.AddKeySetMisses(oidcServer.IssuerURL(), 2000)).To(Equal(1024))
+
+		// expired misses are evicted
+		// when the cache is full
+		refreshInterval := auth.KeySetRefreshInterval
+		auth.KeySetRefreshInterval = 0
+		defer func() {
+			auth.KeySetRefreshInterval = refreshInterval
+		}()
+		Expect(auth.AddKeySetMisses(oidcServer.IssuerURL(), 1)).To(Equal(1))
+	})
+})
@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/mevansam/goutils/logger"
+
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/appbricks/mycloudspace-client/api"
+)
+
+var ErrClaimNotFound = errors.New("claim not found in id token")
+
+// the identity of a logged in user
+// read from the claims of the id
+// token issued by an identity
+// provider
+type IdentityToken struct {
+	jwkSet       jwk.Set
+	jwtToken     jwt.Token
+	claimMapping api.ClaimMapping
+}
+
+type Preferences struct {
+	PreferredName   string `json:"preferredName,omitempty"`
+	EnableBiometric bool   `json:"enableBiometric"`
+	EnableMFA       bool   `json:"enableMFA"`
+	EnableTOTP      bool   `json:"enableTOTP"`
+	RememberFor24h  bool   `json:"rememberFor24h"`
+}
+
+// parses the id token in the given auth context
+// and verifies it was signed by the given
+// identity provider
+func NewIdentityToken(
+	ctx context.Context,
+	provider IdentityProvider,
+	authContext config.AuthContext,
+) (*IdentityToken, error) {
+
+	token := api.IDToken(authContext.GetToken())
+	if len(token) == 0 {
+		return nil, fmt.Errorf("not authenticated")
+	}
+	return parseIdentityToken(ctx, provider, token)
+}
+
+// parses the given encoded id token, verifies it
+// was signed by the given identity provider and
+// validates its expiry, issuer, audience and
+// token use claims
+func parseIdentityToken(
+	ctx context.Context,
+	provider IdentityProvider,
+	token string,
+) (*IdentityToken, error) {
+
+	var (
+		err error
+
+		message *jws.Message
+		keyID   string
+	)
+	idToken := &IdentityToken{
+		claimMapping: provider.ClaimMapping(),
+	}
+
+	if len(token) == 0 {
+		return nil, fmt.Errorf("id token is empty")
+	}
+	if message, err = jws.Parse([]byte(token)); err != nil {
+		return nil, err
+	}
+	if signatures := message.Signatures(); len(signatures) > 0 {
+		keyID = signatures[0].ProtectedHeaders().KeyID()
+	}
+	if idToken.jwkSet, err = provider.KeySet(ctx, keyID); err != nil {
+		return nil, err
+	}
+	if idToken.jwtToken, err = jwt.Parse(
+		[]byte(token),
+		jwt.WithKeySet(idToken.jwkSet),
+	); err != nil {
+		return nil, err
+	}
+
+	validateOptions := []jwt.ValidateOption{
+		jwt.WithAcceptableSkew(provider.ClockSkew()),
+		jwt.WithRequiredClaim(jwt.ExpirationKey),
+		jwt.WithIssuer(provider.Issuer()),
+	}
+	if audience := provider.Audience(); len(audience) > 0 {
+		validateOptions = append(validateOptions, jwt.WithAudience(audience))
+	}
+	if tokenUse := idToken.claimMapping.TokenUse; len(tokenUse) > 0 {
+		validateOptions = append(validateOptions, jwt.WithClaimValue(tokenUse, "id"))
+	}
+	if err = jwt.Validate(idToken.jwtToken, validateOptions...); err != nil {
+		logger.DebugMessage("parseIdentityToken(): ID token is not valid: %s", err.Error())
+		return nil, err
+	}
+
+	return idToken, nil
+}
+
+// parses the id token in the given auth context
+// issued by the service's identity provider
+func newServiceIdentityToken(
+	serviceConfig api.ServiceConfig,
+	authContext config.AuthContext,
+) (*IdentityToken, error) {
+	return NewIdentityToken(context.Background(), NewIdentityProvider(serviceConfig), authContext)
+}
+
+func (t *IdentityToken) UserID() (string, error) {
+	return t.getStringClaim(t.claimMapping.UserID)
+}
+
+func (t *IdentityToken) Username() (string, error) {
+	return t.getStringClaim(t.claimMapping.Username)
+}
+
+// the nonce sent with the authentication
+// request which returned the token
+func (t *IdentityToken) Nonce() (string, error) {
+	return t.getStringClaim("nonce")
+}
+
+// returns the user's preferences or the
+// default preferences if the token does
+// not have a valid preferences claim
+func (t *IdentityToken) Preferences() *Preferences {
+
+	var (
+		err error
+		ok  bool
+
+		value interface{}
+		data  string
+	)
+	prefs := &Preferences{}
+	claimKey := t.claimMapping.Preferences
+
+	if value, _ = t.jwtToken.Get(claimKey); value == nil {
+		return prefs
+	}
+	if data, ok = value.(string); !ok {
+		logger.ErrorMessage(
+			"JWT Token claim %s is not the expected type: %# v",
+			claimKey, value,
+		)
+		return prefs
+	}
+	if err = json.Unmarshal([]byte(data), prefs); err != nil {
+		logger.ErrorMessage(
+			"Unable to parse JWT Token claim %s with value '%s': %s",
+			claimKey, data, err.Error(),
+		)
+	}
+	return prefs
+}
+
+// returns the time the user's key was last
+// updated or 0 if the user does not have
+// a key
+func (t *IdentityToken) KeyTimestamp() (int64, error) {
+	return t.getTimesampClaim(t.claimMapping.KeyTimestamp)
+}
+
+// returns the time the user's config was
+// last updated or 0 if the user's config
+// has not been saved
+func (t *IdentityToken) ConfigTimestamp() (int64, error) {
+	return t.getTimesampClaim(t.claimMapping.ConfigTimestamp)
+}
+
+func (t *IdentityToken) getStringClaim(claimKey string) (string, error) {
+
+	var (
+		ok bool
+
+		value interface{}
+		s     string
+	)
+
+	if value, _ = t.jwtToken.Get(claimKey); value == nil {
+		return "", fmt.Errorf("%w: %s", ErrClaimNotFound, claimKey)
+	}
+	if s, ok = value.(string); !ok {
+		return "", fmt.Errorf(
+			"id token claim %s is not the expected type: %T",
+			claimKey, value,
+		)
+	}
+	return s, nil
+}
+
+func (t *IdentityToken) getTimesampClaim(claimKey string) (int64, error) {
+
+	var (
+		err error
+		ok  bool
+
+		value   interface{}
+		ts      string
+		tsValue int64
+	)
+
+	if value, _ = t.jwtToken.Get(claimKey); value == nil {
+		return 0, nil
+	}
+	if n, isNumber := value.(float64); isNumber {
+		return int64(n), nil
+	}
+	if ts, ok = value.(string); !ok {
+		return 0, fmt.Errorf(
+			"id token claim %s is not the expected type: %T",
+			claimKey, value,
+		)
+	}
+	if tsValue, err = strconv.ParseInt(ts, 10, 64); err != nil {
+		return 0, fmt.Errorf(
+			"unable to parse id token claim %s with value '%s': %s",
+			claimKey, ts, err.Error(),
+		)
+	}
+	return tsValue, nil
+}
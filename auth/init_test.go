@@ -0,0 +1,20 @@
+package auth_test
+
+import (
+	"testing"
+
+	"github.com/mevansam/goutils/logger"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestAuth(t *testing.T) {
+	logger.Initialize()
+
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "auth")
+}
+
+var _ = AfterSuite(func() {
+})
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/mevansam/goutils/logger"
+)
+
+// the minimum interval between re-fetches of a key
+// set for a key id that was not found in it. this
+// stops tokens with unknown key ids from causing a
+// request to the identity provider each time they
+// are validated.
+var KeySetRefreshInterval = time.Minute
+
+// maximum number of key ids not found in key sets
+// that are remembered. tokens with random key ids
+// would otherwise grow the cache without bound.
+const maxKeySetMisses = 1024
+
+// signing key sets cached by their URL. a set
+// is only re-fetched when a token is signed with
+// a key which is not in the cached set, which is
+// the case when the provider rotates its keys.
+type keySetCache struct {
+	sets map[string]jwk.Set
+
+	// time when a key id was last not found
+	// in the re-fetched key set of a URL
+	misses map[string]time.Time
+
+	mx sync.Mutex
+}
+
+var keySets = &keySetCache{
+	sets:   make(map[string]jwk.Set),
+	misses: make(map[string]time.Time),
+}
+
+// returns the key set at the given URL. the set is
+// fetched if it is not cached or if it does not
+// contain the key with the given id.
+func (c *keySetCache) get(ctx context.Context, url, keyID string) (jwk.Set, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	var (
+		err error
+
+		set jwk.Set
+	)
+
+	set, cached := c.sets[url]
+	if cached {
+		if _, found := set.LookupKeyID(keyID); found || len(keyID) == 0 {
+			return set, nil
+		}
+		if missedAt, missed := c.misses[url+"#"+keyID]; missed && time.Since(missedAt) < KeySetRefreshInterval {
+			return set, nil
+		}
+		logger.DebugMessage("keySetCache.get(): Key '%s' not found in cached key set. Refreshing key set from %s.", keyID, url)
+	}
+
+	if set, err = jwk.Fetch(ctx, url); err != nil {
+		logger.ErrorMessage("keySetCache.get(): Failed to fetch key set from %s: %s", url, err.Error())
+		return nil, err
+	}
+	if _, found := set.LookupKeyID(keyID); !found && len(keyID) > 0 {
+		c.addMiss(url + "#" + keyID)
+	}
+	c.sets[url] = set
+	return set, nil
+}
+
+// records a key id that was not found in a key set.
+// misses older than the refresh interval no longer
+// stop a re-fetch so they are evicted. if the cache
+// is still full the oldest miss is evicted.
+func (c *keySetCache) addMiss(key string) {
+
+	var (
+		oldestKey string
+		oldestAt  time.Time
+	)
+
+	now := time.Now()
+	if len(c.misses) >= maxKeySetMisses {
+		for k, missedAt := range c.misses {
+			if now.Sub(missedAt) >= KeySetRefreshInterval {
+				delete(c.misses, k)
+			} else if len(oldestKey) == 0 || missedAt.Before(oldestAt) {
+				oldestKey, oldestAt = k, missedAt
+			}
+		}
+		if len(c.misses) >= maxKeySetMisses {
+			delete(c.misses, oldestKey)
+		}
+	}
+	c.misses[key] = now
+}
@@ -0,0 +1,346 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/oauth2"
+
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/appbricks/mycloudspace-client/api"
+	"github.com/appbricks/mycloudspace-client/audit"
+	"github.com/appbricks/mycloudspace-client/mycscloud"
+	"github.com/appbricks/mycloudspace-client/ui"
+	"github.com/mevansam/goutils/logger"
+)
+
+// environment variables from which the credentials
+// and answers of a machine login are read. secrets
+// may also be read from the file named by the
+// variable with the "_FILE" suffix.
+const (
+	EnvMachineClientID      = "MYCS_CLIENT_ID"
+	EnvMachineClientSecret  = "MYCS_CLIENT_SECRET"
+	EnvMachineRefreshToken  = "MYCS_REFRESH_TOKEN"
+	EnvMachineRequestAccess = "MYCS_REQUEST_DEVICE_ACCESS"
+	EnvMachineOwnerKeyFile  = "MYCS_OWNER_KEY_FILE"
+	EnvMachineOwnerKeyPass  = "MYCS_OWNER_KEY_PASSPHRASE"
+)
+
+var ErrNoMachineCredentials = errors.New("no machine login credentials configured")
+
+// credentials used to login without user
+// interaction. a pre-issued refresh token is
+// exchanged for the machine user's tokens. the
+// client credentials grant is not supported as
+// identity providers do not issue an id token
+// identifying a MyCS user for it.
+type MachineCredentials struct {
+	// overrides the client of the service config
+	// if set. the secret is needed if the refresh
+	// token was issued to a confidential client.
+	ClientID,
+	ClientSecret string
+
+	RefreshToken string
+}
+
+// answers given to the prompts shown
+// when authorizing the device and user
+type MachineAnswers struct {
+	// request access to the device if
+	// the user has not been granted it
+	RequestDeviceAccess bool
+
+	// the owner's key file and its passphrase
+	// which are needed if the machine user is
+	// the device owner and the device does
+	// not have the owner's key
+	OwnerKeyFile,
+	OwnerKeyPassphrase string
+}
+
+// returns the machine login credentials set in the
+// environment or ErrNoMachineCredentials if none
+// have been set
+func MachineCredentialsFromEnv() (*MachineCredentials, error) {
+
+	var (
+		err error
+
+		credentials MachineCredentials
+	)
+
+	credentials.ClientID = os.Getenv(EnvMachineClientID)
+	if credentials.ClientSecret, err = secretFromEnv(EnvMachineClientSecret); err != nil {
+		return nil, err
+	}
+	if credentials.RefreshToken, err = secretFromEnv(EnvMachineRefreshToken); err != nil {
+		return nil, err
+	}
+	if len(credentials.RefreshToken) == 0 {
+		return nil, ErrNoMachineCredentials
+	}
+	return &credentials, nil
+}
+
+// returns the answers to the device
+// authorization prompts set in the
+// environment
+func MachineAnswersFromEnv() (*MachineAnswers, error) {
+
+	var (
+		err error
+
+		answers MachineAnswers
+	)
+
+	if requestAccess := os.Getenv(EnvMachineRequestAccess); len(requestAccess) > 0 {
+		if answers.RequestDeviceAccess, err = strconv.ParseBool(requestAccess); err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %s", EnvMachineRequestAccess, requestAccess)
+		}
+	}
+	answers.OwnerKeyFile = os.Getenv(EnvMachineOwnerKeyFile)
+	if answers.OwnerKeyPassphrase, err = secretFromEnv(EnvMachineOwnerKeyPass); err != nil {
+		return nil, err
+	}
+	return &answers, nil
+}
+
+// returns the value of the given environment
+// variable or the contents of the file named
+// by the variable with a "_FILE" suffix
+func secretFromEnv(name string) (string, error) {
+
+	if value := os.Getenv(name); len(value) > 0 {
+		return value, nil
+	}
+	if fileName := os.Getenv(name + "_FILE"); len(fileName) > 0 {
+		data, err := os.ReadFile(fileName)
+		if err != nil {
+			return "", fmt.Errorf("unable to read %s from %s: %s", name, fileName, err.Error())
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return "", nil
+}
+
+// logs in to the given config without user interaction
+// using the given credentials and authorizes the device
+// and user. the prompts shown during authorization are
+// answered with the given answers.
+func MachineLogin(
+	ctx context.Context,
+	serviceConfig api.ServiceConfig,
+	appConfig config.Config,
+	credentials *MachineCredentials,
+	answers *MachineAnswers,
+) error {
+
+	var (
+		err error
+
+		token   *oauth2.Token
+		awsAuth *IdentityToken
+	)
+
+	if credentials == nil || len(credentials.RefreshToken) == 0 {
+		return ErrNoMachineCredentials
+	}
+	if answers == nil {
+		answers = &MachineAnswers{}
+	}
+	if len(credentials.ClientID) > 0 {
+		serviceConfig.CliendID = credentials.ClientID
+		serviceConfig.ClientSecret = credentials.ClientSecret
+	}
+	if serviceConfig, err = ResolveServiceConfig(ctx, serviceConfig); err != nil {
+		return err
+	}
+
+	authContext := appConfig.AuthContext()
+	defer func() {
+		if err != nil {
+			if resetErr := authContext.Reset(); resetErr != nil {
+				logger.ErrorMessage(
+					"MachineLogin(): Failed to reset auth context as login failed: %s",
+					resetErr.Error(),
+				)
+			}
+		}
+		audit.Record(
+			appConfig, audit.EventLogin, appConfig.DeviceContext().GetLoggedInUserName(), err,
+			map[string]string{"method": "machine", "clientID": serviceConfig.CliendID},
+		)
+	}()
+
+	if token, err = machineToken(ctx, serviceConfig, credentials); err != nil {
+		logger.ErrorMessage("MachineLogin(): Failed to retrieve token: %s", err.Error())
+		return err
+	}
+	if len(api.IDToken(token)) == 0 {
+		err = fmt.Errorf("identity provider did not return an id token for the machine credentials")
+		return err
+	}
+	if err = authContext.Reset(); err != nil {
+		return err
+	}
+	authContext.SetToken(token)
+
+	if awsAuth, err = newServiceIdentityToken(serviceConfig, authContext); err != nil {
+		return err
+	}
+	cloudAPI := mycscloud.NewCloudAPI(api.NewGraphQLClientForService(ctx, serviceConfig, authContext))
+	if err = cloudAPI.UpdatePropertiesWithContext(ctx, authContext); err != nil {
+		return err
+	}
+	if tokenStore, storeErr := NewTokenStoreForConfig(appConfig); storeErr == nil {
+		saveToken(tokenStore, authContext)
+	} else {
+		logger.DebugMessage("MachineLogin(): Token will not be stored separately from the config: %s", storeErr.Error())
+	}
+	if err = setLoggedInUser(appConfig, awsAuth); err != nil {
+		return err
+	}
+	if err = AuthorizeDeviceAndUser(serviceConfig, appConfig, &machineUI{answers: answers}); err != nil {
+		return err
+	}
+	if authContext.GetToken() == nil {
+		// access to the device has been requested
+		// and the machine user needs to login
+		// again once the request is approved
+		err = mycscloud.ErrAccessPending
+		return err
+	}
+	saveProfile(appConfig)
+	return nil
+}
+
+// retrieves a token for the given
+// machine credentials
+func machineToken(
+	ctx context.Context,
+	serviceConfig api.ServiceConfig,
+	credentials *MachineCredentials,
+) (*oauth2.Token, error) {
+
+	oauthConfig := serviceConfig.OAuthConfig()
+
+	// a token with only the refresh token
+	// set is always refreshed by the source
+	return oauthConfig.TokenSource(
+		ctx,
+		&oauth2.Token{RefreshToken: credentials.RefreshToken},
+	).Token()
+}
+
+// ui which logs all messages and answers
+// the prompts shown when authorizing the
+// device and user non-interactively
+type machineUI struct {
+	answers *MachineAnswers
+}
+
+type machineUIMessage struct {
+	title   string
+	answers *MachineAnswers
+}
+
+type machineProgressMessage struct{}
+
+func (u *machineUI) NewUIMessage(title string) ui.Message {
+	return &machineUIMessage{title: title, answers: u.answers}
+}
+
+func (u *machineUI) NewUIMessageWithCancel(title string, cancel context.CancelFunc) ui.Message {
+	return u.NewUIMessage(title)
+}
+
+func (u *machineUI) ShowErrorMessage(message string) {
+	logger.ErrorMessage("MachineLogin(): %s", message)
+}
+
+func (u *machineUI) ShowInfoMessage(title, message string) {
+	logger.DebugMessage("MachineLogin(): %s: %s", title, message)
+}
+
+func (u *machineUI) ShowNoteMessage(title, message string) {
+	logger.DebugMessage("MachineLogin(): %s: %s", title, message)
+}
+
+func (u *machineUI) ShowNoticeMessage(title, message string) {
+	logger.WarnMessage("MachineLogin(): %s: %s", title, message)
+}
+
+func (m *machineUIMessage) WriteMessage(message string)        { m.log(message) }
+func (m *machineUIMessage) WriteCommentMessage(message string) { m.log(message) }
+func (m *machineUIMessage) WriteInfoMessage(message string)    { m.log(message) }
+func (m *machineUIMessage) WriteNoteMessage(message string)    { m.log(message) }
+func (m *machineUIMessage) WriteNoticeMessage(message string)  { m.log(message) }
+func (m *machineUIMessage) WriteText(text string)              { m.log(text) }
+
+func (m *machineUIMessage) WriteErrorMessage(message string) {
+	logger.ErrorMessage("MachineLogin(): %s: %s", m.title, message)
+}
+
+func (m *machineUIMessage) WriteDangerMessage(message string) {
+	logger.WarnMessage("MachineLogin(): %s: %s", m.title, message)
+}
+
+func (m *machineUIMessage) WriteFatalMessage(message string) {
+	logger.ErrorMessage("MachineLogin(): %s: %s", m.title, message)
+}
+
+func (m *machineUIMessage) log(message string) {
+	logger.DebugMessage("MachineLogin(): %s: %s", m.title, message)
+}
+
+// prompts are answered asynchronously as
+// the caller may block until it receives
+// the answer. prompts without an answer
+// are answered with no input.
+
+func (m *machineUIMessage) ShowMessageWithInput(defaultInput string, handleInput func(*string)) {
+	go handleInput(nil)
+}
+
+func (m *machineUIMessage) ShowMessageWithSecureInput(handleInput func(*string)) {
+	if m.title == ownerKeyPassphrasePrompt && len(m.answers.OwnerKeyFile) > 0 {
+		passphrase := m.answers.OwnerKeyPassphrase
+		go handleInput(&passphrase)
+		return
+	}
+	go handleInput(nil)
+}
+
+func (m *machineUIMessage) ShowMessageWithSecureVerifiedInput(handleInput func(*string)) {
+	go handleInput(nil)
+}
+
+func (m *machineUIMessage) ShowMessageWithYesNoInput(handleInput func(bool)) {
+	go handleInput(m.title == deviceAccessPrompt && m.answers.RequestDeviceAccess)
+}
+
+func (m *machineUIMessage) ShowMessageWithFileInput(handleInput func(*string)) {
+	if m.title == ownerKeyFilePrompt && len(m.answers.OwnerKeyFile) > 0 {
+		keyFile := m.answers.OwnerKeyFile
+		go handleInput(&keyFile)
+		return
+	}
+	go handleInput(nil)
+}
+
+func (m *machineUIMessage) DismissMessage() {}
+
+func (m *machineUIMessage) ShowMessageWithProgressIndicator(startMsg, progressMsg, endMsg string, doneAt int) ui.ProgressMessage {
+	m.log(startMsg)
+	return &machineProgressMessage{}
+}
+
+func (p *machineProgressMessage) Start()                                  {}
+func (p *machineProgressMessage) Update(updateMsg string, progressAt int) {}
+func (p *machineProgressMessage) Done()                                   {}
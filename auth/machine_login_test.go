@@ -0,0 +1,234 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/appbricks/mycloudspace-client/api"
+	"github.com/appbricks/mycloudspace-client/auth"
+	"github.com/appbricks/mycloudspace-client/mycscloud"
+
+	mycs_mocks "github.com/appbricks/mycloudspace-client/test/mocks"
+	test_server "github.com/mevansam/goutils/test/mocks"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Machine Login", func() {
+
+	var (
+		err error
+
+		oidcServer *mycs_mocks.MockOIDCServer
+		apiServer  *test_server.MockHttpServer
+		appConfig  *sessionConfig
+
+		serviceConfig api.ServiceConfig
+
+		configDir string
+	)
+
+	BeforeEach(func() {
+		oidcServer, err = mycs_mocks.NewMockOIDCServer()
+		Expect(err).ToNot(HaveOccurred())
+		oidcServer.SetUserClaims(map[string]interface{}{
+			"sub":                "eb018175-a0cd-4472-809f-a635afb03b16",
+			"preferred_username": "build-agent",
+		})
+		oidcServer.Start()
+
+		apiServer = test_server.NewMockHttpServer(9292)
+		apiServer.Start()
+
+		configDir, err = os.MkdirTemp("", "mycs-machine-")
+		Expect(err).ToNot(HaveOccurred())
+
+		deviceContext := config.NewDeviceContext()
+		_, err = deviceContext.NewDevice()
+		Expect(err).ToNot(HaveOccurred())
+		deviceContext.SetDeviceID("zyxw", "1234", "Build Agent")
+		_, err = deviceContext.NewOwnerUser("0000", "owner")
+		Expect(err).ToNot(HaveOccurred())
+
+		appConfig = &sessionConfig{
+			configFile:    filepath.Join(configDir, "config.yml"),
+			authContext:   config.NewAuthContext(),
+			deviceContext: deviceContext,
+		}
+
+		serviceConfig = api.ServiceConfig{
+			CliendID:  "mock client id",
+			IssuerURL: oidcServer.IssuerURL(),
+			ApiURL:    "http://localhost:9292/",
+		}
+	})
+
+	AfterEach(func() {
+		apiServer.Stop()
+		oidcServer.Stop()
+		os.RemoveAll(configDir)
+	})
+
+	It("reads credentials and answers from the environment", func() {
+		defer os.Unsetenv(auth.EnvMachineRefreshToken + "_FILE")
+		defer os.Unsetenv(auth.EnvMachineRequestAccess)
+
+		_, err = auth.MachineCredentialsFromEnv()
+		Expect(err).To(Equal(auth.ErrNoMachineCredentials))
+
+		tokenFile := filepath.Join(configDir, "refresh-token")
+		Expect(os.WriteFile(tokenFile, []byte("mock refresh token\n"), 0600)).To(Succeed())
+		os.Setenv(auth.EnvMachineRefreshToken+"_FILE", tokenFile)
+		os.Setenv(auth.EnvMachineRequestAccess, "true")
+
+		credentials, err := auth.MachineCredentialsFromEnv()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(credentials.RefreshToken).To(Equal("mock refresh token"))
+
+		answers, err := auth.MachineAnswersFromEnv()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(answers.RequestDeviceAccess).To(BeTrue())
+
+		os.Setenv(auth.EnvMachineRequestAccess, "maybe")
+		_, err = auth.MachineAnswersFromEnv()
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("logs in with a refresh token and declines device access", func() {
+		apiServer.PushRequest().
+			ExpectJSONRequest(mycsCloudPropsRequest).
+			RespondWith(mycsCloudPropsResponse)
+		apiServer.PushRequest().
+			ExpectJSONRequest(authDeviceRequest).
+			RespondWith(authDeviceUnauthorizedResponse)
+
+		err = auth.MachineLogin(
+			context.Background(), serviceConfig, appConfig,
+			&auth.MachineCredentials{RefreshToken: "mock refresh token"},
+			&auth.MachineAnswers{RequestDeviceAccess: false},
+		)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("access request declined"))
+		Expect(appConfig.authContext.IsLoggedIn()).To(BeFalse())
+
+		_, exists := appConfig.deviceContext.GetGuestUser("build-agent")
+		Expect(exists).To(BeFalse())
+		Expect(apiServer.Done()).To(BeTrue())
+	})
+
+	It("logs in with a refresh token and requests device access", func() {
+		apiServer.PushRequest().
+			ExpectJSONRequest(mycsCloudPropsRequest).
+			RespondWith(mycsCloudPropsResponse)
+		apiServer.PushRequest().
+			ExpectJSONRequest(authDeviceRequest).
+			RespondWith(authDeviceUnauthorizedResponse)
+		apiServer.PushRequest().
+			ExpectJSONRequest(addBuildAgentRequest).
+			RespondWith(addBuildAgentResponse)
+
+		err = auth.MachineLogin(
+			context.Background(), serviceConfig, appConfig,
+			&auth.MachineCredentials{
+				ClientID:     mycs_mocks.MockMachineClientID,
+				ClientSecret: mycs_mocks.MockMachineClientSecret,
+				RefreshToken: "mock refresh token",
+			},
+			&auth.MachineAnswers{RequestDeviceAccess: true},
+		)
+		// the device cannot be used until
+		// the access request is approved
+		Expect(errors.Is(err, mycscloud.ErrAccessPending)).To(BeTrue())
+		Expect(appConfig.authContext.IsLoggedIn()).To(BeFalse())
+
+		_, exists := appConfig.deviceContext.GetGuestUser("build-agent")
+		Expect(exists).To(BeTrue())
+		Expect(apiServer.Done()).To(BeTrue())
+	})
+
+	It("requires a refresh token", func() {
+		// client credentials alone do not identify
+		// a user as no id token is issued for them
+		err = auth.MachineLogin(
+			context.Background(), serviceConfig, appConfig,
+			&auth.MachineCredentials{
+				ClientID:     mycs_mocks.MockMachineClientID,
+				ClientSecret: mycs_mocks.MockMachineClientSecret,
+			},
+			nil,
+		)
+		Expect(err).To(Equal(auth.ErrNoMachineCredentials))
+		Expect(appConfig.authContext.IsLoggedIn()).To(BeFalse())
+
+		os.Setenv(auth.EnvMachineClientSecret, mycs_mocks.MockMachineClientSecret)
+		defer os.Unsetenv(auth.EnvMachineClientSecret)
+		_, err = auth.MachineCredentialsFromEnv()
+		Expect(err).To(Equal(auth.ErrNoMachineCredentials))
+	})
+
+	It("fails when the token endpoint does not return an id token", func() {
+		oidcServer.OmitIDTokens(true)
+
+		err = auth.MachineLogin(
+			context.Background(), serviceConfig, appConfig,
+			&auth.MachineCredentials{RefreshToken: "mock refresh token"},
+			nil,
+		)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("identity provider did not return an id token for the machine credentials"))
+		Expect(appConfig.authContext.IsLoggedIn()).To(BeFalse())
+		Expect(oidcServer.TokenPolls()).To(Equal(1))
+		Expect(apiServer.Done()).To(BeTrue())
+	})
+
+	It("fails with a revoked refresh token", func() {
+		oidcServer.RevokeRefreshTokens()
+
+		err = auth.MachineLogin(
+			context.Background(), serviceConfig, appConfig,
+			&auth.MachineCredentials{RefreshToken: "mock refresh token"},
+			nil,
+		)
+		Expect(err).To(HaveOccurred())
+		Expect(appConfig.authContext.IsLoggedIn()).To(BeFalse())
+	})
+})
+
+const authDeviceRequest = `{
+	"query": "query ($idKey:String!){authDevice(idKey: $idKey){accessType,device{deviceID,deviceName,deviceType,managedDevices{deviceID,users{deviceUsers{user{userID,userName,firstName,middleName,familyName}}}},users{deviceUsers{user{userID,userName,firstName,middleName,familyName},isOwner,status}}}}}",
+	"variables": {
+		"idKey": "zyxw"
+	}
+}`
+const authDeviceUnauthorizedResponse = `{
+	"data": {},
+	"errors": [
+		{
+			"errorType": "Unauthorized",
+			"message": "user is not authorized to access device"
+		}
+	]
+}`
+const addBuildAgentRequest = `{
+	"query": "mutation ($deviceID:ID!$userID:ID!){addDeviceUser(deviceID: $deviceID, userID: $userID){device{deviceID},user{userID}}}",
+	"variables": {
+		"deviceID": "1234",
+		"userID": ""
+	}
+}`
+const addBuildAgentResponse = `{
+	"data": {
+		"addDeviceUser": {
+			"device": {
+				"deviceID": "1234"
+			},
+			"user": {
+				"userID": "eb018175-a0cd-4472-809f-a635afb03b16"
+			}
+		}
+	}
+}`
@@ -0,0 +1,244 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/appbricks/mycloudspace-client/api"
+	"github.com/mevansam/goutils/logger"
+)
+
+var (
+	ErrInvalidState = errors.New("invalid authentication response state")
+	ErrInvalidNonce = errors.New("invalid id token nonce")
+
+	ErrLoginCancelled = errors.New("login was cancelled")
+)
+
+// an OAuth 2.0 authorization code flow for a
+// public client. the code is exchanged with
+// a PKCE (S256) code verifier in place of a
+// client secret and the callback's state and
+// the id token's nonce are validated.
+type authCodeFlow struct {
+	ctx context.Context
+
+	serviceConfig api.ServiceConfig
+	authContext   config.AuthContext
+	oauthConfig   *oauth2.Config
+
+	state,
+	nonce,
+	verifier string
+
+	server *http.Server
+	done   chan error
+
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+func newAuthCodeFlow(
+	ctx context.Context,
+	serviceConfig api.ServiceConfig,
+	authContext config.AuthContext,
+) (*authCodeFlow, error) {
+
+	var (
+		err error
+	)
+
+	flow := &authCodeFlow{
+		ctx: ctx,
+
+		serviceConfig: serviceConfig,
+		authContext:   authContext,
+		oauthConfig:   serviceConfig.OAuthConfig(),
+
+		verifier: oauth2.GenerateVerifier(),
+		done:     make(chan error, 1),
+		stopped:  make(chan struct{}),
+	}
+	if flow.state, err = randomString(); err != nil {
+		return nil, err
+	}
+	if flow.nonce, err = randomString(); err != nil {
+		return nil, err
+	}
+	return flow, nil
+}
+
+// starts a callback server on the first available
+// port of the given ports and returns the URL the
+// user needs to navigate to in order to login
+func (f *authCodeFlow) start(
+	ports []int,
+	handlers ...func() (string, func(http.ResponseWriter, *http.Request)),
+) (string, error) {
+
+	var (
+		err error
+
+		listener net.Listener
+	)
+
+	for _, port := range ports {
+		if listener, err = net.Listen("tcp", fmt.Sprintf("localhost:%d", port)); err == nil {
+			f.oauthConfig.RedirectURL = fmt.Sprintf("http://localhost:%d/callback", port)
+			break
+		}
+		logger.DebugMessage("authCodeFlow.start(): Unable to listen on port %d: %s", port, err.Error())
+	}
+	if listener == nil {
+		return "", fmt.Errorf("unable to start the authentication callback server: %s", err.Error())
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", f.handleCallback)
+	for _, handler := range handlers {
+		path, handle := handler()
+		mux.HandleFunc(path, handle)
+	}
+	f.server = &http.Server{Handler: mux}
+
+	go func() {
+		if err := f.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.ErrorMessage("authCodeFlow.start(): Authentication callback server failed: %s", err.Error())
+			f.complete(err)
+		}
+	}()
+	go func() {
+		// stop the callback server if
+		// the login is cancelled
+		select {
+		case <-f.ctx.Done():
+			f.complete(f.ctx.Err())
+			f.stop()
+		case <-f.stopped:
+		}
+	}()
+
+	return f.oauthConfig.AuthCodeURL(
+		f.state,
+		oauth2.AccessTypeOffline,
+		oauth2.S256ChallengeOption(f.verifier),
+		oauth2.SetAuthURLParam("nonce", f.nonce),
+	), nil
+}
+
+// waits for the given timeout for the login to complete.
+// returns true if the flow is still waiting for the
+// user to login.
+func (f *authCodeFlow) waitForCompletion(timeout time.Duration) (bool, error) {
+
+	select {
+	case err := <-f.done:
+		f.stop()
+		return false, err
+	case <-time.After(timeout):
+		return true, nil
+	}
+}
+
+func (f *authCodeFlow) stop() {
+	f.stopOnce.Do(func() {
+		close(f.stopped)
+		if f.server != nil {
+			_ = f.server.Close()
+		}
+	})
+}
+
+func (f *authCodeFlow) complete(err error) {
+	select {
+	case f.done <- err:
+	default:
+	}
+}
+
+func (f *authCodeFlow) handleCallback(w http.ResponseWriter, r *http.Request) {
+
+	var (
+		err error
+
+		token   *oauth2.Token
+		idToken *IdentityToken
+		nonce   string
+	)
+
+	query := r.URL.Query()
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(f.state)) != 1 {
+		// responses with an unexpected state are rejected
+		// without ending the flow as they may have not been
+		// initiated by this client
+		logger.ErrorMessage("authCodeFlow.handleCallback(): Authentication response has an invalid state")
+		http.Error(w, ErrInvalidState.Error(), http.StatusBadRequest)
+		return
+	}
+	if errCode := query.Get("error"); len(errCode) > 0 {
+		errDescription := query.Get("error_description")
+		if errCode == "access_denied" {
+			// the user declined to sign in
+			logger.DebugMessage("authCodeFlow.handleCallback(): Authentication was cancelled: %s", errDescription)
+			renderCallbackPage(w, CallbackCancelled, http.StatusOK, errCode)
+			f.complete(ErrLoginCancelled)
+			return
+		}
+		err = fmt.Errorf("authentication failed: %s", errCode)
+		if len(errDescription) > 0 {
+			err = fmt.Errorf("authentication failed: %s: %s", errCode, errDescription)
+		}
+		logger.ErrorMessage("authCodeFlow.handleCallback(): Authentication failed: %s", err.Error())
+		renderCallbackPage(w, CallbackError, http.StatusUnauthorized, errCode)
+		f.complete(err)
+		return
+	}
+	if token, err = f.oauthConfig.Exchange(
+		f.ctx,
+		query.Get("code"),
+		oauth2.VerifierOption(f.verifier),
+	); err != nil {
+		f.fail(w, err)
+		return
+	}
+	if idToken, err = parseIdentityToken(f.ctx, NewIdentityProvider(f.serviceConfig), api.IDToken(token)); err != nil {
+		f.fail(w, err)
+		return
+	}
+	if nonce, err = idToken.Nonce(); err != nil ||
+		subtle.ConstantTimeCompare([]byte(nonce), []byte(f.nonce)) != 1 {
+		f.fail(w, ErrInvalidNonce)
+		return
+	}
+	f.authContext.SetToken(token)
+
+	callBackHandler()(w, r)
+	f.complete(nil)
+}
+
+func (f *authCodeFlow) fail(w http.ResponseWriter, err error) {
+	logger.ErrorMessage("authCodeFlow.handleCallback(): Authentication failed: %s", err.Error())
+	renderCallbackPage(w, CallbackError, http.StatusUnauthorized, callbackErrorCode)
+	f.complete(err)
+}
+
+// returns a random url safe string
+// with 256 bits of entropy
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
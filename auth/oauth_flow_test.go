@@ -0,0 +1,269 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"testing/fstest"
+	"time"
+
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/appbricks/mycloudspace-client/api"
+	"github.com/appbricks/mycloudspace-client/auth"
+
+	mycs_mocks "github.com/appbricks/mycloudspace-client/test/mocks"
+	test_server "github.com/mevansam/goutils/test/mocks"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Authorization Code Flow", func() {
+
+	var (
+		err error
+
+		oidcServer  *mycs_mocks.MockOIDCServer
+		apiServer   *test_server.MockHttpServer
+		authContext config.AuthContext
+		mockUI      *mycs_mocks.MockUI
+
+		serviceConfig api.ServiceConfig
+
+		authURL        chan *url.URL
+		restoreBrowser func()
+	)
+
+	BeforeEach(func() {
+		oidcServer, err = mycs_mocks.NewMockOIDCServer()
+		Expect(err).ToNot(HaveOccurred())
+		oidcServer.SetUserClaims(map[string]interface{}{
+			"sub":                "eb018175-a0cd-4472-809f-a635afb03b16",
+			"preferred_username": "ken",
+		})
+		oidcServer.Start()
+
+		apiServer = test_server.NewMockHttpServer(9291)
+		apiServer.Start()
+
+		authContext = config.NewAuthContext()
+		mockUI = mycs_mocks.NewMockUI()
+
+		// public client without a secret
+		serviceConfig = api.ServiceConfig{
+			CliendID:  "mock client id",
+			IssuerURL: oidcServer.IssuerURL(),
+			ApiURL:    "http://localhost:9291/",
+		}
+
+		// the browser logs in to the mock
+		// provider and follows the redirect
+		// back to the callback server
+		authURL = make(chan *url.URL, 1)
+		restoreBrowser = auth.SetOpenBrowser(func(u string) error {
+			loginURL, err := url.Parse(u)
+			if err != nil {
+				return err
+			}
+			authURL <- loginURL
+			go func() {
+				defer GinkgoRecover()
+				resp, err := http.Get(u)
+				Expect(err).ToNot(HaveOccurred())
+				resp.Body.Close()
+			}()
+			return nil
+		})
+	})
+
+	AfterEach(func() {
+		restoreBrowser()
+		apiServer.Stop()
+		oidcServer.Stop()
+	})
+
+	waitForLogin := func(authRet auth.AsyncAuthRet) error {
+		select {
+		case ret := <-authRet:
+			return ret.Error
+		case <-time.After(10 * time.Second):
+			Fail("timed out waiting for login to complete")
+		}
+		return nil
+	}
+
+	It("logs in a user using PKCE and validates the state and nonce", func() {
+		apiServer.PushRequest().
+			ExpectJSONRequest(mycsCloudPropsRequest).
+			RespondWith(mycsCloudPropsResponse)
+
+		authRet := auth.Authenticate(
+			auth.WithLoginFlow(context.Background(), auth.LoginFlowBrowser),
+			serviceConfig, authContext, mockUI,
+		)
+		Expect(waitForLogin(authRet)).To(Succeed())
+
+		loginURL := <-authURL
+		params := loginURL.Query()
+		Expect(params.Get("code_challenge_method")).To(Equal("S256"))
+		Expect(params.Get("code_challenge")).ToNot(BeEmpty())
+		Expect(params.Get("state")).ToNot(BeEmpty())
+		Expect(params.Get("nonce")).ToNot(BeEmpty())
+		Expect(params.Has("client_secret")).To(BeFalse())
+
+		Expect(authContext.IsLoggedIn()).To(BeTrue())
+		keyID, _ := authContext.GetPublicKey()
+		Expect(keyID).To(Equal("test public key id"))
+	})
+
+	It("rejects a callback with an invalid state", func() {
+		restoreBrowser()
+		restoreBrowser = auth.SetOpenBrowser(func(u string) error {
+			loginURL, err := url.Parse(u)
+			if err != nil {
+				return err
+			}
+			authURL <- loginURL
+			return nil
+		})
+
+		authRet := auth.Authenticate(
+			auth.WithLoginFlow(context.Background(), auth.LoginFlowBrowser),
+			serviceConfig, authContext, mockUI,
+		)
+		loginURL := <-authURL
+
+		callbackURL, err := url.Parse(loginURL.Query().Get("redirect_uri"))
+		Expect(err).ToNot(HaveOccurred())
+		callbackURL.RawQuery = url.Values{
+			"code":  []string{"mock authorization code #1"},
+			"state": []string{"forged state"},
+		}.Encode()
+
+		resp, err := http.Get(callbackURL.String())
+		Expect(err).ToNot(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+
+		// the login is still pending until cancelled
+		mockUI.Messages()[0].Cancel()
+		Expect(errors.Is(waitForLogin(authRet), context.Canceled)).To(BeTrue())
+		Expect(authContext.IsLoggedIn()).To(BeFalse())
+	})
+
+	It("shows the error returned to the callback and the page for a cancelled login", func() {
+		restoreBrowser()
+		restoreBrowser = auth.SetOpenBrowser(func(u string) error {
+			loginURL, err := url.Parse(u)
+			if err != nil {
+				return err
+			}
+			authURL <- loginURL
+			return nil
+		})
+
+		callback := func(loginURL *url.URL, values url.Values) (int, string) {
+			callbackURL, err := url.Parse(loginURL.Query().Get("redirect_uri"))
+			Expect(err).ToNot(HaveOccurred())
+			values.Set("state", loginURL.Query().Get("state"))
+			callbackURL.RawQuery = values.Encode()
+
+			resp, err := http.Get(callbackURL.String())
+			Expect(err).ToNot(HaveOccurred())
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			return resp.StatusCode, string(body)
+		}
+
+		authRet := auth.Authenticate(
+			auth.WithLoginFlow(context.Background(), auth.LoginFlowBrowser),
+			serviceConfig, authContext, mockUI,
+		)
+		status, page := callback(<-authURL, url.Values{
+			"error":             []string{"invalid_scope"},
+			"error_description": []string{"scope <openid> is not allowed"},
+		})
+		Expect(status).To(Equal(http.StatusUnauthorized))
+		Expect(page).To(ContainSubstring("Sign In Failed"))
+		// only the error code is shown
+		Expect(page).To(ContainSubstring("Error code: invalid_scope"))
+		Expect(page).ToNot(ContainSubstring("is not allowed"))
+		// assets are inlined
+		Expect(page).To(ContainSubstring(`src="data:image/png;base64,`))
+		Expect(page).ToNot(ContainSubstring("https://"))
+
+		err = waitForLogin(authRet)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("authentication failed: invalid_scope: scope <openid> is not allowed"))
+
+		authRet = auth.Authenticate(
+			auth.WithLoginFlow(context.Background(), auth.LoginFlowBrowser),
+			serviceConfig, authContext, mockUI,
+		)
+		status, page = callback(<-authURL, url.Values{
+			"error": []string{"access_denied"},
+		})
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(page).To(ContainSubstring("Sign In Cancelled"))
+		Expect(waitForLogin(authRet)).To(Equal(auth.ErrLoginCancelled))
+		Expect(authContext.IsLoggedIn()).To(BeFalse())
+	})
+
+	It("renders the success page from templates that override the defaults", func() {
+		Expect(auth.SetCallbackTemplates(fstest.MapFS{
+			"success.html": {Data: []byte(`{{define "content"}}<p>Welcome to Acme Cloud</p>{{end}}`)},
+			"logo.png":     {Data: []byte("acme logo")},
+		})).To(Succeed())
+		defer func() {
+			Expect(auth.SetCallbackTemplates(nil)).To(Succeed())
+		}()
+
+		pages := make(chan string, 1)
+		restoreBrowser()
+		restoreBrowser = auth.SetOpenBrowser(func(u string) error {
+			go func() {
+				defer GinkgoRecover()
+				resp, err := http.Get(u)
+				Expect(err).ToNot(HaveOccurred())
+				defer resp.Body.Close()
+				body, err := io.ReadAll(resp.Body)
+				Expect(err).ToNot(HaveOccurred())
+				pages <- string(body)
+			}()
+			return nil
+		})
+
+		apiServer.PushRequest().
+			ExpectJSONRequest(mycsCloudPropsRequest).
+			RespondWith(mycsCloudPropsResponse)
+
+		authRet := auth.Authenticate(
+			auth.WithLoginFlow(context.Background(), auth.LoginFlowBrowser),
+			serviceConfig, authContext, mockUI,
+		)
+		Expect(waitForLogin(authRet)).To(Succeed())
+
+		page := <-pages
+		Expect(page).To(ContainSubstring("<title>Authenticated</title>"))
+		Expect(page).To(ContainSubstring("<p>Welcome to Acme Cloud</p>"))
+		Expect(page).To(ContainSubstring(`src="data:image/png;base64,YWNtZSBsb2dv"`))
+
+		Expect(auth.SetCallbackTemplates(fstest.MapFS{
+			"error.html": {Data: []byte(`{{define "content"}}{{.Error`)},
+		})).ToNot(Succeed())
+	})
+
+	It("rejects an id token with an invalid nonce", func() {
+		oidcServer.SetIDTokenNonce("replayed nonce")
+
+		authRet := auth.Authenticate(
+			auth.WithLoginFlow(context.Background(), auth.LoginFlowBrowser),
+			serviceConfig, authContext, mockUI,
+		)
+		Expect(errors.Is(waitForLogin(authRet), auth.ErrInvalidNonce)).To(BeTrue())
+		Expect(authContext.IsLoggedIn()).To(BeFalse())
+	})
+})
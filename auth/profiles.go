@@ -0,0 +1,390 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/appbricks/mycloudspace-client/api"
+	"github.com/appbricks/mycloudspace-client/audit"
+	"github.com/appbricks/mycloudspace-client/internal/securefile"
+	"github.com/appbricks/mycloudspace-client/mycscloud"
+	"github.com/appbricks/mycloudspace-client/ui"
+	"github.com/mevansam/goutils/logger"
+)
+
+var ErrProfileNotFound = errors.New("profile not found")
+
+// a signed in user of a device whose
+// session can be switched to without
+// logging in again
+type Profile struct {
+	UserID,
+	UserName string
+
+	// whether the profile is the session
+	// of the currently logged in user
+	Active bool
+	// expiry of the profile's session token
+	Expiry time.Time
+}
+
+// saves the sessions of the users signed in to a
+// device side by side in a file encrypted with a
+// key derived from the device's private key
+type ProfileStore struct {
+	path string
+	key  []byte
+
+	mx sync.Mutex
+}
+
+type storedProfile struct {
+	UserID string       `json:"user_id"`
+	Token  *storedToken `json:"token"`
+}
+
+// returns the profile store for the
+// given config which is saved alongside
+// the config file
+func NewProfileStoreForConfig(appConfig config.Config) (*ProfileStore, error) {
+	return NewProfileStore(
+		filepath.Join(filepath.Dir(appConfig.GetConfigFile()), "profiles"),
+		appConfig.DeviceContext(),
+	)
+}
+
+func NewProfileStore(path string, deviceContext config.DeviceContext) (*ProfileStore, error) {
+
+	if deviceContext == nil {
+		return nil, fmt.Errorf("a device context is required to store profiles")
+	}
+	key, err := deviceDataKey(deviceContext, "profiles")
+	if err != nil {
+		return nil, err
+	}
+	return &ProfileStore{
+		path: path,
+		key:  key,
+	}, nil
+}
+
+// returns the profiles in the
+// store ordered by user name
+func (s *ProfileStore) List() ([]*Profile, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	profiles, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*Profile, 0, len(profiles))
+	for userName, p := range profiles {
+		list = append(list, &Profile{
+			UserID:   p.UserID,
+			UserName: userName,
+			Expiry:   tokenExpiry(p.Token.token()),
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].UserName < list[j].UserName
+	})
+	return list, nil
+}
+
+// returns the user id and session token of
+// the profile with the given user name
+func (s *ProfileStore) Load(userName string) (string, *oauth2.Token, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	profiles, err := s.read()
+	if err != nil {
+		return "", nil, err
+	}
+	p, exists := profiles[userName]
+	if !exists {
+		return "", nil, ErrProfileNotFound
+	}
+	return p.UserID, p.Token.token(), nil
+}
+
+// saves the session token of the given user
+// replacing the user's existing profile
+func (s *ProfileStore) Save(userID, userName string, token *oauth2.Token) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if token == nil {
+		return fmt.Errorf("no token to save")
+	}
+	profiles, err := s.read()
+	if err != nil {
+		return err
+	}
+	profiles[userName] = &storedProfile{
+		UserID: userID,
+		Token:  newStoredToken(token),
+	}
+	return s.write(profiles)
+}
+
+// removes the profile of the given user
+func (s *ProfileStore) Remove(userName string) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	profiles, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, exists := profiles[userName]; !exists {
+		return ErrProfileNotFound
+	}
+	delete(profiles, userName)
+	return s.write(profiles)
+}
+
+func (s *ProfileStore) read() (map[string]*storedProfile, error) {
+
+	var (
+		err error
+
+		cipherText []byte
+		plainText  []byte
+	)
+
+	profiles := make(map[string]*storedProfile)
+	if cipherText, err = os.ReadFile(s.path); err != nil {
+		if os.IsNotExist(err) {
+			return profiles, nil
+		}
+		return nil, err
+	}
+	if plainText, err = securefile.Open(s.key, cipherText); err != nil {
+		return nil, fmt.Errorf("unable to decrypt saved profiles: %s", err.Error())
+	}
+	if err = json.Unmarshal(plainText, &profiles); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+func (s *ProfileStore) write(profiles map[string]*storedProfile) error {
+
+	var (
+		err error
+
+		plainText  []byte
+		cipherText []byte
+	)
+
+	if len(profiles) == 0 {
+		if err = os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if plainText, err = json.Marshal(profiles); err != nil {
+		return err
+	}
+	if cipherText, err = securefile.Seal(s.key, plainText); err != nil {
+		return err
+	}
+	return securefile.WriteFileAtomic(s.path, cipherText)
+}
+
+// returns the profiles of the users signed in
+// to the device of the given config
+func ListProfiles(appConfig config.Config) ([]*Profile, error) {
+
+	var (
+		err error
+
+		store    *ProfileStore
+		profiles []*Profile
+	)
+
+	if store, err = NewProfileStoreForConfig(appConfig); err != nil {
+		return nil, err
+	}
+	if profiles, err = store.List(); err != nil {
+		return nil, err
+	}
+	loggedInUserName := appConfig.DeviceContext().GetLoggedInUserName()
+	for _, p := range profiles {
+		p.Active = p.UserName == loggedInUserName
+	}
+	return profiles, nil
+}
+
+// switches the logged in user of the given config
+// to the signed in user with the given name. the
+// session of the current user is saved so it can
+// be switched back to. only the user's access to
+// the device is re-authorized so the user does not
+// need to login again unless the profile's session
+// can no longer be refreshed.
+func SwitchProfile(
+	serviceConfig api.ServiceConfig,
+	appConfig config.Config,
+	appUI ui.UI,
+	userName string,
+) error {
+
+	var (
+		err error
+
+		store   *ProfileStore
+		token   *oauth2.Token
+		awsAuth *IdentityToken
+	)
+
+	authContext := appConfig.AuthContext()
+	deviceContext := appConfig.DeviceContext()
+
+	currUserID := deviceContext.GetLoggedInUserID()
+	currUserName := deviceContext.GetLoggedInUserName()
+	if userName == currUserName && authContext.GetToken() != nil {
+		return nil
+	}
+
+	if serviceConfig, err = ResolveServiceConfig(context.Background(), serviceConfig); err != nil {
+		return err
+	}
+	if store, err = NewProfileStoreForConfig(appConfig); err != nil {
+		return err
+	}
+	if _, token, err = store.Load(userName); err != nil {
+		return err
+	}
+
+	// save the current user's session
+	// so it can be switched back to
+	currToken := authContext.GetToken()
+	if currToken != nil && len(currUserName) > 0 {
+		if err = store.Save(currUserID, currUserName, currToken); err != nil {
+			return err
+		}
+	}
+	loginSession.Stop()
+
+	defer func() {
+		audit.Record(appConfig, audit.EventLogin, userName, err, map[string]string{"method": "profile"})
+	}()
+
+	keyID, keyData := authContext.GetPublicKey()
+	setToken := func(token *oauth2.Token) error {
+		if err := authContext.Reset(); err != nil {
+			return err
+		}
+		authContext.SetPublicKey(keyID, keyData)
+		authContext.SetToken(token)
+		return nil
+	}
+	defer func() {
+		if err != nil {
+			logger.ErrorMessage("SwitchProfile(): Failed to switch to user \"%s\": %s", userName, err.Error())
+
+			// restore the current user's session
+			if restoreErr := setToken(currToken); restoreErr != nil {
+				logger.ErrorMessage("SwitchProfile(): Failed to restore session: %s", restoreErr.Error())
+				return
+			}
+			deviceContext.SetLoggedInUser(currUserID, currUserName)
+			if currToken != nil {
+				if sessionErr := loginSession.Start(context.Background(), serviceConfig, appConfig, appUI); sessionErr != nil {
+					logger.ErrorMessage("SwitchProfile(): Session will not be refreshed: %s", sessionErr.Error())
+				}
+			}
+		}
+	}()
+
+	if err = setToken(token); err != nil {
+		return err
+	}
+	// refresh the profile's session
+	// if it is close to expiry
+	if token, err = api.NewAuthTokenSource(context.Background(), serviceConfig, authContext).Token(); err != nil {
+		return err
+	}
+	if awsAuth, err = newServiceIdentityToken(serviceConfig, authContext); err != nil {
+		return err
+	}
+	if err = setLoggedInUser(appConfig, awsAuth); err != nil {
+		return err
+	}
+
+	deviceAPI := mycscloud.NewDeviceAPI(api.NewGraphQLClientForService(context.Background(), serviceConfig, authContext))
+	if cache, cacheErr := mycscloud.NewCacheForConfig(appConfig); cacheErr == nil {
+		deviceAPI.WithCache(cache)
+	}
+	if err = deviceAPI.UpdateDeviceContext(deviceContext); err != nil {
+		return err
+	}
+
+	if tokenStore, storeErr := NewTokenStoreForConfig(appConfig); storeErr == nil {
+		saveToken(tokenStore, authContext)
+	}
+	if err = store.Save(deviceContext.GetLoggedInUserID(), userName, token); err != nil {
+		return err
+	}
+	if sessionErr := loginSession.Start(context.Background(), serviceConfig, appConfig, appUI); sessionErr != nil {
+		logger.ErrorMessage("SwitchProfile(): Session will not be refreshed: %s", sessionErr.Error())
+	}
+	return nil
+}
+
+// removes the profile of the given user. the
+// profile of the logged in user cannot be
+// removed and needs to be logged out.
+func RemoveProfile(appConfig config.Config, userName string) error {
+
+	var (
+		err error
+
+		store *ProfileStore
+	)
+
+	if userName == appConfig.DeviceContext().GetLoggedInUserName() {
+		return fmt.Errorf("the profile of the logged in user \"%s\" cannot be removed", userName)
+	}
+	if store, err = NewProfileStoreForConfig(appConfig); err != nil {
+		return err
+	}
+	return store.Remove(userName)
+}
+
+// saves the session of the logged in
+// user of the given config as a profile
+func saveProfile(appConfig config.Config) {
+
+	var (
+		err error
+
+		store *ProfileStore
+	)
+
+	deviceContext := appConfig.DeviceContext()
+	token := appConfig.AuthContext().GetToken()
+	userName := deviceContext.GetLoggedInUserName()
+	if token == nil || len(userName) == 0 {
+		return
+	}
+	if store, err = NewProfileStoreForConfig(appConfig); err == nil {
+		err = store.Save(deviceContext.GetLoggedInUserID(), userName, token)
+	}
+	if err != nil {
+		logger.ErrorMessage("saveProfile(): Failed to save profile of user \"%s\": %s", userName, err.Error())
+	}
+}
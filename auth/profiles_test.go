@@ -0,0 +1,193 @@
+package auth_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/appbricks/mycloudspace-client/api"
+	"github.com/appbricks/mycloudspace-client/auth"
+	"github.com/appbricks/mycloudspace-client/mycscloud"
+
+	mycs_mocks "github.com/appbricks/mycloudspace-client/test/mocks"
+	test_server "github.com/mevansam/goutils/test/mocks"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Profiles", func() {
+
+	var (
+		err error
+
+		oidcServer *mycs_mocks.MockOIDCServer
+		apiServer  *test_server.MockHttpServer
+		appConfig  *sessionConfig
+		mockUI     *mycs_mocks.MockUI
+
+		serviceConfig api.ServiceConfig
+
+		configDir string
+	)
+
+	BeforeEach(func() {
+		oidcServer, err = mycs_mocks.NewMockOIDCServer()
+		Expect(err).ToNot(HaveOccurred())
+		oidcServer.Start()
+
+		apiServer = test_server.NewMockHttpServer(9293)
+		apiServer.Start()
+
+		configDir, err = os.MkdirTemp("", "mycs-profiles-")
+		Expect(err).ToNot(HaveOccurred())
+
+		deviceContext := config.NewDeviceContext()
+		_, err = deviceContext.NewDevice()
+		Expect(err).ToNot(HaveOccurred())
+		deviceContext.SetDeviceID("zyxw", "1234", "Family Laptop")
+		_, err = deviceContext.NewOwnerUser("0000", "owner")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = deviceContext.NewGuestUser("1111", "alice")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = deviceContext.NewGuestUser("2222", "bob")
+		Expect(err).ToNot(HaveOccurred())
+
+		appConfig = &sessionConfig{
+			configFile:    filepath.Join(configDir, "config.yml"),
+			authContext:   config.NewAuthContext(),
+			deviceContext: deviceContext,
+		}
+		mockUI = mycs_mocks.NewMockUI()
+
+		serviceConfig = api.ServiceConfig{
+			CliendID:  "mock client id",
+			IssuerURL: oidcServer.IssuerURL(),
+			ApiURL:    "http://localhost:9293/",
+		}
+	})
+
+	AfterEach(func() {
+		auth.CurrentSession().Stop()
+		apiServer.Stop()
+		oidcServer.Stop()
+		os.RemoveAll(configDir)
+	})
+
+	newToken := func(userID, userName string) *oauth2.Token {
+		idToken, err := oidcServer.NewIDToken(map[string]interface{}{
+			"sub":                userID,
+			"preferred_username": userName,
+			"aud":                "mock client id",
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		return (&oauth2.Token{
+			AccessToken:  "access token for " + userName,
+			TokenType:    "Bearer",
+			RefreshToken: "mock refresh token",
+			Expiry:       time.Now().Add(time.Hour),
+		}).WithExtra(map[string]interface{}{
+			"id_token": idToken,
+		})
+	}
+
+	It("saves and removes encrypted profiles", func() {
+		store, err := auth.NewProfileStoreForConfig(appConfig)
+		Expect(err).ToNot(HaveOccurred())
+
+		profiles, err := store.List()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(profiles).To(BeEmpty())
+
+		Expect(store.Save("2222", "bob", newToken("2222", "bob"))).To(Succeed())
+		Expect(store.Save("1111", "alice", newToken("1111", "alice"))).To(Succeed())
+
+		profiles, err = store.List()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(profiles)).To(Equal(2))
+		Expect(profiles[0].UserName).To(Equal("alice"))
+		Expect(profiles[0].UserID).To(Equal("1111"))
+		Expect(profiles[1].UserName).To(Equal("bob"))
+		Expect(profiles[1].Expiry.After(time.Now())).To(BeTrue())
+
+		userID, token, err := store.Load("bob")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(userID).To(Equal("2222"))
+		Expect(token.AccessToken).To(Equal("access token for bob"))
+
+		Expect(store.Remove("bob")).To(Succeed())
+		_, _, err = store.Load("bob")
+		Expect(err).To(Equal(auth.ErrProfileNotFound))
+		Expect(store.Remove("bob")).To(Equal(auth.ErrProfileNotFound))
+	})
+
+	It("switches between signed in users without logging in again", func() {
+		// alice is logged in and bob
+		// has signed in previously
+		appConfig.authContext.SetToken(newToken("1111", "alice"))
+		Expect(appConfig.SetLoggedInUser("1111", "alice")).To(Succeed())
+
+		store, err := auth.NewProfileStoreForConfig(appConfig)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(store.Save("2222", "bob", newToken("2222", "bob"))).To(Succeed())
+
+		apiServer.PushRequest().
+			ExpectJSONRequest(authDeviceRequest).
+			RespondWith(authDeviceGuestResponse)
+
+		err = auth.SwitchProfile(serviceConfig, appConfig, mockUI, "bob")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(apiServer.Done()).To(BeTrue())
+		Expect(oidcServer.TokenPolls()).To(Equal(0))
+		Expect(mockUI.Messages()).To(BeEmpty())
+
+		Expect(appConfig.deviceContext.GetLoggedInUserName()).To(Equal("bob"))
+		Expect(appConfig.authContext.GetToken().AccessToken).To(Equal("access token for bob"))
+
+		profiles, err := auth.ListProfiles(appConfig)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(profiles)).To(Equal(2))
+		Expect(profiles[0].UserName).To(Equal("alice"))
+		Expect(profiles[0].Active).To(BeFalse())
+		Expect(profiles[1].UserName).To(Equal("bob"))
+		Expect(profiles[1].Active).To(BeTrue())
+
+		// bob remains logged in if alice's
+		// access to the device has been revoked
+		apiServer.PushRequest().
+			ExpectJSONRequest(authDeviceRequest).
+			RespondWith(authDeviceUnauthorizedResponse)
+
+		err = auth.SwitchProfile(serviceConfig, appConfig, mockUI, "alice")
+		Expect(errors.Is(err, mycscloud.ErrUnauthorized)).To(BeTrue())
+		Expect(apiServer.Done()).To(BeTrue())
+		Expect(appConfig.deviceContext.GetLoggedInUserName()).To(Equal("bob"))
+		Expect(appConfig.authContext.GetToken().AccessToken).To(Equal("access token for bob"))
+
+		Expect(auth.RemoveProfile(appConfig, "bob")).ToNot(Succeed())
+		Expect(auth.RemoveProfile(appConfig, "alice")).To(Succeed())
+
+		profiles, err = auth.ListProfiles(appConfig)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(profiles)).To(Equal(1))
+		Expect(profiles[0].UserName).To(Equal("bob"))
+	})
+
+	It("fails to switch to a user that has not signed in", func() {
+		err = auth.SwitchProfile(serviceConfig, appConfig, mockUI, "carol")
+		Expect(err).To(Equal(auth.ErrProfileNotFound))
+	})
+})
+
+const authDeviceGuestResponse = `{
+	"data": {
+		"authDevice": {
+			"accessType": "guest"
+		}
+	}
+}`
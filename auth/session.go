@@ -0,0 +1,354 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwt"
+	"golang.org/x/oauth2"
+
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/appbricks/mycloudspace-client/api"
+	"github.com/appbricks/mycloudspace-client/ui"
+	"github.com/mevansam/goutils/logger"
+)
+
+// events of a user's login session
+type SessionEvent int
+
+const (
+	// the session's token has been refreshed
+	// or the user has logged in again
+	SessionRefreshed SessionEvent = iota
+	// the session's token has expired and
+	// could not be refreshed
+	SessionExpired
+	// the identity provider has revoked
+	// the session's refresh token
+	SessionRevoked
+)
+
+func (e SessionEvent) String() string {
+	switch e {
+	case SessionRefreshed:
+		return "refreshed"
+	case SessionExpired:
+		return "expired"
+	case SessionRevoked:
+		return "revoked"
+	default:
+		return fmt.Sprintf("SessionEvent(%d)", int(e))
+	}
+}
+
+// handles a session event. the error is the
+// reason a session expired or was revoked.
+type SessionHandler func(event SessionEvent, err error)
+
+const (
+	// session tokens are refreshed this
+	// long before they expire
+	DefaultSessionRefreshLead = 5 * time.Minute
+
+	// interval at which a failed refresh
+	// is retried until the token expires
+	sessionRetryInterval = 30 * time.Second
+)
+
+// keeps the session of a logged in user alive by
+// refreshing the session's token ahead of its
+// expiry. the user is asked to login again only
+// if the token cannot be refreshed.
+type SessionManager struct {
+	refreshLead time.Duration
+
+	subscribers map[int]SessionHandler
+	nextID      int
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mx sync.Mutex
+}
+
+// the session of the user logged in via Login
+var loginSession = NewSessionManager()
+
+// returns the manager of the session
+// of the user logged in via Login
+func CurrentSession() *SessionManager {
+	return loginSession
+}
+
+func NewSessionManager() *SessionManager {
+	return &SessionManager{
+		refreshLead: DefaultSessionRefreshLead,
+		subscribers: make(map[int]SessionHandler),
+	}
+}
+
+// sets how long before a token
+// expires it will be refreshed
+func (m *SessionManager) SetRefreshLead(lead time.Duration) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.refreshLead = lead
+}
+
+// adds a handler which is called with the events
+// of all sessions managed. handlers are called
+// asynchronously. the returned function
+// removes the handler.
+func (m *SessionManager) Subscribe(handler SessionHandler) func() {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	id := m.nextID
+	m.nextID++
+	m.subscribers[id] = handler
+
+	return func() {
+		m.mx.Lock()
+		defer m.mx.Unlock()
+		delete(m.subscribers, id)
+	}
+}
+
+// starts managing the session of the user logged
+// in to the given config. any session currently
+// being managed is stopped. the session is also
+// stopped when the given context is cancelled
+// and the context's login options are used if
+// the user needs to login again. if there is no
+// token, as would be the case when the login only
+// requested access to the device, there is no
+// session to manage and nothing is started.
+func (m *SessionManager) Start(
+	ctx context.Context,
+	serviceConfig api.ServiceConfig,
+	appConfig config.Config,
+	appUI ui.UI,
+) error {
+
+	var (
+		err error
+	)
+
+	m.Stop()
+
+	if appConfig.AuthContext().GetToken() == nil {
+		logger.DebugMessage("SessionManager.Start(): No session will be managed as no user has logged in.")
+		return nil
+	}
+	if serviceConfig, err = ResolveServiceConfig(ctx, serviceConfig); err != nil {
+		return err
+	}
+
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	m.cancel = cancel
+	m.done = make(chan struct{})
+
+	go m.run(ctx, m.done, serviceConfig, appConfig, appUI)
+	return nil
+}
+
+// stops managing the current session and
+// waits for any refresh in progress to end
+func (m *SessionManager) Stop() {
+	m.mx.Lock()
+	cancel, done := m.cancel, m.done
+	m.cancel, m.done = nil, nil
+	m.mx.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+func (m *SessionManager) run(
+	ctx context.Context,
+	done chan struct{},
+	serviceConfig api.ServiceConfig,
+	appConfig config.Config,
+	appUI ui.UI,
+) {
+	defer close(done)
+
+	var (
+		err error
+
+		token *oauth2.Token
+		event SessionEvent
+	)
+
+	authContext := appConfig.AuthContext()
+	tokenStore, _ := NewTokenStoreForConfig(appConfig)
+	tokenSource := api.NewAuthTokenSource(ctx, serviceConfig, authContext)
+
+	for {
+		if token = authContext.GetToken(); token == nil {
+			logger.DebugMessage("SessionManager.run(): Session has ended as the user has logged out.")
+			return
+		}
+		expiry := tokenExpiry(token)
+		if expiry.IsZero() {
+			logger.DebugMessage("SessionManager.run(): Session will not be refreshed as its token does not expire.")
+			return
+		}
+
+		// tokens with a lifetime shorter than the
+		// refresh lead are refreshed half way to
+		// their expiry
+		m.mx.Lock()
+		refreshLead := m.refreshLead
+		m.mx.Unlock()
+		if remaining := time.Until(expiry); refreshLead > remaining/2 {
+			refreshLead = remaining / 2
+		}
+		refreshAt := expiry.Add(-refreshLead)
+
+		if !sleepUntil(ctx, refreshAt) {
+			return
+		}
+
+		if len(token.RefreshToken) == 0 {
+			err = fmt.Errorf("session token cannot be refreshed")
+		} else {
+			_, err = tokenSource.Refresh(token)
+			for err != nil && !isRefreshRejected(err) && time.Now().Before(expiry) {
+				// transient failures such as the identity provider
+				// being unreachable are retried until the token
+				// expires
+				logger.DebugMessage("SessionManager.run(): Session refresh will be retried: %s", err.Error())
+
+				retryAt := time.Now().Add(sessionRetryInterval)
+				if retryAt.After(expiry) {
+					retryAt = expiry
+				}
+				if !sleepUntil(ctx, retryAt) {
+					return
+				}
+				_, err = tokenSource.Refresh(token)
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			saveToken(tokenStore, authContext)
+			m.notify(SessionRefreshed, nil)
+			continue
+		}
+
+		if isRefreshRejected(err) {
+			event = SessionRevoked
+		} else {
+			// the current token remains
+			// valid until it expires
+			if !sleepUntil(ctx, expiry) {
+				return
+			}
+			event = SessionExpired
+		}
+		logger.DebugMessage("SessionManager.run(): Session has been %s: %s", event, err.Error())
+		m.notify(event, err)
+
+		if err = m.login(ctx, event, serviceConfig, appConfig, appUI); err != nil {
+			logger.ErrorMessage("SessionManager.run(): Session has ended as login failed: %s", err.Error())
+			return
+		}
+		m.notify(SessionRefreshed, nil)
+	}
+}
+
+// asks the user to login again when
+// the session cannot be refreshed
+func (m *SessionManager) login(
+	ctx context.Context,
+	event SessionEvent,
+	serviceConfig api.ServiceConfig,
+	appConfig config.Config,
+	appUI ui.UI,
+) error {
+
+	message := "Your session has expired. Please login again to continue using My Cloud Space."
+	if event == SessionRevoked {
+		message = "Your session is no longer valid. Please login again to continue using My Cloud Space."
+	}
+
+	tokenRet := GetAuthenticatedToken(ctx, serviceConfig, appConfig, true, appUI, message)
+
+	select {
+	case token := <-tokenRet:
+		close(tokenRet)
+		return token.Error
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *SessionManager) notify(event SessionEvent, err error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	for _, handler := range m.subscribers {
+		go handler(event, err)
+	}
+}
+
+// returns the time the given token expires. if the
+// token does not have an expiry time the expiry of
+// its id token is returned.
+func tokenExpiry(token *oauth2.Token) time.Time {
+
+	if !token.Expiry.IsZero() {
+		return token.Expiry
+	}
+	// the id token has already been verified
+	// so it is parsed without verification
+	// only to read its expiry time
+	if idToken, err := jwt.ParseString(api.IDToken(token)); err == nil {
+		return idToken.Expiration()
+	}
+	return time.Time{}
+}
+
+// returns whether a refresh was rejected by the
+// identity provider as opposed to having failed
+// because the provider could not be reached
+func isRefreshRejected(err error) bool {
+
+	var retrieveErr *oauth2.RetrieveError
+	if !errors.As(err, &retrieveErr) {
+		return false
+	}
+	if retrieveErr.ErrorCode == "invalid_grant" {
+		return true
+	}
+	return retrieveErr.Response != nil &&
+		(retrieveErr.Response.StatusCode == http.StatusBadRequest ||
+			retrieveErr.Response.StatusCode == http.StatusUnauthorized)
+}
+
+// sleeps until the given time and returns true
+// or returns false if the context is cancelled
+func sleepUntil(ctx context.Context, t time.Time) bool {
+
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
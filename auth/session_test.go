@@ -0,0 +1,227 @@
+package auth_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/appbricks/mycloudspace-client/api"
+	"github.com/appbricks/mycloudspace-client/auth"
+
+	mycs_mocks "github.com/appbricks/mycloudspace-client/test/mocks"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// config whose token store is
+// saved to a temporary directory
+type sessionConfig struct {
+	config.Config
+
+	configFile    string
+	authContext   config.AuthContext
+	deviceContext config.DeviceContext
+}
+
+func (c *sessionConfig) GetConfigFile() string {
+	return c.configFile
+}
+
+func (c *sessionConfig) AuthContext() config.AuthContext {
+	return c.authContext
+}
+
+func (c *sessionConfig) DeviceContext() config.DeviceContext {
+	return c.deviceContext
+}
+
+func (c *sessionConfig) SetLoggedInUser(userID, userName string) error {
+	c.deviceContext.SetLoggedInUser(userID, userName)
+	return nil
+}
+
+type sessionEvent struct {
+	event auth.SessionEvent
+	err   error
+}
+
+var _ = Describe("Session Manager", func() {
+
+	var (
+		err error
+
+		oidcServer *mycs_mocks.MockOIDCServer
+		appConfig  *sessionConfig
+		mockUI     *mycs_mocks.MockUI
+
+		serviceConfig api.ServiceConfig
+
+		session     *auth.SessionManager
+		events      chan sessionEvent
+		unsubscribe func()
+
+		configDir string
+	)
+
+	BeforeEach(func() {
+		oidcServer, err = mycs_mocks.NewMockOIDCServer()
+		Expect(err).ToNot(HaveOccurred())
+		oidcServer.SetUserClaims(map[string]interface{}{
+			"sub":                "eb018175-a0cd-4472-809f-a635afb03b16",
+			"preferred_username": "ken",
+		})
+		oidcServer.SetTokenLifetime(4 * time.Second)
+		oidcServer.Start()
+
+		configDir, err = os.MkdirTemp("", "mycs-session-")
+		Expect(err).ToNot(HaveOccurred())
+
+		deviceContext := config.NewDeviceContext()
+		_, err = deviceContext.NewDevice()
+		Expect(err).ToNot(HaveOccurred())
+
+		appConfig = &sessionConfig{
+			configFile:    filepath.Join(configDir, "config.yml"),
+			authContext:   config.NewAuthContext(),
+			deviceContext: deviceContext,
+		}
+		mockUI = mycs_mocks.NewMockUI()
+
+		serviceConfig = api.ServiceConfig{
+			CliendID:  "mock client id",
+			IssuerURL: oidcServer.IssuerURL(),
+		}
+
+		session = auth.NewSessionManager()
+		session.SetRefreshLead(time.Second)
+
+		events = make(chan sessionEvent, 10)
+		unsubscribe = session.Subscribe(func(event auth.SessionEvent, err error) {
+			events <- sessionEvent{event, err}
+		})
+	})
+
+	AfterEach(func() {
+		unsubscribe()
+		session.Stop()
+		oidcServer.Stop()
+		os.RemoveAll(configDir)
+	})
+
+	setToken := func(refreshToken string, lifetime time.Duration) {
+		idToken, err := oidcServer.NewIDToken(map[string]interface{}{
+			"sub": "eb018175-a0cd-4472-809f-a635afb03b16",
+			"aud": "mock client id",
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		appConfig.authContext.SetToken(
+			(&oauth2.Token{
+				AccessToken:  "mock access token",
+				TokenType:    "Bearer",
+				RefreshToken: refreshToken,
+				Expiry:       time.Now().Add(lifetime),
+			}).WithExtra(map[string]interface{}{
+				"id_token": idToken,
+			}),
+		)
+	}
+
+	waitForEvent := func(timeout time.Duration) sessionEvent {
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(timeout):
+			Fail("timed out waiting for a session event")
+		}
+		return sessionEvent{}
+	}
+
+	It("refreshes the session token ahead of its expiry", func() {
+		setToken("mock refresh token", 2*time.Second)
+		expiry := appConfig.authContext.GetToken().Expiry
+
+		err = session.Start(context.Background(), serviceConfig, appConfig, mockUI)
+		Expect(err).ToNot(HaveOccurred())
+
+		e := waitForEvent(5 * time.Second)
+		Expect(e.event).To(Equal(auth.SessionRefreshed))
+		Expect(e.err).ToNot(HaveOccurred())
+		Expect(time.Now().Before(expiry)).To(BeTrue())
+
+		token := appConfig.authContext.GetToken()
+		Expect(token.AccessToken).ToNot(Equal("mock access token"))
+		Expect(token.Expiry.After(expiry)).To(BeTrue())
+
+		// the refreshed token is saved to the token store
+		tokenStore, err := auth.NewTokenStoreForConfig(appConfig)
+		Expect(err).ToNot(HaveOccurred())
+		storedToken, err := tokenStore.Load()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(storedToken.AccessToken).To(Equal(token.AccessToken))
+
+		// the session continues to be refreshed
+		e = waitForEvent(5 * time.Second)
+		Expect(e.event).To(Equal(auth.SessionRefreshed))
+		Expect(mockUI.Messages()).To(BeEmpty())
+	})
+
+	It("asks the user to login again when the refresh token has been revoked", func() {
+		oidcServer.RevokeRefreshTokens()
+		setToken("mock refresh token", 2*time.Second)
+
+		err = session.Start(
+			auth.WithLoginFlow(context.Background(), auth.LoginFlowDeviceCode),
+			serviceConfig, appConfig, mockUI,
+		)
+		Expect(err).ToNot(HaveOccurred())
+
+		e := waitForEvent(5 * time.Second)
+		Expect(e.event).To(Equal(auth.SessionRevoked))
+		Expect(e.err).To(HaveOccurred())
+		Expect(mockUI.WaitForText("Your session is no longer valid", 5*time.Second)).To(BeTrue())
+	})
+
+	It("notifies subscribers when a session that cannot be refreshed expires", func() {
+		setToken("", 2*time.Second)
+		expiry := appConfig.authContext.GetToken().Expiry
+
+		err = session.Start(
+			auth.WithLoginFlow(context.Background(), auth.LoginFlowDeviceCode),
+			serviceConfig, appConfig, mockUI,
+		)
+		Expect(err).ToNot(HaveOccurred())
+
+		e := waitForEvent(5 * time.Second)
+		Expect(e.event).To(Equal(auth.SessionExpired))
+		Expect(e.err).To(HaveOccurred())
+		Expect(time.Now().Before(expiry)).To(BeFalse())
+		Expect(mockUI.WaitForText("Your session has expired", 5*time.Second)).To(BeTrue())
+	})
+
+	It("does not manage a session when no user has logged in", func() {
+		// a login that only requested access to
+		// the device does not save a token
+		err = session.Start(context.Background(), serviceConfig, appConfig, mockUI)
+		Expect(err).ToNot(HaveOccurred())
+
+		Consistently(events, 2*time.Second).ShouldNot(Receive())
+		Expect(oidcServer.TokenPolls()).To(Equal(0))
+	})
+
+	It("stops refreshing the session when stopped", func() {
+		setToken("mock refresh token", 2*time.Second)
+
+		err = session.Start(context.Background(), serviceConfig, appConfig, mockUI)
+		Expect(err).ToNot(HaveOccurred())
+		session.Stop()
+
+		Consistently(events, 3*time.Second).ShouldNot(Receive())
+		Expect(oidcServer.TokenPolls()).To(Equal(0))
+	})
+})
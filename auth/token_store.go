@@ -0,0 +1,312 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/appbricks/mycloudspace-client/api"
+	"github.com/appbricks/mycloudspace-client/internal/securefile"
+	"github.com/mevansam/goutils/logger"
+)
+
+var ErrTokenNotFound = errors.New("token not found")
+
+// persists the OAuth tokens of a logged in
+// user separately from the app config so
+// that the session cannot be replayed from
+// a copy of the config file
+type TokenStore interface {
+	// returns the saved token or
+	// ErrTokenNotFound
+	Load() (*oauth2.Token, error)
+	// saves the given token replacing
+	// any previously saved token
+	Save(token *oauth2.Token) error
+	// deletes the saved token
+	Delete() error
+}
+
+// token store which saves the token to a file
+// encrypted with a key derived from the
+// device's private key
+type FileTokenStore struct {
+	path string
+	key  []byte
+
+	mx sync.Mutex
+}
+
+// the token fields persisted by the store. the
+// id token is saved explicitly as the token's
+// extra values are not serialized.
+type storedToken struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"`
+	IDToken      string    `json:"id_token,omitempty"`
+}
+
+// returns a token store for the given
+// config which is saved alongside the
+// config file
+func NewTokenStoreForConfig(appConfig config.Config) (*FileTokenStore, error) {
+	return NewFileTokenStore(
+		filepath.Join(filepath.Dir(appConfig.GetConfigFile()), "token"),
+		appConfig.DeviceContext(),
+	)
+}
+
+// returns a token store which saves the token
+// to the file at the given path encrypted with
+// a key derived from the device's private key
+func NewFileTokenStore(path string, deviceContext config.DeviceContext) (*FileTokenStore, error) {
+
+	if deviceContext == nil {
+		return nil, fmt.Errorf("a device context is required to store tokens")
+	}
+	key, err := deviceDataKey(deviceContext, "token")
+	if err != nil {
+		return nil, err
+	}
+	return &FileTokenStore{
+		path: path,
+		key:  key,
+	}, nil
+}
+
+// returns a key derived from the device's private
+// key for encrypting data for the given purpose
+func deviceDataKey(deviceContext config.DeviceContext, purpose string) ([]byte, error) {
+
+	device := deviceContext.GetDevice()
+	if device == nil || len(device.RSAPrivateKey) == 0 {
+		return nil, fmt.Errorf("device context has not been initialized with a device key")
+	}
+	key := sha256.Sum256([]byte(device.RSAPrivateKey + "|" + purpose))
+	return key[:], nil
+}
+
+func (s *FileTokenStore) Load() (*oauth2.Token, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	var (
+		err error
+
+		cipherText []byte
+		plainText  []byte
+		stored     storedToken
+	)
+
+	if cipherText, err = os.ReadFile(s.path); err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrTokenNotFound
+		}
+		return nil, err
+	}
+	if plainText, err = securefile.Open(s.key, cipherText); err != nil {
+		return nil, fmt.Errorf("unable to decrypt saved token: %s", err.Error())
+	}
+	if err = json.Unmarshal(plainText, &stored); err != nil {
+		return nil, err
+	}
+	return stored.token(), nil
+}
+
+func (s *FileTokenStore) Save(token *oauth2.Token) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	var (
+		err error
+
+		plainText  []byte
+		cipherText []byte
+	)
+
+	if token == nil {
+		return fmt.Errorf("no token to save")
+	}
+	if plainText, err = json.Marshal(newStoredToken(token)); err != nil {
+		return err
+	}
+	if cipherText, err = securefile.Seal(s.key, plainText); err != nil {
+		return err
+	}
+	return securefile.WriteFileAtomic(s.path, cipherText)
+}
+
+func (s *FileTokenStore) Delete() error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func newStoredToken(token *oauth2.Token) *storedToken {
+	return &storedToken{
+		AccessToken:  token.AccessToken,
+		TokenType:    token.TokenType,
+		RefreshToken: token.RefreshToken,
+		Expiry:       token.Expiry,
+		IDToken:      api.IDToken(token),
+	}
+}
+
+func (t *storedToken) token() *oauth2.Token {
+
+	token := &oauth2.Token{
+		AccessToken:  t.AccessToken,
+		TokenType:    t.TokenType,
+		RefreshToken: t.RefreshToken,
+		Expiry:       t.Expiry,
+	}
+	if len(t.IDToken) > 0 {
+		token = token.WithExtra(map[string]interface{}{
+			"id_token": t.IDToken,
+		})
+	}
+	return token
+}
+
+type tokenStoreContextKey struct{}
+
+// returns a context which saves the tokens
+// of users logged in by Authenticate to
+// the given token store
+func WithTokenStore(ctx context.Context, store TokenStore) context.Context {
+	return context.WithValue(ctx, tokenStoreContextKey{}, store)
+}
+
+func tokenStoreFromContext(ctx context.Context) TokenStore {
+	store, _ := ctx.Value(tokenStoreContextKey{}).(TokenStore)
+	return store
+}
+
+// saves the token of the given auth
+// context to the given store if any
+func saveToken(store TokenStore, authContext config.AuthContext) {
+	if store == nil {
+		return
+	}
+	if token := authContext.GetToken(); token != nil {
+		if err := store.Save(token); err != nil {
+			logger.ErrorMessage("saveToken(): Failed to save token: %s", err.Error())
+		}
+	}
+}
+
+// syncs the token of the given config's auth context
+// with the config's token store. the token is
+// restored from the store if the auth context does
+// not have one. if the auth context has a token it
+// is the latest token of the session and replaces
+// the stored token. this also migrates the tokens
+// of configs saved before the token store was used.
+func SyncTokenStore(appConfig config.Config) (TokenStore, error) {
+
+	var (
+		err error
+
+		store *FileTokenStore
+		token *oauth2.Token
+	)
+
+	if store, err = NewTokenStoreForConfig(appConfig); err != nil {
+		return nil, err
+	}
+	authContext := appConfig.AuthContext()
+	if token = authContext.GetToken(); token != nil {
+		if err = store.Save(token); err != nil {
+			return nil, err
+		}
+		return store, nil
+	}
+	if token, err = store.Load(); err != nil {
+		if err == ErrTokenNotFound {
+			return store, nil
+		}
+		return nil, err
+	}
+	authContext.SetToken(token)
+	return store, nil
+}
+
+// saves the given config without the logged in
+// user's token which is saved to the config's
+// token store instead. configs should only be
+// saved via this function as saving a config
+// directly writes the token to the config file.
+func SaveConfig(appConfig config.Config) error {
+
+	var (
+		err error
+	)
+
+	if _, err = SyncTokenStore(appConfig); err != nil {
+		logger.DebugMessage("SaveConfig(): Token will be saved with the config as it cannot be stored separately: %s", err.Error())
+		return appConfig.Save()
+	}
+	return saveConfigWithoutToken(appConfig)
+}
+
+// migrates the token saved with configs prior to
+// the use of a token store. this should be called
+// when a config is loaded. the token is moved to
+// the token store and the config is rewritten
+// without it. the token is kept in the config's
+// auth context so the session can continue.
+func MigrateConfigToken(appConfig config.Config) error {
+
+	var (
+		err error
+	)
+
+	if appConfig.AuthContext().GetToken() == nil {
+		// restore the session from the store
+		_, err = SyncTokenStore(appConfig)
+		return err
+	}
+	if _, err = SyncTokenStore(appConfig); err != nil {
+		return err
+	}
+	logger.DebugMessage("MigrateConfigToken(): Token has been moved to the token store. Removing it from the config.")
+	return saveConfigWithoutToken(appConfig)
+}
+
+// saves the given config with the token removed
+// from its auth context while it is being saved
+func saveConfigWithoutToken(appConfig config.Config) error {
+
+	var (
+		err error
+	)
+
+	authContext := appConfig.AuthContext()
+	token := authContext.GetToken()
+	if token == nil {
+		return appConfig.Save()
+	}
+	keyID, keyData := authContext.GetPublicKey()
+	if err = authContext.Reset(); err != nil {
+		return err
+	}
+	authContext.SetPublicKey(keyID, keyData)
+	defer authContext.SetToken(token)
+
+	return appConfig.Save()
+}
@@ -0,0 +1,156 @@
+package auth_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/appbricks/mycloudspace-client/api"
+	"github.com/appbricks/mycloudspace-client/auth"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// config which writes its auth context's
+// token to its config file when saved
+type fileConfig struct {
+	sessionConfig
+}
+
+func (c *fileConfig) Save() error {
+	data := ""
+	if token := c.authContext.GetToken(); token != nil {
+		data = token.RefreshToken + "\n" + api.IDToken(token)
+	}
+	return os.WriteFile(c.configFile, []byte(data), 0600)
+}
+
+var _ = Describe("Token Store", func() {
+
+	var (
+		err error
+
+		deviceContext config.DeviceContext
+
+		storeDir   string
+		tokenStore *auth.FileTokenStore
+	)
+
+	BeforeEach(func() {
+		deviceContext = config.NewDeviceContext()
+		_, err = deviceContext.NewDevice()
+		Expect(err).ToNot(HaveOccurred())
+
+		storeDir, err = os.MkdirTemp("", "mycs-token-")
+		Expect(err).ToNot(HaveOccurred())
+
+		tokenStore, err = auth.NewFileTokenStore(filepath.Join(storeDir, "token"), deviceContext)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(storeDir)
+	})
+
+	It("saves and loads an encrypted token", func() {
+		_, err = tokenStore.Load()
+		Expect(err).To(Equal(auth.ErrTokenNotFound))
+
+		expiry := time.Now().Add(time.Hour).Round(time.Second)
+		err = tokenStore.Save(
+			(&oauth2.Token{
+				AccessToken:  "mock access token",
+				TokenType:    "Bearer",
+				RefreshToken: "mock refresh token",
+				Expiry:       expiry,
+			}).WithExtra(map[string]interface{}{
+				"id_token": "mock id token",
+			}),
+		)
+		Expect(err).ToNot(HaveOccurred())
+
+		data, err := os.ReadFile(filepath.Join(storeDir, "token"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(strings.Contains(string(data), "mock refresh token")).To(BeFalse())
+
+		token, err := tokenStore.Load()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(token.AccessToken).To(Equal("mock access token"))
+		Expect(token.RefreshToken).To(Equal("mock refresh token"))
+		Expect(token.Expiry.Equal(expiry)).To(BeTrue())
+		Expect(api.IDToken(token)).To(Equal("mock id token"))
+
+		Expect(tokenStore.Delete()).To(Succeed())
+		_, err = tokenStore.Load()
+		Expect(err).To(Equal(auth.ErrTokenNotFound))
+	})
+
+	It("cannot be read with another device's key", func() {
+		err = tokenStore.Save(&oauth2.Token{AccessToken: "mock access token"})
+		Expect(err).ToNot(HaveOccurred())
+
+		otherDeviceContext := config.NewDeviceContext()
+		_, err = otherDeviceContext.NewDevice()
+		Expect(err).ToNot(HaveOccurred())
+
+		otherTokenStore, err := auth.NewFileTokenStore(filepath.Join(storeDir, "token"), otherDeviceContext)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = otherTokenStore.Load()
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("moves a token saved with a config to the token store on load", func() {
+		appConfig := &fileConfig{
+			sessionConfig{
+				configFile:    filepath.Join(storeDir, "config.yml"),
+				authContext:   config.NewAuthContext(),
+				deviceContext: deviceContext,
+			},
+		}
+		appConfig.authContext.SetToken(
+			(&oauth2.Token{
+				AccessToken:  "mock access token",
+				RefreshToken: "mock refresh token",
+			}).WithExtra(map[string]interface{}{
+				"id_token": "mock id token",
+			}),
+		)
+		// config saved prior to the use of a token store
+		Expect(appConfig.Save()).To(Succeed())
+		data, err := os.ReadFile(appConfig.configFile)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(strings.Contains(string(data), "mock refresh token")).To(BeTrue())
+
+		err = auth.MigrateConfigToken(appConfig)
+		Expect(err).ToNot(HaveOccurred())
+
+		data, err = os.ReadFile(appConfig.configFile)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(strings.Contains(string(data), "mock refresh token")).To(BeFalse())
+		Expect(strings.Contains(string(data), "mock id token")).To(BeFalse())
+
+		// session continues with the migrated token
+		Expect(api.IDToken(appConfig.authContext.GetToken())).To(Equal("mock id token"))
+
+		// the config's token store is saved alongside the config file
+		token, err := tokenStore.Load()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(token.RefreshToken).To(Equal("mock refresh token"))
+
+		// loading the config again restores the token from the store
+		appConfig.authContext = config.NewAuthContext()
+		err = auth.MigrateConfigToken(appConfig)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(api.IDToken(appConfig.authContext.GetToken())).To(Equal("mock id token"))
+	})
+
+	It("requires an initialized device", func() {
+		_, err = auth.NewFileTokenStore(filepath.Join(storeDir, "token"), config.NewDeviceContext())
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -12,6 +12,7 @@ import (
 	cb_config "github.com/appbricks/cloud-builder/config"
 	"github.com/appbricks/cloud-builder/userspace"
 	"github.com/appbricks/mycloudspace-client/api"
+	"github.com/appbricks/mycloudspace-client/audit"
 	"github.com/appbricks/mycloudspace-client/auth"
 	"github.com/appbricks/mycloudspace-client/mycscloud"
 	"github.com/appbricks/mycloudspace-client/system"
@@ -84,6 +85,15 @@ func NewConfigInitializer(
 	if err = appConfig.Load(); err != nil {
 		return nil, err
 	}
+	// move the token saved with configs
+	// prior to the use of a token store
+	// out of the config file
+	if err = auth.MigrateConfigToken(appConfig); err != nil {
+		logger.DebugMessage("NewConfigInitializer(): Token could not be moved to the token store: %s", err.Error())
+	}
+	if serviceConfig, err = auth.ResolveServiceConfig(ctx, serviceConfig); err != nil {
+		return nil, err
+	}
 
 	ci := &ConfigInitializer{
 		ctx: ctx,
@@ -101,7 +111,7 @@ func NewConfigInitializer(
 		if !ownerOK || !deviceOK {
 			return nil, fmt.Errorf("device configuration is in an inconsistent state")
 		}
-		ci.currOwnerAPIClient = api.NewGraphQLClient(serviceConfig.ApiURL, "", appConfig.AuthContext())
+		ci.currOwnerAPIClient = api.NewGraphQLClientForService(ctx, serviceConfig, appConfig.AuthContext())
 		ci.resetConfig = false
 		
 	} else {
@@ -153,8 +163,13 @@ func (ci *ConfigInitializer) ResetDeviceOwner(
 			newUserName, 
 			newDeviceName   string
 			userNeedsNewKey bool
+
+			userName     string
+			keyTimestamp int64
 		)
 
+		deviceOwner, ownerIsSet := ci.appConfig.DeviceContext().GetOwnerUserName()
+
 		// always call handler on exit
 		defer func() {
 			if tokenRet != nil {
@@ -166,6 +181,10 @@ func (ci *ConfigInitializer) ResetDeviceOwner(
 			} else {
 				ci.appConfig = newAppConfig
 			}
+			audit.Record(
+				ci.appConfig, audit.EventDeviceOwnerReset, userName, err, 
+				map[string]string{"previousOwner": deviceOwner},
+			)
 			handleAuthResult(
 				newUserName, 
 				newDeviceName,
@@ -184,7 +203,6 @@ func (ci *ConfigInitializer) ResetDeviceOwner(
 			return
 		}
 
-		deviceOwner, ownerIsSet := ci.appConfig.DeviceContext().GetOwnerUserName()
 		if ci.appConfig.Initialized() && ownerIsSet {
 			// confirm device owner by forcing user to re-login
 			confirmInput := make(chan bool, 1)
@@ -213,7 +231,10 @@ func (ci *ConfigInitializer) ResetDeviceOwner(
 			}
 
 			// ensure device reset is done only by the device owner
-			if token.AWSAuth.Username() != deviceOwner {
+			if userName, err = token.AWSAuth.Username(); err != nil {
+				return
+			}
+			if userName != deviceOwner {
 				ci.appUI.ShowErrorMessage("In order to re-initialize a configuration you need to sign in as the current device owner.")
 				err = fmt.Errorf("device owner not logged in")
 				return
@@ -231,7 +252,10 @@ func (ci *ConfigInitializer) ResetDeviceOwner(
 			return
 		}
 
-		if deviceOwner == token.AWSAuth.Username() {
+		if userName, err = token.AWSAuth.Username(); err != nil {
+			return
+		}
+		if deviceOwner == userName {
 			ci.appUI.ShowInfoMessage("Device Owner Reset", fmt.Sprintf("User \"%s\" is already the device owner.", deviceOwner))
 			err = fmt.Errorf("device owner already set")
 			return
@@ -242,12 +266,15 @@ func (ci *ConfigInitializer) ResetDeviceOwner(
 			return
 		}
 
-		userNeedsNewKey = token.AWSAuth.KeyTimestamp() == 0
+		if keyTimestamp, err = token.AWSAuth.KeyTimestamp(); err != nil {
+			return
+		}
+		userNeedsNewKey = keyTimestamp == 0
 	}()
 }
 
 func (ci *ConfigInitializer) resetDeviceOwner(
-	awsAuth *auth.AWSCognitoJWT,
+	awsAuth *auth.IdentityToken,
 	newAppConfig cb_config.Config,
 ) (string, string, error) {
 
@@ -263,17 +290,21 @@ func (ci *ConfigInitializer) resetDeviceOwner(
 	)
 	
 	deviceContext := newAppConfig.DeviceContext()
-	newUserName = awsAuth.Username()
-	userID = awsAuth.UserID()
+	if newUserName, err = awsAuth.Username(); err != nil {
+		return "", "", err
+	}
+	if userID, err = awsAuth.UserID(); err != nil {
+		return "", "", err
+	}
 
 	// create device owner user
 	if owner, err = deviceContext.NewOwnerUser(userID, newUserName); err != nil {
 		return "", "", fmt.Errorf("failed to initialize new device owner: %s", err.Error())	
 	}
 	// retrieve the owner user details
-	ci.newOwnerAPIClient = api.NewGraphQLClient(ci.serviceConfig.ApiURL, "", newAppConfig.AuthContext())
+	ci.newOwnerAPIClient = api.NewGraphQLClientForService(ci.ctx, ci.serviceConfig, newAppConfig.AuthContext())
 	userAPI := mycscloud.NewUserAPI(ci.newOwnerAPIClient)
-	if _, err = userAPI.GetUser(owner); err != nil {
+	if _, err = userAPI.GetUserWithContext(ci.ctx, owner); err != nil {
 		return "", "", fmt.Errorf("failed to retrieve user '%s' from MyCS cloud: %s", owner.Name, err.Error())
 	}
 	// create new device to associate with the owner
@@ -329,6 +360,11 @@ func (ci *ConfigInitializer) LoadDeviceOwnerKey(
 					)
 				}
 			}
+			ownerName, _ := ci.appConfig.DeviceContext().GetOwnerUserName()
+			audit.Record(
+				ci.appConfig, audit.EventOwnerKeyLoad, ownerName, err, 
+				map[string]string{"keyFile": keyFileName, "created": fmt.Sprintf("%t", createKey)},
+			)
 			handleKeyLoadedResult(keyFileName, err)
 		}()
 
@@ -352,6 +388,219 @@ func (ci *ConfigInitializer) LoadDeviceOwnerKey(
 	}()
 }
 
+// replaces the device owner's key with a new key which is
+// saved to the given key file. the owner's universal
+// config is re-encrypted with the new key. if rotation
+// fails the owner's previous key is restored and the new
+// key file is removed.
+func (ci *ConfigInitializer) RotateDeviceOwnerKey(
+	keyFileURL string,
+	handleKeyRotatedResult func(keyFileName string, err error),
+) {
+
+	keyFileName := strings.TrimPrefix(keyFileURL, "file://")
+
+	go func() {
+
+		var (
+			err error
+
+			owner *userspace.User
+		)
+
+		// always call handler on exit
+		defer func() {
+			if err != nil {
+				ci.appUI.ShowErrorMessage(
+					fmt.Sprintf(
+						"Unable to rotate device owner's key: %s", 
+						err.Error(),
+					),
+				)
+			}
+			handleKeyRotatedResult(keyFileName, err)
+		}()
+
+		// the user is prompted for the new key's
+		// passphrase only once it has been verified
+		// that the owner's key can be rotated
+		deviceContext := ci.appConfig.DeviceContext()
+		if !ci.appConfig.Initialized() || ci.currOwnerAPIClient == nil {
+			err = fmt.Errorf("device has not been initialized with an owner")
+			return
+		}
+		if owner = deviceContext.GetOwner(); owner == nil {
+			err = fmt.Errorf("cannot rotate key as device owner not configured")
+			return
+		}
+		if deviceContext.GetLoggedInUserID() != owner.UserID {
+			err = fmt.Errorf("only the device owner '%s' can rotate the owner's key", owner.Name)
+			return
+		}
+
+		keyRet := ci.getPrivateKey(keyFileName, true, ci.appUI)
+		defer close(keyRet)
+		key := <-keyRet
+
+		if err = key.Error; err != nil {
+			return
+		}
+		if key.Key == nil {
+			err = fmt.Errorf("key file not created and no error was returned")
+			return
+		}
+		defer func() {
+			if err != nil {
+				// the new key is discarded
+				if removeErr := os.Remove(keyFileName); removeErr != nil {
+					logger.ErrorMessage(
+						"ConfigInitializer.RotateDeviceOwnerKey(): Failed to remove new key file '%s': %s", 
+						keyFileName, removeErr.Error(),
+					)
+				}
+			}
+		}()
+
+		prevConfigAsOf := ci.appConfig.GetConfigAsOf()
+		userAPI := mycscloud.NewUserAPI(ci.currOwnerAPIClient)
+		err = userAPI.RotateUserKeyWithContext(ci.ctx, owner, key.Key, prevConfigAsOf, 
+			func(configTimestamp int64) error {
+				// persist the new key with the config
+				ci.appConfig.SetConfigAsOf(configTimestamp)
+				if err := auth.SaveConfig(ci.appConfig); err != nil {
+					ci.appConfig.SetConfigAsOf(prevConfigAsOf)
+					return err
+				}
+				return nil
+			},
+		)
+	}()
+}
+
+// splits the device owner's key into the given total
+// number of shares any threshold number of which can
+// be used to recover the key via RecoverDeviceOwnerKey
+func (ci *ConfigInitializer) DeviceOwnerKeyShares(threshold, total int) ([]*KeyShare, error) {
+
+	owner := ci.appConfig.DeviceContext().GetOwner()
+	if owner == nil {
+		return nil, fmt.Errorf("device owner not configured")
+	}
+	if len(owner.RSAPrivateKey) == 0 {
+		return nil, fmt.Errorf("device owner's key has not been loaded")
+	}
+	return SplitKey([]byte(owner.RSAPrivateKey), threshold, total)
+}
+
+// rebuilds the device owner's key from the given shares
+// and sets it as the owner's key. if a key file url is
+// given the recovered key is saved to a new key file
+// encrypted with a passphrase entered by the user.
+func (ci *ConfigInitializer) RecoverDeviceOwnerKey(
+	shares []*KeyShare,
+	keyFileURL string,
+	handleKeyRecoveredResult func(keyFileName string, err error),
+) {
+
+	keyFileName := strings.TrimPrefix(keyFileURL, "file://")
+
+	go func() {
+
+		var (
+			err error
+
+			keyData   []byte
+			key       *crypto.RSAKey
+			publicKey string
+			owner     *userspace.User
+		)
+
+		// always call handler on exit
+		defer func() {
+			if err != nil {
+				ci.appUI.ShowErrorMessage(
+					fmt.Sprintf(
+						"Unable to recover device owner's key: %s", 
+						err.Error(),
+					),
+				)
+			}
+			ownerName, _ := ci.appConfig.DeviceContext().GetOwnerUserName()
+			audit.Record(
+				ci.appConfig, audit.EventOwnerKeyLoad, ownerName, err, 
+				map[string]string{"keyFile": keyFileName, "recoveredFromShares": fmt.Sprintf("%d", len(shares))},
+			)
+			handleKeyRecoveredResult(keyFileName, err)
+		}()
+
+		if owner = ci.appConfig.DeviceContext().GetOwner(); owner == nil {
+			err = fmt.Errorf("cannot set key as device owner not configured")
+			return
+		}
+		if keyData, err = CombineKeyShares(shares); err != nil {
+			return
+		}
+		if key, err = crypto.NewRSAKeyFromPEM(string(keyData), nil); err != nil {
+			err = fmt.Errorf("key shares did not combine to a valid key: %s", err.Error())
+			return
+		}
+		if publicKey, err = key.GetPublicKeyPEM(); err != nil {
+			err = fmt.Errorf("unable to read public key of recovered key: %w", err)
+			return
+		}
+		if len(owner.RSAPublicKey) > 0 && owner.RSAPublicKey != publicKey {
+			err = fmt.Errorf("recovered key is not the key of user '%s'", owner.Name)
+			return
+		}
+		if err = owner.SetKey(key, false); err != nil {
+			err = fmt.Errorf("unable to set recovered key of user '%s': %w", owner.Name, err)
+			return
+		}
+		ci.updateUserKey = true
+
+		if len(keyFileName) > 0 {
+			if err = ci.saveKeyFile(keyFileName, key); err != nil {
+				err = fmt.Errorf("key was recovered but could not be saved: %s", err.Error())
+			}
+		}
+	}()
+}
+
+// saves the given key to a new key file encrypted
+// with a passphrase entered by the user
+func (ci *ConfigInitializer) saveKeyFile(keyFileName string, key *crypto.RSAKey) error {
+
+	var (
+		err error
+
+		keyFilePEM string
+	)
+
+	if _, err = os.Stat(keyFileName); err == nil {
+		return fmt.Errorf("a file already exists at the given path '%s'", keyFileName)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	input := make(chan *string, 1)
+	uh := ci.appUI.NewUIMessage("Key File Passphrase")
+	uh.WriteInfoMessage(
+		"Please enter and verify the key file passphrase. " + 
+		"This will be used to encrypt the private key.",
+	)
+	uh.ShowMessageWithSecureVerifiedInput(func(keyFilePassphrase *string) {
+		input <-keyFilePassphrase
+	})
+	keyFilePassphrase := <-input
+	if keyFilePassphrase == nil {
+		return fmt.Errorf("key file needs a passphrase")
+	}
+	if keyFilePEM, err = key.GetEncryptedPrivateKeyPEM([]byte(*keyFilePassphrase)); err != nil {
+		return err
+	}
+	return os.WriteFile(keyFileName, []byte(keyFilePEM), 0600)
+}
+
 func (ci *ConfigInitializer) getPrivateKey(
 	keyFileName string, 
 	createKey bool, 
@@ -416,7 +665,7 @@ func (ci *ConfigInitializer) getPrivateKey(
 		uh := ui.NewUIMessage("Key File Passphrase")
 		uh.WriteInfoMessage("Enter the passphrase needed to open the key file.")
 		uh.ShowMessageWithSecureInput(func(keyFilePassphrase *string) {
-			if key, err = crypto.NewRSAKeyFromFile(keyFileName, []byte(*keyFilePassphrase)); err != nil {
+			if key, err = loadKeyFile(keyFileName, []byte(*keyFilePassphrase)); err != nil {
 				keyRet <-KeyRet{nil, err}
 			} else {
 				keyRet <-KeyRet{key, nil}
@@ -470,7 +719,7 @@ func (ci *ConfigInitializer) Save(
 			} else {
 				userAPI = mycscloud.NewUserAPI(ci.currOwnerAPIClient)
 			}			
-			if err = userAPI.UpdateUserKey(deviceContext.GetOwner()); err != nil {
+			if err = userAPI.UpdateUserKeyWithContext(ci.ctx, deviceContext.GetOwner()); err != nil {
 				return
 			}
 		}
@@ -481,7 +730,7 @@ func (ci *ConfigInitializer) Save(
 			if ci.currOwnerAPIClient != nil {
 				// unregister this device using the prev owner's API client
 				deviceAPI = mycscloud.NewDeviceAPI(ci.currOwnerAPIClient)
-				if _, err = deviceAPI.UnRegisterDevice(ci.currDeviceID); err != nil {
+				if _, err = deviceAPI.UnRegisterDeviceWithContext(ci.ctx, ci.currDeviceID); err != nil {
 					logger.DebugMessage(
 						"initialize(): Unable to unregister device with ID '%s'. Registration of new device will continue: %s", 
 						ci.currDeviceID, err.Error(),
@@ -494,7 +743,8 @@ func (ci *ConfigInitializer) Save(
 				return
 			}
 			deviceAPI = mycscloud.NewDeviceAPI(ci.newOwnerAPIClient)
-			if deviceIDKey, deviceID, err = deviceAPI.RegisterDevice(
+			if deviceIDKey, deviceID, err = deviceAPI.RegisterDeviceWithContext(
+				ci.ctx,
 				deviceName, 
 				system.GetDeviceType(),
 				system.GetDeviceVersion(clientType, clientVersion),
@@ -511,6 +761,6 @@ func (ci *ConfigInitializer) Save(
 		ci.appConfig.SetKeyTimeout(time.Duration(unlockedTimeout) * time.Minute)
 		ci.appConfig.SetInitialized()
 		
-		err = ci.appConfig.Save()
+		err = auth.SaveConfig(ci.appConfig)
 	}()
 }
\ No newline at end of file
@@ -0,0 +1,373 @@
+package config
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+
+	"github.com/mevansam/goutils/crypto"
+)
+
+const (
+	keyBackupBegin = "-----BEGIN MYCS OWNER KEY BACKUP-----"
+	keyBackupEnd   = "-----END MYCS OWNER KEY BACKUP-----"
+
+	// width of the lines of the backup's encoded
+	// data which is kept short so that the lines
+	// can be easily typed in when restoring
+	keyBackupLineWidth = 48
+
+	// prefix of the payloads of the backup's
+	// QR codes which is followed by the part
+	// number, the key checksum and the part's
+	// encoded data
+	keyBackupQRPrefix = "MYCSKEY1"
+	// size of the encoded data in each QR code
+	// which keeps the codes readable by phone
+	// cameras when printed
+	keyBackupQRChunkSize = 1024
+	// width and height of QR code images
+	keyBackupQRSize = 512
+)
+
+// returns the given encrypted key file as a printable
+// text block. each line of the block has a checksum
+// so that lines transcribed incorrectly when restoring
+// the key can be identified.
+func KeyBackupText(keyFilePEM []byte) (string, error) {
+
+	if err := validateKeyBackupPEM(keyFilePEM); err != nil {
+		return "", err
+	}
+	lines := splitString(base64.StdEncoding.EncodeToString(keyFilePEM), keyBackupLineWidth)
+
+	text := strings.Builder{}
+	text.WriteString(keyBackupBegin)
+	text.WriteString(fmt.Sprintf("\nLines: %d", len(lines)))
+	text.WriteString("\nChecksum: ")
+	text.WriteString(keyBackupChecksum(keyFilePEM))
+	text.WriteString("\n\n")
+	for i, line := range lines {
+		text.WriteString(fmt.Sprintf("%02d %s %s\n", i+1, line, keyBackupLineChecksum(i+1, line)))
+	}
+	text.WriteString(keyBackupEnd)
+	text.WriteByte('\n')
+	return text.String(), nil
+}
+
+// returns the payloads of the QR codes the given
+// encrypted key file is split into. each payload
+// is self describing so that codes can be scanned
+// in any order.
+func KeyBackupQRPayloads(keyFilePEM []byte) ([]string, error) {
+
+	if err := validateKeyBackupPEM(keyFilePEM); err != nil {
+		return nil, err
+	}
+	checksum := keyBackupChecksum(keyFilePEM)
+	chunks := splitString(base64.StdEncoding.EncodeToString(keyFilePEM), keyBackupQRChunkSize)
+
+	payloads := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		payloads[i] = fmt.Sprintf("%s:%d/%d:%s:%s", keyBackupQRPrefix, i+1, len(chunks), checksum, chunk)
+	}
+	return payloads, nil
+}
+
+// returns the QR code PNG images the
+// given encrypted key file is split into
+func KeyBackupQRCodes(keyFilePEM []byte) ([][]byte, error) {
+
+	var (
+		err error
+
+		payloads []string
+	)
+
+	if payloads, err = KeyBackupQRPayloads(keyFilePEM); err != nil {
+		return nil, err
+	}
+	images := make([][]byte, len(payloads))
+	for i, payload := range payloads {
+		if images[i], err = qrcode.Encode(payload, qrcode.Medium, keyBackupQRSize); err != nil {
+			return nil, err
+		}
+	}
+	return images, nil
+}
+
+// writes the paper backup and the QR code images
+// of the given encrypted key file to the given
+// directory and returns the paths of the files
+// written
+func WriteKeyBackup(keyFilePEM []byte, dir string) ([]string, error) {
+
+	var (
+		err error
+
+		text   string
+		images [][]byte
+	)
+
+	if text, err = KeyBackupText(keyFilePEM); err != nil {
+		return nil, err
+	}
+	if images, err = KeyBackupQRCodes(keyFilePEM); err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	name := "mycs-owner-key-backup-" + keyBackupChecksum(keyFilePEM)
+	paths := []string{filepath.Join(dir, name+".txt")}
+	if err = os.WriteFile(paths[0], []byte(text), 0600); err != nil {
+		return nil, err
+	}
+	for i, image := range images {
+		path := filepath.Join(dir, fmt.Sprintf("%s-qr-%d-of-%d.png", name, i+1, len(images)))
+		if err = os.WriteFile(path, image, 0600); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// writes a backup of the encrypted
+// key file at the given path to the
+// given directory
+func ExportKeyFile(keyFileName, dir string) ([]string, error) {
+
+	keyFilePEM, err := os.ReadFile(keyFileName)
+	if err != nil {
+		return nil, err
+	}
+	if isKeyBackup(keyFilePEM) {
+		if keyFilePEM, err = ParseKeyBackup(string(keyFilePEM)); err != nil {
+			return nil, err
+		}
+	}
+	return WriteKeyBackup(keyFilePEM, dir)
+}
+
+// returns the encrypted key file restored from either
+// the text of a paper backup or the payloads of the
+// backup's scanned QR codes one per line
+func ParseKeyBackup(text string) ([]byte, error) {
+
+	if strings.Contains(text, keyBackupBegin) {
+		return parseKeyBackupText(text)
+	}
+	if strings.Contains(text, keyBackupQRPrefix+":") {
+		return parseKeyBackupQRPayloads(text)
+	}
+	return nil, fmt.Errorf("text does not contain a key backup")
+}
+
+// returns the encrypted key file restored
+// from the backup saved to the given file
+func ReadKeyBackupFile(path string) ([]byte, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKeyBackup(string(data))
+}
+
+func parseKeyBackupText(text string) ([]byte, error) {
+
+	var (
+		err error
+
+		numLines int
+		checksum string
+	)
+
+	begin := strings.Index(text, keyBackupBegin)
+	end := strings.Index(text, keyBackupEnd)
+	if begin == -1 || end < begin {
+		return nil, fmt.Errorf("key backup is incomplete")
+	}
+
+	lines := make(map[int]string)
+	scanner := bufio.NewScanner(strings.NewReader(text[begin+len(keyBackupBegin) : end]))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+		if name, value, isHeader := strings.Cut(line, ":"); isHeader {
+			value = strings.TrimSpace(value)
+			switch name {
+			case "Lines":
+				if numLines, err = strconv.Atoi(value); err != nil {
+					return nil, fmt.Errorf("invalid number of key backup lines '%s'", value)
+				}
+			case "Checksum":
+				checksum = value
+			default:
+				return nil, fmt.Errorf("unknown key backup header '%s'", name)
+			}
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid key backup line '%s'", line)
+		}
+		lineNum, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid key backup line number '%s'", fields[0])
+		}
+		if keyBackupLineChecksum(lineNum, fields[1]) != fields[2] {
+			return nil, fmt.Errorf("line %d of the key backup failed its checksum and may have been transcribed incorrectly", lineNum)
+		}
+		lines[lineNum] = fields[1]
+	}
+
+	data := strings.Builder{}
+	for i := 1; i <= numLines; i++ {
+		line, exists := lines[i]
+		if !exists {
+			return nil, fmt.Errorf("line %d of the key backup is missing", i)
+		}
+		data.WriteString(line)
+	}
+	if len(lines) != numLines {
+		return nil, fmt.Errorf("key backup has %d lines but expected %d", len(lines), numLines)
+	}
+	return decodeKeyBackup(data.String(), checksum)
+}
+
+func parseKeyBackupQRPayloads(text string) ([]byte, error) {
+
+	var (
+		err error
+
+		numParts int
+		checksum string
+	)
+
+	parts := make(map[int]string)
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 0, 2*keyBackupQRChunkSize), 4*keyBackupQRChunkSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, keyBackupQRPrefix+":") {
+			continue
+		}
+
+		var partNum, partTotal int
+
+		fields := strings.Split(line, ":")
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("invalid key backup QR code payload")
+		}
+		if _, err = fmt.Sscanf(fields[1], "%d/%d", &partNum, &partTotal); err != nil {
+			return nil, fmt.Errorf("invalid key backup QR code number '%s'", fields[1])
+		}
+		if numParts == 0 {
+			numParts = partTotal
+			checksum = fields[2]
+		} else if partTotal != numParts || fields[2] != checksum {
+			return nil, fmt.Errorf("key backup QR code %d of %d is not from the same backup as the other codes", partNum, partTotal)
+		}
+		parts[partNum] = fields[3]
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	data := strings.Builder{}
+	for i := 1; i <= numParts; i++ {
+		part, exists := parts[i]
+		if !exists {
+			return nil, fmt.Errorf("key backup QR code %d of %d is missing", i, numParts)
+		}
+		data.WriteString(part)
+	}
+	if len(parts) != numParts {
+		return nil, fmt.Errorf("key backup has %d QR codes but expected %d", len(parts), numParts)
+	}
+	return decodeKeyBackup(data.String(), checksum)
+}
+
+func decodeKeyBackup(data, checksum string) ([]byte, error) {
+
+	keyFilePEM, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key backup data: %s", err.Error())
+	}
+	if keyBackupChecksum(keyFilePEM) != checksum {
+		return nil, fmt.Errorf("key backup failed its checksum")
+	}
+	return keyFilePEM, nil
+}
+
+// loads the key from the given file which may
+// either be an encrypted key file or a backup
+// of the file
+func loadKeyFile(keyFileName string, passphrase []byte) (*crypto.RSAKey, error) {
+
+	data, err := os.ReadFile(keyFileName)
+	if err != nil {
+		return nil, err
+	}
+	if !isKeyBackup(data) {
+		return crypto.NewRSAKeyFromFile(keyFileName, passphrase)
+	}
+	if data, err = ParseKeyBackup(string(data)); err != nil {
+		return nil, err
+	}
+	return crypto.NewRSAKeyFromPEM(string(data), passphrase)
+}
+
+func isKeyBackup(data []byte) bool {
+	text := string(data)
+	return strings.Contains(text, keyBackupBegin) || strings.Contains(text, keyBackupQRPrefix+":")
+}
+
+// only encrypted keys are backed up as the backups
+// are meant to be printed and kept offline
+func validateKeyBackupPEM(keyFilePEM []byte) error {
+
+	block, _ := pem.Decode(keyFilePEM)
+	if block == nil {
+		return fmt.Errorf("key file does not contain a PEM encoded key")
+	}
+	if _, isEncrypted := block.Headers["DEK-Info"]; !isEncrypted && block.Type != "ENCRYPTED PRIVATE KEY" {
+		return fmt.Errorf("only encrypted key files can be backed up")
+	}
+	return nil
+}
+
+func keyBackupChecksum(keyFilePEM []byte) string {
+	hash := sha256.Sum256(keyFilePEM)
+	return hex.EncodeToString(hash[:4])
+}
+
+func keyBackupLineChecksum(lineNum int, line string) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%d|%s", lineNum, line)))
+	return hex.EncodeToString(hash[:2])
+}
+
+func splitString(s string, size int) []string {
+
+	chunks := []string{}
+	for len(s) > size {
+		chunks = append(chunks, s[:size])
+		s = s[size:]
+	}
+	return append(chunks, s)
+}
@@ -0,0 +1,135 @@
+package config_test
+
+import (
+	"bytes"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mevansam/goutils/crypto"
+
+	"github.com/appbricks/mycloudspace-client/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Key Backup", func() {
+
+	var (
+		err error
+
+		key        *crypto.RSAKey
+		keyFilePEM []byte
+	)
+
+	BeforeEach(func() {
+		key, err = crypto.NewRSAKey()
+		Expect(err).ToNot(HaveOccurred())
+		keyPEM, err := key.GetEncryptedPrivateKeyPEM([]byte("backup passphrase"))
+		Expect(err).ToNot(HaveOccurred())
+		keyFilePEM = []byte(keyPEM)
+	})
+
+	It("restores a key file from its paper backup", func() {
+		text, err := config.KeyBackupText(keyFilePEM)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(strings.HasPrefix(text, "-----BEGIN MYCS OWNER KEY BACKUP-----\n")).To(BeTrue())
+		Expect(strings.HasSuffix(text, "-----END MYCS OWNER KEY BACKUP-----\n")).To(BeTrue())
+
+		restoredPEM, err := config.ParseKeyBackup(text)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(restoredPEM).To(Equal(keyFilePEM))
+
+		restoredKey, err := crypto.NewRSAKeyFromPEM(string(restoredPEM), []byte("backup passphrase"))
+		Expect(err).ToNot(HaveOccurred())
+		publicKey, err := key.GetPublicKeyPEM()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(restoredKey.GetPublicKeyPEM()).To(Equal(publicKey))
+	})
+
+	It("identifies lines of a paper backup that were transcribed incorrectly", func() {
+		text, err := config.KeyBackupText(keyFilePEM)
+		Expect(err).ToNot(HaveOccurred())
+
+		// swap two characters of the third line
+		lines := strings.Split(text, "\n")
+		line := []byte(lines[6])
+		Expect(string(line[:3])).To(Equal("03 "))
+		for i := 4; i < len(line); i++ {
+			if line[i] != line[3] {
+				line[3], line[i] = line[i], line[3]
+				break
+			}
+		}
+		lines[6] = string(line)
+
+		_, err = config.ParseKeyBackup(strings.Join(lines, "\n"))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(HavePrefix("line 3 of the key backup failed its checksum"))
+
+		// remove the third line
+		lines = strings.Split(text, "\n")
+		_, err = config.ParseKeyBackup(strings.Join(append(lines[:6], lines[7:]...), "\n"))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("line 3 of the key backup is missing"))
+	})
+
+	It("restores a key file from the payloads of its QR codes scanned in any order", func() {
+		payloads, err := config.KeyBackupQRPayloads(keyFilePEM)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(payloads)).To(BeNumerically(">", 1))
+
+		reversed := []string{}
+		for i := len(payloads) - 1; i >= 0; i-- {
+			reversed = append(reversed, payloads[i])
+		}
+		restoredPEM, err := config.ParseKeyBackup(strings.Join(reversed, "\n"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(restoredPEM).To(Equal(keyFilePEM))
+
+		_, err = config.ParseKeyBackup(strings.Join(payloads[1:], "\n"))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(HavePrefix("key backup QR code 1 of"))
+	})
+
+	It("writes a backup of a key file which can be read back", func() {
+		dir, err := os.MkdirTemp("", "mycs-key-backup-")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		keyFileName := filepath.Join(dir, "owner.pem")
+		err = os.WriteFile(keyFileName, keyFilePEM, 0600)
+		Expect(err).ToNot(HaveOccurred())
+
+		paths, err := config.ExportKeyFile(keyFileName, filepath.Join(dir, "backup"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(paths)).To(BeNumerically(">", 2))
+		Expect(paths[0]).To(HaveSuffix(".txt"))
+
+		for _, path := range paths[1:] {
+			Expect(path).To(HaveSuffix(".png"))
+			image, err := os.ReadFile(path)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = png.Decode(bytes.NewReader(image))
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		restoredPEM, err := config.ReadKeyBackupFile(paths[0])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(restoredPEM).To(Equal(keyFilePEM))
+	})
+
+	It("does not back up unencrypted keys", func() {
+		keyPEM, err := key.GetPrivateKeyPEM()
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = config.KeyBackupText([]byte(keyPEM))
+		Expect(err).To(HaveOccurred())
+		_, err = config.KeyBackupQRCodes([]byte(keyPEM))
+		Expect(err).To(HaveOccurred())
+		_, err = config.KeyBackupText([]byte("not a key"))
+		Expect(err).To(HaveOccurred())
+	})
+})
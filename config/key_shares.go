@@ -0,0 +1,313 @@
+package config
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	keyShareBegin = "-----BEGIN MYCS OWNER KEY SHARE-----"
+	keyShareEnd   = "-----END MYCS OWNER KEY SHARE-----"
+
+	// width of the lines of the
+	// share's encoded data
+	keyShareLineWidth = 64
+)
+
+// a share of a key split using Shamir's secret
+// sharing scheme. the key can be rebuilt from any
+// threshold number of shares of the same set.
+type KeyShare struct {
+	// random id which identifies the
+	// shares split from the same key
+	SetID string
+
+	// share number in the range 1..Total
+	// which is also the x coordinate of
+	// the share's points
+	Index,
+	Threshold,
+	Total int
+
+	Data []byte
+}
+
+// splits the given key into the given total number
+// of shares any threshold number of which can be
+// combined to rebuild the key
+func SplitKey(key []byte, threshold, total int) ([]*KeyShare, error) {
+
+	var (
+		err error
+	)
+
+	if len(key) == 0 {
+		return nil, fmt.Errorf("no key to split")
+	}
+	if threshold < 2 || threshold > total || total > 255 {
+		return nil, fmt.Errorf(
+			"invalid key share threshold %d of %d: at least 2 and at most 255 shares are required",
+			threshold, total,
+		)
+	}
+
+	setID := make([]byte, 4)
+	if _, err = rand.Read(setID); err != nil {
+		return nil, err
+	}
+	shares := make([]*KeyShare, total)
+	for i := range shares {
+		shares[i] = &KeyShare{
+			SetID:     hex.EncodeToString(setID),
+			Index:     i + 1,
+			Threshold: threshold,
+			Total:     total,
+			Data:      make([]byte, len(key)),
+		}
+	}
+
+	// each byte of the key is the constant term
+	// of a random polynomial of degree threshold-1
+	// which is evaluated at each share's index
+	coefficients := make([]byte, threshold)
+	for b, secret := range key {
+		coefficients[0] = secret
+		if _, err = rand.Read(coefficients[1:]); err != nil {
+			return nil, err
+		}
+		for _, share := range shares {
+			share.Data[b] = gfEvaluate(coefficients, byte(share.Index))
+		}
+	}
+	return shares, nil
+}
+
+// rebuilds the key split into the given shares.
+// at least the threshold number of shares of
+// the same set are required.
+func CombineKeyShares(shares []*KeyShare) ([]byte, error) {
+
+	if len(shares) == 0 {
+		return nil, fmt.Errorf("no key shares provided")
+	}
+	first := shares[0]
+	indexes := make(map[int]bool)
+	for _, share := range shares {
+		if share.SetID != first.SetID {
+			return nil, fmt.Errorf("key shares %s and %s are not from the same key", first.SetID, share.SetID)
+		}
+		if share.Threshold != first.Threshold || share.Total != first.Total || len(share.Data) != len(first.Data) {
+			return nil, fmt.Errorf("key share #%d of set %s is inconsistent with the other shares", share.Index, share.SetID)
+		}
+		if share.Index < 1 || share.Index > share.Total {
+			return nil, fmt.Errorf("key share #%d of set %s has an invalid index", share.Index, share.SetID)
+		}
+		if indexes[share.Index] {
+			return nil, fmt.Errorf("key share #%d of set %s was provided more than once", share.Index, share.SetID)
+		}
+		indexes[share.Index] = true
+	}
+	if len(shares) < first.Threshold {
+		return nil, fmt.Errorf("%d of %d key shares are required but only %d were provided", first.Threshold, first.Total, len(shares))
+	}
+
+	// interpolate the polynomial of each
+	// byte of the key at x = 0
+	key := make([]byte, len(first.Data))
+	for i, share := range shares {
+		basis := byte(1)
+		for j, other := range shares {
+			if i != j {
+				basis = gfMul(basis, gfDiv(byte(other.Index), byte(other.Index)^byte(share.Index)))
+			}
+		}
+		for b, y := range share.Data {
+			key[b] ^= gfMul(y, basis)
+		}
+	}
+	return key, nil
+}
+
+// returns the share as a printable text block
+// which includes a checksum so that errors made
+// when transcribing the share can be detected
+func (s *KeyShare) String() string {
+
+	text := strings.Builder{}
+	text.WriteString(keyShareBegin)
+	text.WriteString("\nSet: ")
+	text.WriteString(s.SetID)
+	text.WriteString(fmt.Sprintf("\nShare: %d of %d", s.Index, s.Total))
+	text.WriteString(fmt.Sprintf("\nThreshold: %d", s.Threshold))
+	text.WriteString("\nChecksum: ")
+	text.WriteString(s.checksum())
+	text.WriteString("\n\n")
+
+	data := base64.StdEncoding.EncodeToString(s.Data)
+	for len(data) > keyShareLineWidth {
+		text.WriteString(data[:keyShareLineWidth])
+		text.WriteByte('\n')
+		data = data[keyShareLineWidth:]
+	}
+	text.WriteString(data)
+	text.WriteByte('\n')
+	text.WriteString(keyShareEnd)
+	text.WriteByte('\n')
+	return text.String()
+}
+
+func (s *KeyShare) checksum() string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%d|%d|%x", s.SetID, s.Index, s.Threshold, s.Total, s.Data)))
+	return hex.EncodeToString(hash[:4])
+}
+
+// parses a key share from the text
+// block returned by KeyShare.String()
+func ParseKeyShare(text string) (*KeyShare, error) {
+
+	var (
+		err error
+
+		checksum string
+		data     strings.Builder
+	)
+
+	share := &KeyShare{}
+
+	begin := strings.Index(text, keyShareBegin)
+	end := strings.Index(text, keyShareEnd)
+	if begin == -1 || end < begin {
+		return nil, fmt.Errorf("text does not contain a key share")
+	}
+	scanner := bufio.NewScanner(strings.NewReader(text[begin+len(keyShareBegin) : end]))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+		name, value, isHeader := strings.Cut(line, ":")
+		if !isHeader {
+			data.WriteString(line)
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch name {
+		case "Set":
+			share.SetID = value
+		case "Share":
+			if _, err = fmt.Sscanf(value, "%d of %d", &share.Index, &share.Total); err != nil {
+				return nil, fmt.Errorf("invalid key share number '%s'", value)
+			}
+		case "Threshold":
+			if share.Threshold, err = strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("invalid key share threshold '%s'", value)
+			}
+		case "Checksum":
+			checksum = value
+		default:
+			return nil, fmt.Errorf("unknown key share header '%s'", name)
+		}
+	}
+	if share.Data, err = base64.StdEncoding.DecodeString(data.String()); err != nil {
+		return nil, fmt.Errorf("invalid key share data: %s", err.Error())
+	}
+	if checksum != share.checksum() {
+		return nil, fmt.Errorf("key share #%d of set %s failed its checksum and may have been transcribed incorrectly", share.Index, share.SetID)
+	}
+	return share, nil
+}
+
+// reads the key share saved
+// to the file at the given path
+func ReadKeyShareFile(path string) (*KeyShare, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKeyShare(string(data))
+}
+
+// writes each of the given shares to a separate
+// file in the given directory and returns the
+// paths of the files written
+func WriteKeyShareFiles(shares []*KeyShare, dir string) ([]string, error) {
+
+	var (
+		err error
+	)
+
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(shares))
+	for _, share := range shares {
+		path := filepath.Join(dir, fmt.Sprintf("mycs-owner-key-%s-share-%d-of-%d.txt", share.SetID, share.Index, share.Total))
+		if err = os.WriteFile(path, []byte(share.String()), 0600); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// arithmetic in GF(2^8) with the
+// AES reduction polynomial 0x11b
+
+var gfExp, gfLog = func() ([510]byte, [256]byte) {
+
+	var (
+		exp [510]byte
+		log [256]byte
+	)
+
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		exp[i] = x
+		exp[i+255] = x
+		log[x] = byte(i)
+
+		// multiply by the generator 3
+		hi := x & 0x80
+		x2 := x << 1
+		if hi != 0 {
+			x2 ^= 0x1b
+		}
+		x ^= x2
+	}
+	return exp, log
+}()
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+255-int(gfLog[b])]
+}
+
+// evaluates the polynomial with the
+// given coefficients at x
+func gfEvaluate(coefficients []byte, x byte) byte {
+
+	y := byte(0)
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		y = gfMul(y, x) ^ coefficients[i]
+	}
+	return y
+}
@@ -0,0 +1,112 @@
+package config_test
+
+import (
+	"os"
+	"strings"
+
+	"github.com/mevansam/goutils/crypto"
+
+	"github.com/appbricks/mycloudspace-client/config"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Key Shares", func() {
+
+	var (
+		err error
+
+		keyPEM string
+		shares []*config.KeyShare
+	)
+
+	BeforeEach(func() {
+		key, err := crypto.NewRSAKey()
+		Expect(err).ToNot(HaveOccurred())
+		keyPEM, err = key.GetPrivateKeyPEM()
+		Expect(err).ToNot(HaveOccurred())
+
+		shares, err = config.SplitKey([]byte(keyPEM), 3, 5)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(shares)).To(Equal(5))
+	})
+
+	It("rebuilds a key from any threshold number of shares", func() {
+		for _, subset := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}} {
+			selected := []*config.KeyShare{}
+			for _, i := range subset {
+				selected = append(selected, shares[i])
+			}
+			key, err := config.CombineKeyShares(selected)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(key)).To(Equal(keyPEM))
+		}
+
+		_, err = config.CombineKeyShares(shares[:2])
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("3 of 5 key shares are required but only 2 were provided"))
+
+		_, err = config.CombineKeyShares([]*config.KeyShare{shares[0], shares[1], shares[1]})
+		Expect(err).To(HaveOccurred())
+
+		otherShares, err := config.SplitKey([]byte(keyPEM), 3, 5)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = config.CombineKeyShares([]*config.KeyShare{shares[0], shares[1], otherShares[2]})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("encodes shares as text with a checksum", func() {
+		text := shares[1].String()
+		Expect(strings.HasPrefix(text, "-----BEGIN MYCS OWNER KEY SHARE-----\n")).To(BeTrue())
+		Expect(text).To(ContainSubstring("Share: 2 of 5\nThreshold: 3\n"))
+
+		share, err := config.ParseKeyShare("printed on 2021-06-12\n\n" + text)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(share).To(Equal(shares[1]))
+
+		// a transcription error is detected
+		lines := strings.Split(text, "\n")
+		line := []byte(lines[6])
+		if line[0] == 'A' {
+			line[0] = 'B'
+		} else {
+			line[0] = 'A'
+		}
+		lines[6] = string(line)
+		_, err = config.ParseKeyShare(strings.Join(lines, "\n"))
+		Expect(err).To(HaveOccurred())
+
+		_, err = config.ParseKeyShare("not a key share")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("writes and reads share files", func() {
+		dir, err := os.MkdirTemp("", "mycs-key-shares-")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		paths, err := config.WriteKeyShareFiles(shares, dir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(paths)).To(Equal(5))
+
+		selected := []*config.KeyShare{}
+		for _, path := range paths[2:] {
+			share, err := config.ReadKeyShareFile(path)
+			Expect(err).ToNot(HaveOccurred())
+			selected = append(selected, share)
+		}
+		key, err := config.CombineKeyShares(selected)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(key)).To(Equal(keyPEM))
+	})
+
+	It("validates the share threshold", func() {
+		_, err = config.SplitKey([]byte(keyPEM), 1, 5)
+		Expect(err).To(HaveOccurred())
+		_, err = config.SplitKey([]byte(keyPEM), 6, 5)
+		Expect(err).To(HaveOccurred())
+		_, err = config.SplitKey([]byte(keyPEM), 3, 256)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -20,6 +20,7 @@ require (
 	github.com/appbricks/cloud-builder v0.0.4
 	github.com/appbricks/mycloudspace-common v0.0.3
 	github.com/cloudevents/sdk-go/v2 v2.8.0
+	github.com/google/uuid v1.3.0
 	github.com/hasura/go-graphql-client v0.6.3
 	github.com/lestrrat-go/jwx v1.2.19
 	github.com/mevansam/goforms v0.0.2
@@ -27,9 +28,11 @@ require (
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/onsi/ginkgo v1.16.5
 	github.com/onsi/gomega v1.20.1
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	go4.org/netipx v0.0.0-20230303233057-f1b76eb4bb35
 	golang.org/x/oauth2 v0.19.0
 	golang.zx2c4.com/wireguard/wgctrl v0.0.0-20230429144221-925a1e7659e6
+	nhooyr.io/websocket v1.8.7
 	tailscale.com v0.0.0-00010101000000-000000000000
 )
 
@@ -92,7 +95,6 @@ require (
 	github.com/google/go-cmp v0.5.9 // indirect
 	github.com/google/nftables v0.1.0 // indirect
 	github.com/google/s2a-go v0.1.4 // indirect
-	github.com/google/uuid v1.3.0 // indirect
 	github.com/googleapis/enterprise-certificate-proxy v0.2.3 // indirect
 	github.com/googleapis/gax-go/v2 v2.11.0 // indirect
 	github.com/hashicorp/hcl v1.0.0 // indirect
@@ -143,7 +145,6 @@ require (
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/rogpeppe/go-internal v1.8.1 // indirect
 	github.com/sirupsen/logrus v1.8.1 // indirect
-	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e // indirect
 	github.com/spf13/afero v1.8.1 // indirect
 	github.com/spf13/cast v1.4.1 // indirect
 	github.com/spf13/jwalterweatherman v1.1.0 // indirect
@@ -196,6 +197,5 @@ require (
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 	gvisor.dev/gvisor v0.0.0-20221203005347-703fd9b7fbc0 // indirect
 	inet.af/peercred v0.0.0-20210906144145-0893ea02156a // indirect
-	nhooyr.io/websocket v1.8.7 // indirect
 	software.sslmate.com/src/go-pkcs12 v0.2.0 // indirect
 )
@@ -0,0 +1,103 @@
+// package securefile encrypts data saved to the
+// local file system by the client, such as the
+// token store and the offline cache.
+package securefile
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// encrypts the given data with AES-GCM using
+// the given 256 bit key. the random nonce is
+// prepended to the returned cipher text.
+func Seal(key, plainText []byte) ([]byte, error) {
+
+	var (
+		err error
+
+		gcm cipher.AEAD
+	)
+
+	if gcm, err = newGCM(key); err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plainText, nil), nil
+}
+
+// decrypts data encrypted by Seal
+func Open(key, cipherText []byte) ([]byte, error) {
+
+	var (
+		err error
+
+		gcm cipher.AEAD
+	)
+
+	if gcm, err = newGCM(key); err != nil {
+		return nil, err
+	}
+	if len(cipherText) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted data is truncated")
+	}
+	nonce := cipherText[:gcm.NonceSize()]
+	return gcm.Open(nil, nonce, cipherText[gcm.NonceSize():], nil)
+}
+
+// writes the given data to a temporary file
+// which replaces the file at the given path
+// so that a partially written file is
+// never read
+func WriteFileAtomic(path string, data []byte) error {
+
+	var (
+		err error
+
+		tmpFile *os.File
+	)
+
+	dir := filepath.Dir(path)
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if tmpFile, err = os.CreateTemp(dir, filepath.Base(path)+".*"); err != nil {
+		return err
+	}
+	if _, err = tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return err
+	}
+	if err = tmpFile.Close(); err != nil {
+		os.Remove(tmpFile.Name())
+		return err
+	}
+	if err = os.Rename(tmpFile.Name(), path); err != nil {
+		os.Remove(tmpFile.Name())
+		return err
+	}
+	return nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+
+	var (
+		err error
+
+		block cipher.Block
+	)
+
+	if block, err = aes.NewCipher(key); err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,56 @@
+package securefile_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/appbricks/mycloudspace-client/internal/securefile"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Secure File", func() {
+
+	var (
+		key []byte
+	)
+
+	BeforeEach(func() {
+		key = []byte("0123456789abcdef0123456789abcdef")
+	})
+
+	It("seals and opens data", func() {
+		cipherText, err := securefile.Seal(key, []byte("secret data"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(cipherText)).ToNot(ContainSubstring("secret data"))
+
+		plainText, err := securefile.Open(key, cipherText)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(plainText)).To(Equal("secret data"))
+
+		_, err = securefile.Open([]byte("fedcba9876543210fedcba9876543210"), cipherText)
+		Expect(err).To(HaveOccurred())
+		_, err = securefile.Open(key, cipherText[:4])
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("replaces a file atomically", func() {
+		dir, err := os.MkdirTemp("", "securefile-")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "data", "file")
+		Expect(securefile.WriteFileAtomic(path, []byte("first"))).To(Succeed())
+		Expect(securefile.WriteFileAtomic(path, []byte("second"))).To(Succeed())
+
+		data, err := os.ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(data)).To(Equal("second"))
+
+		// no temporary files are left behind
+		entries, err := os.ReadDir(filepath.Dir(path))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(entries)).To(Equal(1))
+	})
+})
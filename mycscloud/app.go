@@ -4,12 +4,47 @@ import (
 	"context"
 
 	"github.com/appbricks/cloud-builder/target"
+	"github.com/appbricks/cloud-builder/userspace"
 	"github.com/hasura/go-graphql-client"
 
 	"github.com/mevansam/goutils/logger"
 	"github.com/mevansam/goutils/utils"
 )
 
+// an app deployed to a space
+type App struct {
+	AppID,
+	AppName,
+	SpaceID string
+
+	Cookbook,
+	Recipe,
+	IaaS,
+	Region,
+	Status string
+
+	// url at which the app can be reached
+	Endpoint string
+
+	// users who have been granted access to the app
+	Users []*userspace.User
+
+	// local target of the app if the app
+	// is managed by this client. this is
+	// only set for apps in SpaceNodes.
+	Target *target.Target
+}
+
+// returns the key of the app's local
+// target or the app's ID if it is not
+// managed by this client
+func (a *App) Key() string {
+	if a.Target != nil {
+		return a.Target.Key()
+	}
+	return a.AppID
+}
+
 type AppAPI struct {
 	apiClient *graphql.Client
 }
@@ -25,6 +60,14 @@ func (a *AppAPI) AddApp(
 	tgt *target.Target,
 	spaceID string,
 ) error {
+	return a.AddAppWithContext(context.Background(), tgt, spaceID)
+}
+
+func (a *AppAPI) AddAppWithContext(
+	ctx context.Context,
+	tgt *target.Target,
+	spaceID string,
+) error {
 
 	region := tgt.Provider.Region()
 	if region == nil {
@@ -48,9 +91,9 @@ func (a *AppAPI) AddApp(
 		"region": graphql.String(*region),
 		"spaceID": graphql.ID(spaceID),
 	}
-	if err := a.apiClient.Mutate(context.Background(), &mutation, variables); err != nil {
+	if err := a.apiClient.Mutate(ctx, &mutation, variables); err != nil {
 		logger.ErrorMessage("AppAPI.AddApp(): addApp mutation returned an error: %s", err.Error())
-		return err
+		return apiError(err)
 	}
 	logger.TraceMessage("AppAPI.AddApp(): addApp mutation returned response: %# v", mutation)
 
@@ -60,7 +103,11 @@ func (a *AppAPI) AddApp(
 	return nil
 }
 
-func (a *AppAPI) DeleteApp(tgt *target.Target) ([]string, error)  {
+func (a *AppAPI) DeleteApp(tgt *target.Target) ([]string, error) {
+	return a.DeleteAppWithContext(context.Background(), tgt)
+}
+
+func (a *AppAPI) DeleteAppWithContext(ctx context.Context, tgt *target.Target) ([]string, error) {
 
 	var mutation struct {
 		DeleteApp []string `graphql:"deleteApp(appID: $appID)"`
@@ -68,9 +115,9 @@ func (a *AppAPI) DeleteApp(tgt *target.Target) ([]string, error)  {
 	variables := map[string]interface{}{
 		"appID": graphql.ID(tgt.NodeID),
 	}
-	if err := a.apiClient.Mutate(context.Background(), &mutation, variables); err != nil {
+	if err := a.apiClient.Mutate(ctx, &mutation, variables); err != nil {
 		logger.ErrorMessage("AppAPI.DeleteApp(): deleteApp mutation returned an error: %s", err.Error())
-		return nil, err
+		return nil, apiError(err)
 	}
 	logger.TraceMessage("AppAPI.DeleteApp(): deleteApp mutation returned response: %# v", mutation)
 
@@ -80,3 +127,129 @@ func (a *AppAPI) DeleteApp(tgt *target.Target) ([]string, error)  {
 	}
 	return userIDs, nil
 }
+
+func (a *AppAPI) GetApps(spaceID string) ([]*App, error) {
+	return a.GetAppsWithContext(context.Background(), spaceID)
+}
+
+func (a *AppAPI) GetAppsWithContext(ctx context.Context, spaceID string) ([]*App, error) {
+
+	var query struct {
+		GetSpace struct {
+			Apps []struct {
+				AppID    graphql.String `graphql:"appID"`
+				AppName  graphql.String
+				Cookbook graphql.String
+				Recipe   graphql.String
+				Iaas     graphql.String
+				Region   graphql.String
+				Status   graphql.String
+				Endpoint graphql.String
+				Users    struct {
+					AppUsers []struct {
+						User struct {
+							UserID     graphql.String `graphql:"userID"`
+							UserName   graphql.String
+							FirstName  graphql.String
+							MiddleName graphql.String
+							FamilyName graphql.String
+						}
+					}
+				}
+			}
+		} `graphql:"getSpace(spaceID: $spaceID)"`
+	}
+	variables := map[string]interface{}{
+		"spaceID": graphql.ID(spaceID),
+	}
+	if err := a.apiClient.Query(ctx, &query, variables); err != nil {
+		logger.ErrorMessage("AppAPI.GetApps(): getSpace query returned an error: %s", err.Error())
+		return nil, apiError(err)
+	}
+	logger.TraceMessage("AppAPI.GetApps(): getSpace query returned response: %# v", query)
+
+	apps := make([]*App, 0, len(query.GetSpace.Apps))
+	for _, appData := range query.GetSpace.Apps {
+		app := &App{
+			AppID:    string(appData.AppID),
+			AppName:  string(appData.AppName),
+			SpaceID:  spaceID,
+			Cookbook: string(appData.Cookbook),
+			Recipe:   string(appData.Recipe),
+			IaaS:     string(appData.Iaas),
+			Region:   string(appData.Region),
+			Status:   string(appData.Status),
+			Endpoint: string(appData.Endpoint),
+			Users:    make([]*userspace.User, 0, len(appData.Users.AppUsers)),
+		}
+		for _, appUser := range appData.Users.AppUsers {
+			app.Users = append(app.Users, &userspace.User{
+				UserID:     string(appUser.User.UserID),
+				Name:       string(appUser.User.UserName),
+				FirstName:  string(appUser.User.FirstName),
+				MiddleName: string(appUser.User.MiddleName),
+				FamilyName: string(appUser.User.FamilyName),
+			})
+		}
+		apps = append(apps, app)
+	}
+	return apps, nil
+}
+
+// grants the user access to the app. the user
+// must be a member of the app's space.
+func (a *AppAPI) GrantAppAccess(appID, userID string) error {
+	return a.GrantAppAccessWithContext(context.Background(), appID, userID)
+}
+
+func (a *AppAPI) GrantAppAccessWithContext(ctx context.Context, appID, userID string) error {
+
+	var mutation struct {
+		AddAppUser struct {
+			App struct {
+				AppID graphql.String `graphql:"appID"`
+			}
+			User struct {
+				UserID graphql.String `graphql:"userID"`
+			}
+		} `graphql:"addAppUser(appID: $appID, userID: $userID)"`
+	}
+	variables := map[string]interface{}{
+		"appID":  graphql.ID(appID),
+		"userID": graphql.ID(userID),
+	}
+	if err := a.apiClient.Mutate(ctx, &mutation, variables); err != nil {
+		logger.ErrorMessage("AppAPI.GrantAppAccess(): addAppUser mutation returned an error: %s", err.Error())
+		return apiError(err)
+	}
+	logger.TraceMessage("AppAPI.GrantAppAccess(): addAppUser mutation returned response: %# v", mutation)
+	return nil
+}
+
+func (a *AppAPI) RevokeAppAccess(appID, userID string) error {
+	return a.RevokeAppAccessWithContext(context.Background(), appID, userID)
+}
+
+func (a *AppAPI) RevokeAppAccessWithContext(ctx context.Context, appID, userID string) error {
+
+	var mutation struct {
+		DeleteAppUser struct {
+			App struct {
+				AppID graphql.String `graphql:"appID"`
+			}
+			User struct {
+				UserID graphql.String `graphql:"userID"`
+			}
+		} `graphql:"deleteAppUser(appID: $appID, userID: $userID)"`
+	}
+	variables := map[string]interface{}{
+		"appID":  graphql.ID(appID),
+		"userID": graphql.ID(userID),
+	}
+	if err := a.apiClient.Mutate(ctx, &mutation, variables); err != nil {
+		logger.ErrorMessage("AppAPI.RevokeAppAccess(): deleteAppUser mutation returned an error: %s", err.Error())
+		return apiError(err)
+	}
+	logger.TraceMessage("AppAPI.RevokeAppAccess(): deleteAppUser mutation returned response: %# v", mutation)
+	return nil
+}
@@ -87,6 +87,70 @@ var _ = Describe("App API", func() {
 		Expect(userIDs[0]).To(Equal("removed app user #1"))
 		Expect(userIDs[1]).To(Equal("removed app user #2"))
 	})
+
+	It("retrieves the apps deployed to a space", func() {
+		testServer, appAPI := startMockNodeService()
+		defer testServer.Stop()
+
+		testServer.PushRequest().
+			ExpectJSONRequest(getAppsRequest).
+			RespondWith(errorResponse)
+
+		_, err = appAPI.GetApps(spaceTgt.GetSpaceID())
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Message: a test error occurred, Locations: []"))
+
+		testServer.PushRequest().
+			ExpectJSONRequest(getAppsRequest).
+			RespondWith(getAppsResponse)
+
+		apps, err := appAPI.GetApps(spaceTgt.GetSpaceID())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(apps)).To(Equal(2))
+		Expect(apps[0].Key()).To(Equal("126e0de1-d422-4200-9486-25b108d6cc8d"))
+		Expect(apps[0].AppName).To(Equal("test-simple-deployment"))
+		Expect(apps[0].SpaceID).To(Equal("1d812616-5955-4bc6-8b67-ec3f0f12a756"))
+		Expect(apps[0].Cookbook).To(Equal("test"))
+		Expect(apps[0].Recipe).To(Equal("simple"))
+		Expect(apps[0].IaaS).To(Equal("aws"))
+		Expect(apps[0].Region).To(Equal("us-west-2"))
+		Expect(apps[0].Status).To(Equal("running"))
+		Expect(apps[0].Endpoint).To(Equal("https://simple.test.local"))
+		Expect(len(apps[0].Users)).To(Equal(2))
+		Expect(apps[0].Users[0].UserID).To(Equal("owner's user id"))
+		Expect(apps[0].Users[1].Name).To(Equal("bob"))
+		Expect(apps[1].AppName).To(Equal("wiki"))
+		Expect(len(apps[1].Users)).To(Equal(0))
+		Expect(testServer.Done()).To(BeTrue())
+	})
+
+	It("grants and revokes a user's access to an app", func() {
+		testServer, appAPI := startMockNodeService()
+		defer testServer.Stop()
+
+		testServer.PushRequest().
+			ExpectJSONRequest(addAppUserRequest).
+			RespondWith(errorResponse)
+
+		err = appAPI.GrantAppAccess(tgt.NodeID, "bob's user id")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Message: a test error occurred, Locations: []"))
+
+		testServer.PushRequest().
+			ExpectJSONRequest(addAppUserRequest).
+			RespondWith(addAppUserResponse)
+
+		err = appAPI.GrantAppAccess(tgt.NodeID, "bob's user id")
+		Expect(err).ToNot(HaveOccurred())
+
+		testServer.PushRequest().
+			ExpectJSONRequest(deleteAppUserRequest).
+			RespondWith(deleteAppUserResponse)
+
+		err = appAPI.RevokeAppAccess(tgt.NodeID, "bob's user id")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(testServer.Done()).To(BeTrue())
+	})
 })
 
 const addAppRequest = `{
@@ -126,3 +190,97 @@ const deleteAppResponse = `{
 		]
 	}
 }`
+
+const getAppsRequest = `{
+	"query": "query ($spaceID:ID!){getSpace(spaceID: $spaceID){apps{appID,appName,cookbook,recipe,iaas,region,status,endpoint,users{appUsers{user{userID,userName,firstName,middleName,familyName}}}}}}",
+	"variables": {
+		"spaceID": "1d812616-5955-4bc6-8b67-ec3f0f12a756"
+	}
+}`
+const getAppsResponse = `{
+	"data": {
+		"getSpace": {
+			"apps": [
+				{
+					"appID": "126e0de1-d422-4200-9486-25b108d6cc8d",
+					"appName": "test-simple-deployment",
+					"cookbook": "test",
+					"recipe": "simple",
+					"iaas": "aws",
+					"region": "us-west-2",
+					"status": "running",
+					"endpoint": "https://simple.test.local",
+					"users": {
+						"appUsers": [
+							{
+								"user": {
+									"userID": "owner's user id",
+									"userName": "owner"
+								}
+							},
+							{
+								"user": {
+									"userID": "bob's user id",
+									"userName": "bob"
+								}
+							}
+						]
+					}
+				},
+				{
+					"appID": "a9f3c1d2-0b7e-4c55-9a3e-5f1d2c3b4a5e",
+					"appName": "wiki",
+					"cookbook": "apps",
+					"recipe": "wiki",
+					"iaas": "aws",
+					"region": "us-east-1",
+					"status": "shutdown",
+					"endpoint": "https://wiki.test.local",
+					"users": {
+						"appUsers": []
+					}
+				}
+			]
+		}
+	}
+}`
+
+const addAppUserRequest = `{
+	"query": "mutation ($appID:ID!$userID:ID!){addAppUser(appID: $appID, userID: $userID){app{appID},user{userID}}}",
+	"variables": {
+		"appID": "126e0de1-d422-4200-9486-25b108d6cc8d",
+		"userID": "bob's user id"
+	}
+}`
+const addAppUserResponse = `{
+	"data": {
+		"addAppUser": {
+			"app": {
+				"appID": "126e0de1-d422-4200-9486-25b108d6cc8d"
+			},
+			"user": {
+				"userID": "bob's user id"
+			}
+		}
+	}
+}`
+
+const deleteAppUserRequest = `{
+	"query": "mutation ($appID:ID!$userID:ID!){deleteAppUser(appID: $appID, userID: $userID){app{appID},user{userID}}}",
+	"variables": {
+		"appID": "126e0de1-d422-4200-9486-25b108d6cc8d",
+		"userID": "bob's user id"
+	}
+}`
+const deleteAppUserResponse = `{
+	"data": {
+		"deleteAppUser": {
+			"app": {
+				"appID": "126e0de1-d422-4200-9486-25b108d6cc8d"
+			},
+			"user": {
+				"userID": "bob's user id"
+			}
+		}
+	}
+}`
@@ -0,0 +1,277 @@
+package mycscloud
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hasura/go-graphql-client"
+
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/appbricks/mycloudspace-client/internal/securefile"
+	"github.com/mevansam/goutils/logger"
+)
+
+// type of the query responses that
+// are saved to the offline cache
+type CacheEntryType string
+
+const (
+	SpacesCacheEntry        CacheEntryType = "spaces"
+	UserCacheEntry          CacheEntryType = "user"
+	DeviceContextCacheEntry CacheEntryType = "deviceContext"
+)
+
+// the default time for which a cached query
+// response will be returned when the MyCS
+// cloud service cannot be reached
+var DefaultCacheTTLs = map[CacheEntryType]time.Duration{
+	SpacesCacheEntry:        24 * time.Hour,
+	UserCacheEntry:          7 * 24 * time.Hour,
+	DeviceContextCacheEntry: time.Hour,
+}
+
+// the maximum time for which a cached device
+// authorization is returned. this limits how
+// long access that has been revoked remains
+// effective on a device that is offline.
+const maxDeviceContextCacheTTL = time.Hour
+
+// returned when a cache entry does
+// not exist or it has expired
+var ErrCacheMiss = errors.New("cache miss")
+
+// encrypted on-disk cache of the MyCS cloud
+// service query responses for a logged in
+// user. cached responses are returned only
+// when the service cannot be reached.
+type Cache struct {
+	cacheDir string
+	key      []byte
+
+	ttls map[CacheEntryType]time.Duration
+	// time at which stale entries that were
+	// last returned by a lookup were cached
+	stale map[CacheEntryType]time.Time
+
+	mx sync.Mutex
+}
+
+type cacheEntry struct {
+	CachedAt time.Time       `json:"cachedAt"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// returns a cache for the logged in user of the
+// given config which is saved alongside the
+// config file
+func NewCacheForConfig(config config.Config) (*Cache, error) {
+	return NewCache(
+		filepath.Join(filepath.Dir(config.GetConfigFile()), "cache"),
+		config.DeviceContext(),
+	)
+}
+
+// returns a cache for the logged in user of the
+// given device context. the cache is encrypted
+// with a key derived from the device's private
+// key and the user's ID.
+func NewCache(cacheDir string, deviceContext config.DeviceContext) (*Cache, error) {
+
+	if deviceContext == nil {
+		return nil, fmt.Errorf("a device context is required to cache api responses")
+	}
+	device := deviceContext.GetDevice()
+	if device == nil || len(device.RSAPrivateKey) == 0 {
+		return nil, fmt.Errorf("device context has not been initialized with a device key")
+	}
+	userID := deviceContext.GetLoggedInUserID()
+	if len(userID) == 0 {
+		return nil, fmt.Errorf("device context does not have a logged in user")
+	}
+
+	key := sha256.Sum256([]byte(device.RSAPrivateKey + "|" + userID))
+	ttls := make(map[CacheEntryType]time.Duration)
+	for entryType, ttl := range DefaultCacheTTLs {
+		ttls[entryType] = cacheTTL(entryType, ttl)
+	}
+	return &Cache{
+		cacheDir: filepath.Join(cacheDir, userID),
+		key:      key[:],
+
+		ttls:  ttls,
+		stale: make(map[CacheEntryType]time.Time),
+	}, nil
+}
+
+// sets the time for which entries
+// of the given type are valid
+func (c *Cache) SetTTL(entryType CacheEntryType, ttl time.Duration) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.ttls[entryType] = cacheTTL(entryType, ttl)
+}
+
+// returns whether the data last returned for the
+// given entry type was read from the cache as the
+// service could not be reached and when it was
+// cached
+func (c *Cache) IsStale(entryType CacheEntryType) (bool, time.Time) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	cachedAt, isStale := c.stale[entryType]
+	return isStale, cachedAt
+}
+
+// saves data of the given type to the cache
+func (c *Cache) Put(entryType CacheEntryType, data interface{}) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	var (
+		err error
+
+		entry      cacheEntry
+		plainText  []byte
+		cipherText []byte
+	)
+
+	if entry.Data, err = json.Marshal(data); err != nil {
+		return err
+	}
+	entry.CachedAt = time.Now()
+	if plainText, err = json.Marshal(&entry); err != nil {
+		return err
+	}
+	if cipherText, err = securefile.Seal(c.key, plainText); err != nil {
+		return err
+	}
+	if err = securefile.WriteFileAtomic(c.entryPath(entryType), cipherText); err != nil {
+		return err
+	}
+	delete(c.stale, entryType)
+	return nil
+}
+
+// reads data of the given type from the cache and
+// returns the time it was cached. ErrCacheMiss is
+// returned if the entry has expired.
+func (c *Cache) Get(entryType CacheEntryType, data interface{}) (time.Time, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	var (
+		err error
+
+		entry      cacheEntry
+		plainText  []byte
+		cipherText []byte
+	)
+
+	if cipherText, err = os.ReadFile(c.entryPath(entryType)); err != nil {
+		if os.IsNotExist(err) {
+			return time.Time{}, ErrCacheMiss
+		}
+		return time.Time{}, err
+	}
+	if plainText, err = securefile.Open(c.key, cipherText); err != nil {
+		return time.Time{}, err
+	}
+	if err = json.Unmarshal(plainText, &entry); err != nil {
+		return time.Time{}, err
+	}
+	if ttl, exists := c.ttls[entryType]; exists && time.Since(entry.CachedAt) > ttl {
+		return time.Time{}, ErrCacheMiss
+	}
+	if err = json.Unmarshal(entry.Data, data); err != nil {
+		return time.Time{}, err
+	}
+	return entry.CachedAt, nil
+}
+
+// removes all cached entries of the user
+func (c *Cache) Clear() error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.stale = make(map[CacheEntryType]time.Time)
+	return os.RemoveAll(c.cacheDir)
+}
+
+func (c *Cache) entryPath(entryType CacheEntryType) string {
+	return filepath.Join(c.cacheDir, string(entryType))
+}
+
+// runs the given query and saves the response to the
+// cache. if the service cannot be reached then the
+// last cached response is returned instead.
+func (c *Cache) query(
+	ctx context.Context,
+	apiClient *graphql.Client,
+	entryType CacheEntryType,
+	query interface{},
+	variables map[string]interface{},
+) error {
+
+	var (
+		err, cacheErr error
+
+		cachedAt time.Time
+	)
+
+	if err = apiClient.Query(ctx, query, variables); err != nil {
+		if c == nil || !isServiceUnreachable(ctx, err) {
+			return err
+		}
+		if cachedAt, cacheErr = c.Get(entryType, query); cacheErr != nil {
+			if cacheErr != ErrCacheMiss {
+				logger.ErrorMessage("Cache.query(): Failed to read cached %s: %s", entryType, cacheErr.Error())
+			}
+			return err
+		}
+		logger.WarnMessage(
+			"Cache.query(): Service could not be reached so returning %s cached at %s: %s",
+			entryType, cachedAt.Format(time.RFC3339), err.Error(),
+		)
+
+		c.mx.Lock()
+		c.stale[entryType] = cachedAt
+		c.mx.Unlock()
+		return nil
+	}
+	if c != nil {
+		if cacheErr = c.Put(entryType, query); cacheErr != nil {
+			logger.ErrorMessage("Cache.query(): Failed to cache %s: %s", entryType, cacheErr.Error())
+		}
+	}
+	return nil
+}
+
+// returns the given ttl capped for
+// entries that authorize access
+func cacheTTL(entryType CacheEntryType, ttl time.Duration) time.Duration {
+	if entryType == DeviceContextCacheEntry && ttl > maxDeviceContextCacheTTL {
+		return maxDeviceContextCacheTTL
+	}
+	return ttl
+}
+
+// returns whether the given query error was due to
+// the service not being reachable as opposed to an
+// error response from the service
+func isServiceUnreachable(ctx context.Context, err error) bool {
+
+	var (
+		gqlErrors graphql.Errors
+	)
+
+	return ctx.Err() == nil && !errors.As(err, &gqlErrors)
+}
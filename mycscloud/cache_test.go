@@ -0,0 +1,169 @@
+package mycscloud_test
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/appbricks/mycloudspace-client/api"
+	"github.com/appbricks/mycloudspace-client/mycscloud"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	mycs_mocks "github.com/appbricks/mycloudspace-client/test/mocks"
+)
+
+var _ = Describe("Offline Cache", func() {
+
+	var (
+		err error
+
+		cfg           config.Config
+		deviceContext config.DeviceContext
+
+		cacheDir string
+		cache    *mycscloud.Cache
+	)
+
+	type testData struct {
+		Name  string
+		Value int
+	}
+
+	BeforeEach(func() {
+		cfg, err = mycs_mocks.NewMockConfig(sourceDirPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		deviceContext = config.NewDeviceContext()
+		_, err = deviceContext.NewDevice()
+		Expect(err).ToNot(HaveOccurred())
+		deviceContext.SetLoggedInUser("0000", "owner")
+
+		cacheDir, err = os.MkdirTemp("", "mycs-cache-")
+		Expect(err).ToNot(HaveOccurred())
+
+		cache, err = mycscloud.NewCache(cacheDir, deviceContext)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(cacheDir)
+	})
+
+	It("saves and reads encrypted cache entries", func() {
+
+		err = cache.Put(mycscloud.UserCacheEntry, &testData{Name: "test", Value: 1})
+		Expect(err).ToNot(HaveOccurred())
+
+		data := testData{}
+		cachedAt, err := cache.Get(mycscloud.UserCacheEntry, &data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(Equal(testData{Name: "test", Value: 1}))
+		Expect(cachedAt).To(BeTemporally("~", time.Now(), time.Second))
+
+		_, err = cache.Get(mycscloud.SpacesCacheEntry, &data)
+		Expect(err).To(Equal(mycscloud.ErrCacheMiss))
+
+		// entries should not be readable by another user
+		deviceContext.SetLoggedInUser("1111", "guest1")
+		guestCache, err := mycscloud.NewCache(cacheDir, deviceContext)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = guestCache.Get(mycscloud.UserCacheEntry, &data)
+		Expect(err).To(Equal(mycscloud.ErrCacheMiss))
+
+		// expired entries should not be returned
+		cache.SetTTL(mycscloud.UserCacheEntry, time.Millisecond)
+		time.Sleep(10 * time.Millisecond)
+		_, err = cache.Get(mycscloud.UserCacheEntry, &data)
+		Expect(err).To(Equal(mycscloud.ErrCacheMiss))
+
+		Expect(cache.Clear()).To(Succeed())
+		cache.SetTTL(mycscloud.UserCacheEntry, time.Hour)
+		_, err = cache.Get(mycscloud.UserCacheEntry, &data)
+		Expect(err).To(Equal(mycscloud.ErrCacheMiss))
+	})
+
+	It("returns cached spaces when the service cannot be reached", func() {
+		testServer, testServerUrl := startTestServer()
+		spaceAPI := mycscloud.NewSpaceAPI(api.NewGraphQLClient(testServerUrl, "", cfg.AuthContext())).WithCache(cache)
+
+		testServer.PushRequest().
+			ExpectJSONRequest(getSpacesRequest).
+			RespondWith(getSpacesResponse)
+
+		spaces, err := spaceAPI.GetSpaces()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(spaces)).To(Equal(1))
+		Expect(testServer.Done()).To(BeTrue())
+
+		isStale, _ := cache.IsStale(mycscloud.SpacesCacheEntry)
+		Expect(isStale).To(BeFalse())
+
+		// service errors should not be masked by the cache
+		testServer.PushRequest().
+			ExpectJSONRequest(getSpacesRequest).
+			RespondWith(errorResponse)
+
+		_, err = spaceAPI.GetSpaces()
+		Expect(err).To(HaveOccurred())
+		Expect(testServer.Done()).To(BeTrue())
+
+		testServer.Stop()
+
+		ctx := api.WithRetryPolicy(context.Background(), api.RetryPolicy{MaxAttempts: 1})
+		spaces, err = spaceAPI.GetSpacesWithContext(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(spaces)).To(Equal(1))
+		Expect(spaces[0].SpaceID).To(Equal("1d812616-5955-4bc6-8b67-ec3f0f12a756"))
+		Expect(spaces[0].FQDN).To(Equal("test1-wg-us-east-1.local"))
+		Expect(spaces[0].LocalCARoot).To(HavePrefix("-----BEGIN CERTIFICATE-----"))
+
+		isStale, cachedAt := cache.IsStale(mycscloud.SpacesCacheEntry)
+		Expect(isStale).To(BeTrue())
+		Expect(cachedAt).To(BeTemporally("~", time.Now(), 5*time.Second))
+	})
+
+	It("returns a cached device authorization for a limited time when the service cannot be reached", func() {
+		testServer, testServerUrl := startTestServer()
+		deviceAPI := mycscloud.NewDeviceAPI(api.NewGraphQLClient(testServerUrl, "", cfg.AuthContext())).WithCache(cache)
+
+		appDeviceContext := cfg.DeviceContext()
+		_, err = appDeviceContext.NewDevice()
+		Expect(err).ToNot(HaveOccurred())
+		appDeviceContext.SetDeviceID("zyxw", "1234", "New Test Device")
+		_, err = appDeviceContext.NewOwnerUser("0000", "owner")
+		Expect(err).ToNot(HaveOccurred())
+		err = cfg.SetLoggedInUser("0000", "owner")
+		Expect(err).ToNot(HaveOccurred())
+
+		testServer.PushRequest().
+			ExpectJSONRequest(updateDeviceContextRequest).
+			RespondWith(updateDeviceContextResponse)
+
+		err = deviceAPI.UpdateDeviceContext(appDeviceContext)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(testServer.Done()).To(BeTrue())
+
+		isStale, _ := deviceAPI.IsStale()
+		Expect(isStale).To(BeFalse())
+
+		testServer.Stop()
+
+		ctx := api.WithRetryPolicy(context.Background(), api.RetryPolicy{MaxAttempts: 1})
+		err = deviceAPI.UpdateDeviceContextWithContext(ctx, appDeviceContext)
+		Expect(err).ToNot(HaveOccurred())
+
+		isStale, cachedAt := deviceAPI.IsStale()
+		Expect(isStale).To(BeTrue())
+		Expect(cachedAt).To(BeTemporally("~", time.Now(), 5*time.Second))
+
+		// device authorizations are not
+		// returned once they have expired
+		cache.SetTTL(mycscloud.DeviceContextCacheEntry, time.Millisecond)
+		time.Sleep(10 * time.Millisecond)
+		err = deviceAPI.UpdateDeviceContextWithContext(ctx, appDeviceContext)
+		Expect(err).To(HaveOccurred())
+	})
+})
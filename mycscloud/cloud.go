@@ -23,6 +23,13 @@ func NewCloudAPI(apiClient *graphql.Client) *CloudAPI {
 func (c *CloudAPI) UpdateProperties(
 	authContext config.AuthContext,
 ) error {
+	return c.UpdatePropertiesWithContext(context.Background(), authContext)
+}
+
+func (c *CloudAPI) UpdatePropertiesWithContext(
+	ctx context.Context,
+	authContext config.AuthContext,
+) error {
 
 	var query struct {
 		MyCSCloudProps struct {
@@ -31,9 +38,9 @@ func (c *CloudAPI) UpdateProperties(
 		} `graphql:"mycsCloudProps"`		
 	}
 
-	if err := c.apiClient.Query(context.Background(), &query, nil); err != nil {
+	if err := c.apiClient.Query(ctx, &query, nil); err != nil {
 		logger.ErrorMessage("CloudAPI.UpdateProperties(): mycsCloudProps query returned an error: %s", err.Error())
-		return err
+		return apiError(err)
 	}
 	logger.DebugMessage("CloudAPI.UpdateProperties(): mycsCloudProps query returned response: %# v", query)
 
@@ -3,16 +3,19 @@ package mycscloud
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/hasura/go-graphql-client"
 
 	"github.com/appbricks/cloud-builder/config"
 	"github.com/appbricks/cloud-builder/userspace"
+	"github.com/appbricks/mycloudspace-client/api"
 	"github.com/mevansam/goutils/logger"
 )
 
 type DeviceAPI struct {
 	apiClient *graphql.Client
+	cache     *Cache
 }
 
 func NewDeviceAPI(apiClient *graphql.Client) *DeviceAPI {
@@ -21,7 +24,28 @@ func NewDeviceAPI(apiClient *graphql.Client) *DeviceAPI {
 	}
 }
 
+// returns the API with its query responses
+// saved to the given offline cache
+func (d *DeviceAPI) WithCache(cache *Cache) *DeviceAPI {
+	d.cache = cache
+	return d
+}
+
+// returns whether the device authorization last
+// applied to a device context was read from the
+// offline cache and when it was cached
+func (d *DeviceAPI) IsStale() (bool, time.Time) {
+	if d.cache == nil {
+		return false, time.Time{}
+	}
+	return d.cache.IsStale(DeviceContextCacheEntry)
+}
+
 func (d *DeviceAPI) UpdateDeviceContext(deviceContext config.DeviceContext) error {
+	return d.UpdateDeviceContextWithContext(context.Background(), deviceContext)
+}
+
+func (d *DeviceAPI) UpdateDeviceContextWithContext(ctx context.Context, deviceContext config.DeviceContext) error {
 	
 	var (
 		deviceID, ownerUserID string
@@ -32,10 +56,10 @@ func (d *DeviceAPI) UpdateDeviceContext(deviceContext config.DeviceContext) erro
 	)
 
 	if deviceID, exists = deviceContext.GetDeviceID(); !exists {
-		return fmt.Errorf("device context has not been initialized with a device")
+		return newError(ErrInvalidDeviceContext, "device context has not been initialized with a device")
 	}
 	if ownerUserID, exists = deviceContext.GetOwnerUserID(); !exists {
-		return fmt.Errorf("device context has not been initialized with an owner")
+		return newError(ErrInvalidDeviceContext, "device context has not been initialized with an owner")
 	}	
 
 	var query struct {
@@ -82,9 +106,9 @@ func (d *DeviceAPI) UpdateDeviceContext(deviceContext config.DeviceContext) erro
 	variables := map[string]interface{}{
 		"idKey": graphql.String(deviceIDKey),
 	}
-	if err := d.apiClient.Query(context.Background(), &query, variables); err != nil {
+	if err := d.cache.query(ctx, d.apiClient, DeviceContextCacheEntry, &query, variables); err != nil {
 		logger.ErrorMessage("DeviceAPI.UpdateDeviceContext(): authDevice query returned an error: %s", err.Error())
-		return err
+		return apiError(err)
 	}
 	logger.DebugMessage("DeviceAPI.UpdateDeviceContext(): authDevice query returned response: %# v", query)
 
@@ -94,7 +118,7 @@ func (d *DeviceAPI) UpdateDeviceContext(deviceContext config.DeviceContext) erro
 			logger.ErrorMessage(
 				"DeviceAPI.UpdateDeviceContext(): authDevice query returned \"admin\" access type for a user that is not the device owner.",
 			)
-			return fmt.Errorf("invalid device context")
+			return ErrInvalidDeviceContext
 		}
 
 		// check if authorized device matches device in context
@@ -103,7 +127,7 @@ func (d *DeviceAPI) UpdateDeviceContext(deviceContext config.DeviceContext) erro
 				"DeviceAPI.UpdateDeviceContext(): authDevice query returned device ID '%s' but the device context device id was '%s'.",
 				query.AuthDevice.Device.DeviceID, deviceID,
 			)
-			return fmt.Errorf("invalid device context")
+			return ErrInvalidDeviceContext
 		}
 
 		device := deviceContext.GetDevice()
@@ -152,7 +176,7 @@ func (d *DeviceAPI) UpdateDeviceContext(deviceContext config.DeviceContext) erro
 						"DeviceAPI.UpdateDeviceContext(): authDevice query returned owner user ID '%s' but the device context owner has user id '%s'.",
 						deviceUser.User.UserID, ownerUserID,
 					)
-					return fmt.Errorf("invalid device context")
+					return ErrInvalidDeviceContext
 				}
 			} else {
 				if guestUser, exists = guestUsers[userName]; exists && guestUser.UserID ==userID {
@@ -171,18 +195,18 @@ func (d *DeviceAPI) UpdateDeviceContext(deviceContext config.DeviceContext) erro
 		}
 	} else {
 		if string(query.AuthDevice.AccessType) == "unauthorized" {
-			return fmt.Errorf("unauthorized")
+			return ErrUnauthorized
 		}
 		if guestUser, exists = deviceContext.GetGuestUser(deviceContext.GetLoggedInUserName()); !exists {
 			logger.ErrorMessage(
 				"DeviceAPI.UpdateDeviceContext(): authDevice query returned a guest user \"%s\" that was not found in the device context",
 				deviceContext.GetLoggedInUserName(),
 			)
-			return fmt.Errorf("invalid device context")
+			return ErrInvalidDeviceContext
 		}
 		guestUser.Active = string(query.AuthDevice.AccessType) == "guest"
 		if !guestUser.Active {
-			return fmt.Errorf("unauthorized(pending)")
+			return ErrAccessPending
 		}
 	}
 	return nil
@@ -196,6 +220,18 @@ func (d *DeviceAPI) RegisterDevice(
 	devicePublicKey,
 	managedBy string,
 ) (string, string, error) {
+	return d.RegisterDeviceWithContext(context.Background(), deviceName, deviceType, clientVersion, deviceCertRequest, devicePublicKey, managedBy)
+}
+
+func (d *DeviceAPI) RegisterDeviceWithContext(
+	ctx context.Context,
+	deviceName, 
+	deviceType,
+	clientVersion,
+	deviceCertRequest,
+	devicePublicKey,
+	managedBy string,
+) (string, string, error) {
 
 	var mutation struct {
 		AddDevice struct {
@@ -215,15 +251,24 @@ func (d *DeviceAPI) RegisterDevice(
 		"devicePublicKey": graphql.String(devicePublicKey),
 		"managedBy": graphql.String(managedBy),
 	}
-	if err := d.apiClient.Mutate(context.Background(), &mutation, variables); err != nil {
+	if err := d.apiClient.Mutate(ctx, &mutation, variables); err != nil {
 		logger.ErrorMessage("DeviceAPI.RegisterDevice(): addDevice mutation returned an error: %s", err.Error())
-		return "", "", err
+		return "", "", apiError(err)
 	}
 	logger.TraceMessage("DeviceAPI.RegisterDevice(): addDevice mutation returned response: %# v", mutation)
 	return string(mutation.AddDevice.IdKey), string(mutation.AddDevice.DeviceUser.Device.DeviceID), nil
 }
 
+// unregisters the device with the given id. this
+// may be any device owned by the logged in user and
+// not only the device this client is running on.
+// returns the ids of the users removed from the
+// device.
 func (d *DeviceAPI) UnRegisterDevice(deviceID string) ([]string, error) {
+	return d.UnRegisterDeviceWithContext(context.Background(), deviceID)
+}
+
+func (d *DeviceAPI) UnRegisterDeviceWithContext(ctx context.Context, deviceID string) ([]string, error) {
 
 	var mutation struct {
 		DeleteDevice []string `graphql:"deleteDevice(deviceID: $deviceID)"`
@@ -231,9 +276,9 @@ func (d *DeviceAPI) UnRegisterDevice(deviceID string) ([]string, error) {
 	variables := map[string]interface{}{
 		"deviceID": graphql.ID(deviceID),
 	}
-	if err := d.apiClient.Mutate(context.Background(), &mutation, variables); err != nil {
+	if err := d.apiClient.Mutate(ctx, &mutation, variables); err != nil {
 		logger.ErrorMessage("DeviceAPI.UnRegisterDevice(): deleteDevice mutation returned an error: %s", err.Error())
-		return nil, err
+		return nil, apiError(err)
 	}
 	logger.TraceMessage("DeviceAPI.UnRegisterDevice(): deleteDevice mutation returned response: %# v", mutation)
 
@@ -244,7 +289,36 @@ func (d *DeviceAPI) UnRegisterDevice(deviceID string) ([]string, error) {
 	return userIDs, nil
 }
 
+// unregisters the device with the given id after
+// checking that the logged in user owns it. this
+// avoids a request the service would reject when
+// the device is only shared with the user.
+func (d *DeviceAPI) UnRegisterOwnedDevice(deviceID string) ([]string, error) {
+	return d.UnRegisterOwnedDeviceWithContext(context.Background(), deviceID)
+}
+
+func (d *DeviceAPI) UnRegisterOwnedDeviceWithContext(ctx context.Context, deviceID string) ([]string, error) {
+
+	var (
+		err error
+
+		device *UserDevice
+	)
+
+	if device, err = d.GetDeviceWithContext(ctx, deviceID); err != nil {
+		return nil, err
+	}
+	if !device.IsOwned {
+		return nil, newError(ErrUnauthorized, fmt.Sprintf("only the owner of device '%s' can unregister it", device.Name))
+	}
+	return d.UnRegisterDeviceWithContext(ctx, deviceID)
+}
+
 func (d *DeviceAPI) AddDeviceUser(deviceID, userID string) (string, string, error) {
+	return d.AddDeviceUserWithContext(context.Background(), deviceID, userID)
+}
+
+func (d *DeviceAPI) AddDeviceUserWithContext(ctx context.Context, deviceID, userID string) (string, string, error) {
 
 	var mutation struct {
 		AddDeviceUser struct {
@@ -260,15 +334,19 @@ func (d *DeviceAPI) AddDeviceUser(deviceID, userID string) (string, string, erro
 		"deviceID": graphql.ID(deviceID),
 		"userID": graphql.ID(userID),
 	}
-	if err := d.apiClient.Mutate(context.Background(), &mutation, variables); err != nil {
+	if err := d.apiClient.Mutate(ctx, &mutation, variables); err != nil {
 		logger.ErrorMessage("DeviceAPI.AddDeviceUser(): addDeviceUser mutation returned an error: %s", err.Error())
-		return "", "", err
+		return "", "", apiError(err)
 	}
 	logger.TraceMessage("DeviceAPI.AddDeviceUser(): addDeviceUser mutation returned response: %# v", mutation)
 	return string(mutation.AddDeviceUser.Device.DeviceID), string(mutation.AddDeviceUser.User.UserID), nil
 }
 
 func (d *DeviceAPI) RemoveDeviceUser(deviceID, userID string) (string, string, error) {
+	return d.RemoveDeviceUserWithContext(context.Background(), deviceID, userID)
+}
+
+func (d *DeviceAPI) RemoveDeviceUserWithContext(ctx context.Context, deviceID, userID string) (string, string, error) {
 
 	var mutation struct {
 		DeleteDeviceUser struct {
@@ -284,9 +362,9 @@ func (d *DeviceAPI) RemoveDeviceUser(deviceID, userID string) (string, string, e
 		"deviceID": graphql.ID(deviceID),
 		"userID": graphql.ID(userID),
 	}
-	if err := d.apiClient.Mutate(context.Background(), &mutation, variables); err != nil {
+	if err := d.apiClient.Mutate(ctx, &mutation, variables); err != nil {
 		logger.ErrorMessage("DeviceAPI.RemoveDeviceUser(): deleteDeviceUser mutation returned an error: %s", err.Error())
-		return "", "", err
+		return "", "", apiError(err)
 	}
 	logger.TraceMessage("DeviceAPI.RemoveDeviceUser(): deleteDeviceUser mutation returned response: %# v", mutation)
 	return string(mutation.DeleteDeviceUser.Device.DeviceID), string(mutation.DeleteDeviceUser.User.UserID), nil
@@ -301,6 +379,19 @@ func (d *DeviceAPI) SetDeviceWireguardConfig(
 	wgExpirationTimeout,
 	wgInactivityTimeout int,
 ) error {
+	return d.SetDeviceWireguardConfigWithContext(context.Background(), userID, deviceID, spaceID, wgConfigName, wgConfig, wgExpirationTimeout, wgInactivityTimeout)
+}
+
+func (d *DeviceAPI) SetDeviceWireguardConfigWithContext(
+	ctx context.Context,
+	userID,
+	deviceID,
+	spaceID,
+	wgConfigName,
+	wgConfig string,
+	wgExpirationTimeout,
+	wgInactivityTimeout int,
+) error {
 
 	var mutation struct {
 		UpdateDevice struct {
@@ -317,9 +408,10 @@ func (d *DeviceAPI) SetDeviceWireguardConfig(
 		"wgExpirationTimeout": graphql.Int(wgExpirationTimeout),
 		"wgInactivityTimeout": graphql.Int(wgInactivityTimeout),
 	}
-	if err := d.apiClient.Mutate(context.Background(), &mutation, variables); err != nil {
+	// setting the config is idempotent so it is safe to retry
+	if err := d.apiClient.Mutate(api.WithMutationRetry(ctx), &mutation, variables); err != nil {
 		logger.ErrorMessage("DeviceAPI.SetDeviceWireguardConfig(): setDeviceUserSpaceConfig mutation returned an error: %s", err.Error())
-		return err
+		return apiError(err)
 	}
 	logger.TraceMessage("DeviceAPI.SetDeviceWireguardConfig(): setDeviceUserSpaceConfig mutation returned response: %# v", mutation)
 	return nil
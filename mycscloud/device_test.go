@@ -1,6 +1,8 @@
 package mycscloud_test
 
 import (
+	"errors"
+
 	"golang.org/x/oauth2"
 
 	"github.com/appbricks/cloud-builder/config"
@@ -53,6 +55,7 @@ var _ = Describe("Device API", func() {
 		err = deviceAPI.UpdateDeviceContext(deviceContext)
 		Expect(err).To(HaveOccurred())
 		Expect(err.Error()).To(Equal("device context has not been initialized with a device"))
+		Expect(errors.Is(err, mycscloud.ErrInvalidDeviceContext)).To(BeTrue())
 
 		device, err := deviceContext.NewDevice()
 		Expect(err).ToNot(HaveOccurred())
@@ -181,6 +184,42 @@ var _ = Describe("Device API", func() {
 		Expect(userIDs[1]).To(Equal("removed device user #2"))
 	})
 
+	It("unregisters a device owned by the user", func() {
+		testServer, deviceAPI := startMockNodeService()
+		defer testServer.Stop()
+
+		// a device other than the one
+		// the client is running on
+		testServer.PushRequest().
+			ExpectJSONRequest(getUserDevicesRequest).
+			RespondWith(getUserDevicesResponse)
+		testServer.PushRequest().
+			ExpectJSONRequest(deleteOwnedDeviceRequest).
+			RespondWith(deleteDeviceResponse)
+
+		userIDs, err := deviceAPI.UnRegisterOwnedDevice("laptop device id")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(userIDs)).To(Equal(2))
+
+		// only the owner can unregister a device
+		testServer.PushRequest().
+			ExpectJSONRequest(getUserDevicesRequest).
+			RespondWith(getUserDevicesResponse)
+
+		_, err = deviceAPI.UnRegisterOwnedDevice("phone device id")
+		Expect(err).To(HaveOccurred())
+		Expect(errors.Is(err, mycscloud.ErrUnauthorized)).To(BeTrue())
+
+		testServer.PushRequest().
+			ExpectJSONRequest(getUserDevicesRequest).
+			RespondWith(getUserDevicesResponse)
+
+		_, err = deviceAPI.UnRegisterOwnedDevice("old device id")
+		Expect(err).To(HaveOccurred())
+		Expect(errors.Is(err, mycscloud.ErrNotFound)).To(BeTrue())
+		Expect(testServer.Done()).To(BeTrue())
+	})
+
 	It("updates a device user's wireguard config", func() {
 		testServer, deviceAPI := startMockNodeService()
 		defer testServer.Stop()
@@ -278,6 +317,82 @@ var _ = Describe("Device API", func() {
 		err = deviceAPI.SetDeviceWireguardConfig("a user id", "a device id", "a space id", "wg config name", "wg config details", 720, 168)
 		Expect(err).ToNot(HaveOccurred())
 	})
+
+	It("lists the devices the user has access to", func() {
+		testServer, deviceAPI := startMockNodeService()
+		defer testServer.Stop()
+
+		testServer.PushRequest().
+			ExpectJSONRequest(getUserDevicesRequest).
+			RespondWith(errorResponse)
+
+		_, err = deviceAPI.ListDevices()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Message: a test error occurred, Locations: []"))
+
+		testServer.PushRequest().
+			ExpectJSONRequest(getUserDevicesRequest).
+			RespondWith(getUserDevicesResponse)
+
+		devices, err := deviceAPI.ListDevices()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(devices)).To(Equal(2))
+		Expect(devices[0].DeviceID).To(Equal("laptop device id"))
+		Expect(devices[0].Name).To(Equal("Family Laptop"))
+		Expect(devices[0].Type).To(Equal("MacBook"))
+		Expect(devices[0].ClientVersion).To(Equal("0.9.1"))
+		Expect(devices[0].IsOwned).To(BeTrue())
+		Expect(devices[0].AccessStatus).To(Equal("active"))
+		Expect(devices[0].LastSeen.UnixMilli()).To(Equal(int64(1700000000000)))
+		Expect(devices[1].DeviceID).To(Equal("phone device id"))
+		Expect(devices[1].IsOwned).To(BeFalse())
+		Expect(devices[1].LastSeen.IsZero()).To(BeTrue())
+
+		testServer.PushRequest().
+			ExpectJSONRequest(getUserDevicesRequest).
+			RespondWith(getUserDevicesResponse)
+
+		device, err := deviceAPI.GetDevice("phone device id")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(device.Name).To(Equal("Bob's Phone"))
+		Expect(device.AccessStatus).To(Equal("pending"))
+
+		// devices the user no longer has
+		// access to are not returned
+		testServer.PushRequest().
+			ExpectJSONRequest(getUserDevicesRequest).
+			RespondWith(getUserDevicesResponse)
+
+		_, err = deviceAPI.GetDevice("old device id")
+		Expect(err).To(HaveOccurred())
+		Expect(errors.Is(err, mycscloud.ErrNotFound)).To(BeTrue())
+		Expect(testServer.Done()).To(BeTrue())
+	})
+
+	It("renames a device", func() {
+		testServer, deviceAPI := startMockNodeService()
+		defer testServer.Stop()
+
+		err = deviceAPI.RenameDevice("a device id", "  ")
+		Expect(err).To(HaveOccurred())
+		Expect(errors.Is(err, mycscloud.ErrBadRequest)).To(BeTrue())
+
+		testServer.PushRequest().
+			ExpectJSONRequest(updateDeviceRequest).
+			RespondWith(errorResponse)
+
+		err = deviceAPI.RenameDevice("a device id", "Work Laptop")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Message: a test error occurred, Locations: []"))
+
+		testServer.PushRequest().
+			ExpectJSONRequest(updateDeviceRequest).
+			RespondWith(updateDeviceResponse)
+
+		err = deviceAPI.RenameDevice("a device id", " Work Laptop ")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(testServer.Done()).To(BeTrue())
+	})
 })
 
 const updateDeviceContextRequest = `{
@@ -362,6 +477,12 @@ const deleteDeviceRequest = `{
 		"deviceID": "a device id"
 	}
 }`
+const deleteOwnedDeviceRequest = `{
+	"query": "mutation ($deviceID:ID!){deleteDevice(deviceID: $deviceID)}",
+	"variables": {
+		"deviceID": "laptop device id"
+	}
+}`
 const deleteDeviceResponse = `{
 	"data": {
 		"deleteDevice": [
@@ -431,3 +552,66 @@ const setDeviceUserSpaceConfigResponse = `{
 		}
 	}
 }`
+
+const getUserDevicesRequest = `{
+	"query": "{getUser{devices{deviceUsers{device{deviceID,deviceName,deviceType,clientVersion,lastSeen},isOwner,status}}}}"
+}`
+const getUserDevicesResponse = `{
+	"data": {
+		"getUser": {
+			"devices": {
+				"deviceUsers": [
+					{
+						"device": {
+							"deviceID": "laptop device id",
+							"deviceName": "Family Laptop",
+							"deviceType": "MacBook",
+							"clientVersion": "0.9.1",
+							"lastSeen": 1700000000000
+						},
+						"isOwner": true,
+						"status": "active"
+					},
+					{
+						"device": {
+							"deviceID": "phone device id",
+							"deviceName": "Bob's Phone",
+							"deviceType": "iPhone",
+							"clientVersion": "0.9.0",
+							"lastSeen": null
+						},
+						"isOwner": false,
+						"status": "pending"
+					},
+					{
+						"device": {
+							"deviceID": "old device id",
+							"deviceName": "Old Laptop",
+							"deviceType": "MacBook",
+							"clientVersion": "0.8.0",
+							"lastSeen": 1600000000000
+						},
+						"isOwner": false,
+						"status": "inactive"
+					}
+				]
+			}
+		}
+	}
+}`
+
+const updateDeviceRequest = `{
+	"query": "mutation ($deviceID:ID!$deviceName:String!){updateDevice(deviceID: $deviceID, deviceName: $deviceName){deviceID,deviceName}}",
+	"variables": {
+		"deviceID": "a device id",
+		"deviceName": "Work Laptop"
+	}
+}`
+const updateDeviceResponse = `{
+	"data": {
+		"updateDevice": {
+			"deviceID": "a device id",
+			"deviceName": "Work Laptop"
+		}
+	}
+}`
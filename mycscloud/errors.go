@@ -0,0 +1,101 @@
+package mycscloud
+
+import (
+	"errors"
+
+	"github.com/hasura/go-graphql-client"
+
+	"github.com/appbricks/mycloudspace-client/api"
+)
+
+// errors returned by the MyCS cloud APIs which
+// can be tested for using errors.Is()
+var (
+	// user is not authorized to perform the request
+	ErrUnauthorized = errors.New("unauthorized")
+	// user's request for access is yet to be approved
+	ErrAccessPending = errors.New("unauthorized(pending)")
+	// requested entity does not exist
+	ErrNotFound = errors.New("not found")
+	// request conflicts with the current
+	// state of the entity being updated
+	ErrConflict = errors.New("conflict")
+	// request was rejected as its input was invalid
+	ErrBadRequest = errors.New("bad request")
+	// device context does not match the device
+	// registered with the MyCS cloud
+	ErrInvalidDeviceContext = errors.New("invalid device context")
+)
+
+// maps the "errorType" of graphql
+// errors to the above error values
+var errorTypes = map[string]error{
+	"Unauthorized":              ErrUnauthorized,
+	"UnauthorizedException":     ErrUnauthorized,
+	"AccessDenied":              ErrUnauthorized,
+	"AccessDeniedException":     ErrUnauthorized,
+	"AccessPending":             ErrAccessPending,
+	"NotFound":                  ErrNotFound,
+	"ResourceNotFoundException": ErrNotFound,
+	"Conflict":                  ErrConflict,
+	"ConditionalCheckFailed":    ErrConflict,
+	"BadRequest":                ErrBadRequest,
+	"BadRequestException":       ErrBadRequest,
+	"ValidationError":           ErrBadRequest,
+	"InvalidDeviceContext":      ErrInvalidDeviceContext,
+}
+
+// an error returned by the MyCS cloud API
+// service or raised by the API client
+type Error struct {
+	// the graphql error type if the
+	// error was returned by the service
+	Type    string
+	Message string
+
+	kind  error
+	cause error
+}
+
+func newError(kind error, message string) *Error {
+	return &Error{
+		Message: message,
+		kind:    kind,
+	}
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return e.cause.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Is(target error) bool {
+	return e.kind != nil && e.kind == target
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// returns the error returned by a graphql query
+// or mutation as an Error. errors that are not
+// graphql error responses are returned as is.
+func apiError(err error) error {
+
+	var (
+		gqlErrors graphql.Errors
+	)
+
+	if err == nil || !errors.As(err, &gqlErrors) || len(gqlErrors) == 0 {
+		return err
+	}
+	errorType := api.ErrorType(err)
+	return &Error{
+		Type:    errorType,
+		Message: gqlErrors[0].Message,
+		kind:    errorTypes[errorType],
+		cause:   err,
+	}
+}
@@ -0,0 +1,166 @@
+package mycscloud_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/appbricks/mycloudspace-client/api"
+	"github.com/appbricks/mycloudspace-client/mycscloud"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	mycs_mocks "github.com/appbricks/mycloudspace-client/test/mocks"
+	test_server "github.com/mevansam/goutils/test/mocks"
+)
+
+var _ = Describe("API Errors", func() {
+
+	var (
+		err error
+		cfg config.Config
+
+		testServer *test_server.MockHttpServer
+		spaceAPI   *mycscloud.SpaceAPI
+	)
+
+	BeforeEach(func() {
+		var testServerUrl string
+
+		cfg, err = mycs_mocks.NewMockConfig(sourceDirPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		testServer, testServerUrl = startTestServer()
+		spaceAPI = mycscloud.NewSpaceAPI(api.NewGraphQLClient(testServerUrl, "", cfg.AuthContext()))
+	})
+
+	AfterEach(func() {
+		testServer.Stop()
+	})
+
+	It("maps the graphql error type to a typed error", func() {
+
+		testServer.PushRequest().
+			ExpectJSONRequest(getSpacesRequest).
+			RespondWith(unauthorizedErrorResponse)
+
+		_, err = spaceAPI.GetSpaces()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Message: not authorized to access getUser, Locations: []"))
+		Expect(errors.Is(err, mycscloud.ErrUnauthorized)).To(BeTrue())
+		Expect(errors.Is(err, mycscloud.ErrNotFound)).To(BeFalse())
+
+		var apiErr *mycscloud.Error
+		Expect(errors.As(err, &apiErr)).To(BeTrue())
+		Expect(apiErr.Type).To(Equal("Unauthorized"))
+		Expect(apiErr.Message).To(Equal("not authorized to access getUser"))
+
+		testServer.PushRequest().
+			ExpectJSONRequest(getSpacesRequest).
+			RespondWith(notFoundErrorResponse)
+
+		_, err = spaceAPI.GetSpaces()
+		Expect(err).To(HaveOccurred())
+		Expect(errors.Is(err, mycscloud.ErrNotFound)).To(BeTrue())
+		Expect(errors.Is(err, mycscloud.ErrUnauthorized)).To(BeFalse())
+
+		Expect(testServer.Done()).To(BeTrue())
+	})
+
+	It("returns untyped errors for unknown graphql error types", func() {
+
+		testServer.PushRequest().
+			ExpectJSONRequest(getSpacesRequest).
+			RespondWith(errorResponse)
+
+		_, err = spaceAPI.GetSpaces()
+		Expect(err).To(HaveOccurred())
+
+		var apiErr *mycscloud.Error
+		Expect(errors.As(err, &apiErr)).To(BeTrue())
+		Expect(apiErr.Type).To(Equal("Error"))
+		Expect(errors.Is(err, mycscloud.ErrUnauthorized)).To(BeFalse())
+		Expect(errors.Is(err, mycscloud.ErrNotFound)).To(BeFalse())
+		Expect(errors.Is(err, mycscloud.ErrConflict)).To(BeFalse())
+
+		Expect(testServer.Done()).To(BeTrue())
+	})
+
+	Context("the service responds with an HTTP error status", func() {
+
+		var (
+			statusServer *httptest.Server
+			status       int
+		)
+
+		BeforeEach(func() {
+			statusServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(status)
+			}))
+			spaceAPI = mycscloud.NewSpaceAPI(api.NewGraphQLClient(statusServer.URL, "", cfg.AuthContext()))
+		})
+
+		AfterEach(func() {
+			statusServer.Close()
+		})
+
+		It("maps an unauthorized status to an unauthorized error", func() {
+			status = http.StatusUnauthorized
+
+			_, err = spaceAPI.GetSpaces()
+			Expect(err).To(HaveOccurred())
+			Expect(errors.Is(err, mycscloud.ErrUnauthorized)).To(BeTrue())
+			Expect(err.Error()).To(Equal("Message: service responded with status '401 Unauthorized', Locations: []"))
+		})
+
+		It("maps a forbidden status to an unauthorized error", func() {
+			status = http.StatusForbidden
+
+			_, err = spaceAPI.GetSpaces()
+			Expect(err).To(HaveOccurred())
+			Expect(errors.Is(err, mycscloud.ErrUnauthorized)).To(BeTrue())
+			Expect(api.ErrorType(err)).To(Equal("AccessDenied"))
+		})
+
+		It("maps a not found status to a not found error", func() {
+			status = http.StatusNotFound
+
+			_, err = spaceAPI.GetSpaces()
+			Expect(err).To(HaveOccurred())
+			Expect(errors.Is(err, mycscloud.ErrNotFound)).To(BeTrue())
+			Expect(errors.Is(err, mycscloud.ErrUnauthorized)).To(BeFalse())
+		})
+
+		It("returns an untyped error for other statuses", func() {
+			status = http.StatusTeapot
+
+			_, err = spaceAPI.GetSpaces()
+			Expect(err).To(HaveOccurred())
+			Expect(api.ErrorType(err)).To(Equal(""))
+			Expect(errors.Is(err, mycscloud.ErrUnauthorized)).To(BeFalse())
+			Expect(errors.Is(err, mycscloud.ErrNotFound)).To(BeFalse())
+		})
+	})
+})
+
+const unauthorizedErrorResponse = `{
+	"data": {},
+	"errors": [
+		{
+			"errorType": "Unauthorized",
+			"message": "not authorized to access getUser"
+		}
+	]
+}`
+
+const notFoundErrorResponse = `{
+	"data": {},
+	"errors": [
+		{
+			"errorType": "NotFound",
+			"message": "user not found"
+		}
+	]
+}`
@@ -2,7 +2,6 @@ package mycscloud
 
 import (
 	"context"
-	"fmt"
 	"strings"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
@@ -14,21 +13,32 @@ import (
 )
 
 type EventPublisher struct {
-	config config.Config
-	apiUrl, 
-	subUrl string
+	config        config.Config
+	serviceConfig api.ServiceConfig
+	subUrl        string
 }
 
 func NewEventPublisher(apiUrl, subUrl string, config config.Config) *EventPublisher {
+	return NewEventPublisherForService(api.ServiceConfig{ApiURL: apiUrl}, subUrl, config)
+}
+
+// returns an event publisher which refreshes the
+// auth context token via the service's token
+// endpoint if it has expired
+func NewEventPublisherForService(serviceConfig api.ServiceConfig, subUrl string, config config.Config) *EventPublisher {
 
 	return &EventPublisher{
-		config: config,
-		apiUrl: apiUrl,
-		subUrl: subUrl,
+		config:        config,
+		serviceConfig: serviceConfig,
+		subUrl:        subUrl,
 	}
 }
 
 func (p *EventPublisher) PostMeasurementEvents(cloudEvents []*cloudevents.Event) ([]events.CloudEventError, error) {
+	return p.PostMeasurementEventsWithContext(context.Background(), cloudEvents)
+}
+
+func (p *EventPublisher) PostMeasurementEventsWithContext(ctx context.Context, cloudEvents []*cloudevents.Event) ([]events.CloudEventError, error) {
 
 	var (
 		err error
@@ -42,10 +52,10 @@ func (p *EventPublisher) PostMeasurementEvents(cloudEvents []*cloudevents.Event)
 		logger.TraceMessage("EventPublisher.PostMeasurementEvents(): Client is not logged in. Measurement events will be not be recorded.")
 		return nil, nil
 	}
-	apiClient := api.NewGraphQLClientNoPool(p.apiUrl, p.subUrl, p.config)
+	apiClient := api.NewGraphQLClientNoPoolForService(ctx, p.serviceConfig, p.config.AuthContext())
 
 	if deviceID, ok = p.config.DeviceContext().GetDeviceID(); !ok {
-		return nil, fmt.Errorf("unable to determine current client's device context")
+		return nil, newError(ErrInvalidDeviceContext, "unable to determine current client's device context")
 	}
 	sourceUrn.WriteString("urn:mycs:device:")
 	sourceUrn.WriteString(deviceID)
@@ -59,9 +69,9 @@ func (p *EventPublisher) PostMeasurementEvents(cloudEvents []*cloudevents.Event)
 	variables := map[string]interface{}{
 		"data": events.CreatePublishEventList(eventSource, cloudEvents),
 	}
-	if err = apiClient.Mutate(context.Background(), &mutation, variables); err != nil {
+	if err = apiClient.Mutate(ctx, &mutation, variables); err != nil {
 		logger.ErrorMessage("EventsAPI.PostMeasurementEvents(): publishData mutation returned an error: %s", err.Error())
-		return nil, err
+		return nil, apiError(err)
 	}
 	logger.TraceMessage("EventsAPI.PostMeasurementEvents(): publishData mutation returned response: %# v", mutation)
 
@@ -2,6 +2,7 @@ package mycscloud_test
 
 import (
 	"encoding/json"
+	"errors"
 	"time"
 
 	"golang.org/x/oauth2"
@@ -77,6 +78,18 @@ var _ = Describe("Event API", func() {
 		Expect(postErrors[0].Error).To(Equal("failed to post event 49504010-9afa-4c3f-b0b8-bef2cc71d4e2"))
 		Expect(postErrors[0].Event.Context.GetID()).To(Equal("49504010-9afa-4c3f-b0b8-bef2cc71d4e2"))
 	})
+
+	It("does not push events without a device context", func() {
+		testServer, testServerUrl := startTestServer()
+		defer testServer.Stop()
+
+		cfg = mocks.NewMockConfig(cfg.AuthContext(), config.NewDeviceContext(), nil)
+		eventPublisher := mycscloud.NewEventPublisher(testServerUrl, "", cfg)
+
+		_, err = eventPublisher.PostMeasurementEvents([]*cloudevents.Event{})
+		Expect(err).To(HaveOccurred())
+		Expect(errors.Is(err, mycscloud.ErrInvalidDeviceContext)).To(BeTrue())
+	})
 })
 
 var testEvents = []string{
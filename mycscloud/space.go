@@ -2,15 +2,23 @@ package mycscloud
 
 import (
 	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
 
+	"github.com/appbricks/cloud-builder/config"
 	"github.com/appbricks/cloud-builder/target"
 	"github.com/appbricks/cloud-builder/userspace"
 	"github.com/hasura/go-graphql-client"
+	"github.com/mevansam/goforms/forms"
 	"github.com/mevansam/goutils/logger"
 )
 
 type SpaceAPI struct {
-	apiClient *graphql.Client
+	apiClient     *graphql.Client
+	cache         *Cache
+	targetContext config.TargetContext
 }
 
 func NewSpaceAPI(apiClient *graphql.Client) *SpaceAPI {
@@ -20,10 +28,32 @@ func NewSpaceAPI(apiClient *graphql.Client) *SpaceAPI {
 	}
 }
 
+// returns the API with its query responses
+// saved to the given offline cache
+func (s *SpaceAPI) WithCache(cache *Cache) *SpaceAPI {
+	s.cache = cache
+	return s
+}
+
+// returns the API with targets whose spaces
+// are renamed re-keyed in the given context
+func (s *SpaceAPI) WithTargetContext(targetContext config.TargetContext) *SpaceAPI {
+	s.targetContext = targetContext
+	return s
+}
+
 func (s *SpaceAPI) AddSpace(
 	tgt *target.Target,
 	isEgressNode bool,
 ) error {
+	return s.AddSpaceWithContext(context.Background(), tgt, isEgressNode)
+}
+
+func (s *SpaceAPI) AddSpaceWithContext(
+	ctx context.Context,
+	tgt *target.Target,
+	isEgressNode bool,
+) error {
 
 	var mutation struct {
 		AddSpace struct {
@@ -44,9 +74,9 @@ func (s *SpaceAPI) AddSpace(
 		"region": graphql.String(*tgt.Provider.Region()),
 		"isEgressNode": graphql.Boolean(isEgressNode),
 	}
-	if err := s.apiClient.Mutate(context.Background(), &mutation, variables); err != nil {
+	if err := s.apiClient.Mutate(ctx, &mutation, variables); err != nil {
 		logger.DebugMessage("SpaceAPI: addSpace mutation returned an error: %s", err.Error())
-		return err
+		return apiError(err)
 	}
 	logger.TraceMessage("SpaceAPI: addSpace mutation returned response: %# v", mutation)
 	
@@ -57,6 +87,10 @@ func (s *SpaceAPI) AddSpace(
 }
 
 func (s *SpaceAPI) DeleteSpace(tgt *target.Target) ([]string, error) {
+	return s.DeleteSpaceWithContext(context.Background(), tgt)
+}
+
+func (s *SpaceAPI) DeleteSpaceWithContext(ctx context.Context, tgt *target.Target) ([]string, error) {
 
 	var mutation struct {
 		DeleteSpace []string `graphql:"deleteSpace(spaceID: $spaceID)"`
@@ -64,9 +98,9 @@ func (s *SpaceAPI) DeleteSpace(tgt *target.Target) ([]string, error) {
 	variables := map[string]interface{}{
 		"spaceID": graphql.ID(tgt.NodeID),
 	}
-	if err := s.apiClient.Mutate(context.Background(), &mutation, variables); err != nil {
+	if err := s.apiClient.Mutate(ctx, &mutation, variables); err != nil {
 		logger.DebugMessage("SpaceAPI: deleteSpace mutation returned an error: %s", err.Error())
-		return nil, err
+		return nil, apiError(err)
 	}
 	logger.TraceMessage("SpaceAPI: deleteSpace mutation returned response: %# v", mutation)
 
@@ -77,7 +111,201 @@ func (s *SpaceAPI) DeleteSpace(tgt *target.Target) ([]string, error) {
 	return userIDs, nil
 }
 
+// fields of a space to update. fields
+// that are nil are not updated.
+type SpaceUpdate struct {
+	SpaceName    *string
+	IsEgressNode *bool
+	Version      *string
+
+	// endpoint of the space's node
+	IPAddress *string
+	FQDN      *string
+	Port      *int
+}
+
+// updates the given fields of the space the given target
+// was deployed as in place so that users' access to the
+// space is retained. if the space is renamed the name of
+// the target's deployment is updated to match. as the
+// target's key changes with its name the target is saved
+// with its new key to the API's target context. if the
+// API does not have a target context the caller needs
+// to re-key the target.
+func (s *SpaceAPI) UpdateSpace(tgt *target.Target, update SpaceUpdate) error {
+	return s.UpdateSpaceWithContext(context.Background(), tgt, update)
+}
+
+func (s *SpaceAPI) UpdateSpaceWithContext(
+	ctx context.Context,
+	tgt *target.Target,
+	update SpaceUpdate,
+) error {
+
+	var (
+		err error
+
+		inputForm forms.InputForm
+	)
+
+	if len(tgt.NodeID) == 0 {
+		return fmt.Errorf("target '%s' has not been added as a space", tgt.Key())
+	}
+	if update.SpaceName != nil {
+		// ensure the target can be renamed
+		// before the space is updated
+		if inputForm, err = tgt.Recipe.InputForm(); err != nil {
+			return err
+		}
+		if _, err = inputForm.GetFieldValue("name"); err != nil {
+			return fmt.Errorf("target '%s' cannot be renamed: %s", tgt.Key(), err.Error())
+		}
+	}
+
+	var mutation struct {
+		UpdateSpace struct {
+			SpaceID      graphql.String `graphql:"spaceID"`
+			SpaceName    graphql.String
+			Version      graphql.String
+			IsEgressNode graphql.Boolean
+			IpAddress    graphql.String
+			Fqdn         graphql.String
+			Port         graphql.Int
+		} `graphql:"updateSpace(spaceID: $spaceID, space: { spaceName: $spaceName, isEgressNode: $isEgressNode, version: $version, ipAddress: $ipAddress, fqdn: $fqdn, port: $port })"`
+	}
+	variables := map[string]interface{}{
+		"spaceID": graphql.ID(tgt.NodeID),
+		"spaceName": (*graphql.String)(nil),
+		"isEgressNode": (*graphql.Boolean)(nil),
+		"version": (*graphql.String)(nil),
+		"ipAddress": (*graphql.String)(nil),
+		"fqdn": (*graphql.String)(nil),
+		"port": (*graphql.Int)(nil),
+	}
+	if update.SpaceName != nil {
+		variables["spaceName"] = graphql.NewString(graphql.String(*update.SpaceName))
+	}
+	if update.IsEgressNode != nil {
+		variables["isEgressNode"] = graphql.NewBoolean(graphql.Boolean(*update.IsEgressNode))
+	}
+	if update.Version != nil {
+		variables["version"] = graphql.NewString(graphql.String(*update.Version))
+	}
+	if update.IPAddress != nil {
+		variables["ipAddress"] = graphql.NewString(graphql.String(*update.IPAddress))
+	}
+	if update.FQDN != nil {
+		variables["fqdn"] = graphql.NewString(graphql.String(*update.FQDN))
+	}
+	if update.Port != nil {
+		variables["port"] = graphql.NewInt(graphql.Int(*update.Port))
+	}
+	if err = s.apiClient.Mutate(ctx, &mutation, variables); err != nil {
+		logger.DebugMessage("SpaceAPI: updateSpace mutation returned an error: %s", err.Error())
+		return apiError(err)
+	}
+	logger.TraceMessage("SpaceAPI: updateSpace mutation returned response: %# v", mutation)
+
+	if update.SpaceName != nil {
+		prevKey := tgt.Key()
+		if err = inputForm.SetFieldValue("name", string(mutation.UpdateSpace.SpaceName)); err != nil {
+			return fmt.Errorf("space was renamed but its target could not be updated: %s", err.Error())
+		}
+		if s.targetContext != nil && prevKey != tgt.Key() {
+			s.targetContext.DeleteTarget(prevKey)
+			s.targetContext.SaveTarget(tgt.Key(), tgt)
+		}
+	}
+	return nil
+}
+
+// renames the space the given target was deployed as
+// along with the target's deployment
+func (s *SpaceAPI) RenameSpace(tgt *target.Target, spaceName string) error {
+	return s.RenameSpaceWithContext(context.Background(), tgt, spaceName)
+}
+
+func (s *SpaceAPI) RenameSpaceWithContext(ctx context.Context, tgt *target.Target, spaceName string) error {
+	return s.UpdateSpaceWithContext(ctx, tgt, SpaceUpdate{SpaceName: &spaceName})
+}
+
+// sets whether the space the given target was deployed
+// as can be used by its users as an egress node
+func (s *SpaceAPI) SetSpaceEgressNode(tgt *target.Target, isEgressNode bool) error {
+	return s.SetSpaceEgressNodeWithContext(context.Background(), tgt, isEgressNode)
+}
+
+func (s *SpaceAPI) SetSpaceEgressNodeWithContext(ctx context.Context, tgt *target.Target, isEgressNode bool) error {
+	return s.UpdateSpaceWithContext(ctx, tgt, SpaceUpdate{IsEgressNode: &isEgressNode})
+}
+
+// updates the version of the space the given target was
+// deployed as with the target's version. this should be
+// called once the target has been upgraded.
+func (s *SpaceAPI) UpdateSpaceVersion(tgt *target.Target) error {
+	return s.UpdateSpaceVersionWithContext(context.Background(), tgt)
+}
+
+func (s *SpaceAPI) UpdateSpaceVersionWithContext(ctx context.Context, tgt *target.Target) error {
+
+	version := tgt.Version()
+	if len(version) == 0 {
+		return fmt.Errorf("target '%s' does not have a version", tgt.Key())
+	}
+	return s.UpdateSpaceWithContext(ctx, tgt, SpaceUpdate{Version: &version})
+}
+
+// updates the endpoint of the space the given target
+// was deployed as with the target's endpoint. this
+// should be called once the target's node has been
+// redeployed with a new address.
+func (s *SpaceAPI) UpdateSpaceEndpoint(tgt *target.Target) error {
+	return s.UpdateSpaceEndpointWithContext(context.Background(), tgt)
+}
+
+func (s *SpaceAPI) UpdateSpaceEndpointWithContext(ctx context.Context, tgt *target.Target) error {
+
+	var (
+		err error
+
+		endpoint    string
+		endpointURL *url.URL
+		port        int
+	)
+
+	if endpoint, err = tgt.GetEndpoint(); err != nil {
+		return err
+	}
+	if endpointURL, err = url.Parse(endpoint); err != nil || len(endpointURL.Hostname()) == 0 {
+		return fmt.Errorf("target '%s' has an invalid endpoint '%s'", tgt.Key(), endpoint)
+	}
+
+	update := SpaceUpdate{}
+	host := endpointURL.Hostname()
+	if net.ParseIP(host) != nil {
+		update.IPAddress = &host
+	} else {
+		update.FQDN = &host
+	}
+	if p := endpointURL.Port(); len(p) > 0 {
+		if port, err = strconv.Atoi(p); err != nil {
+			return fmt.Errorf("target '%s' has an invalid endpoint '%s'", tgt.Key(), endpoint)
+		}
+	} else if endpointURL.Scheme == "http" {
+		port = 80
+	} else {
+		port = 443
+	}
+	update.Port = &port
+
+	return s.UpdateSpaceWithContext(ctx, tgt, update)
+}
+
 func (s *SpaceAPI) GetSpaces() ([]*userspace.Space, error) {
+	return s.GetSpacesWithContext(context.Background())
+}
+
+func (s *SpaceAPI) GetSpacesWithContext(ctx context.Context) ([]*userspace.Space, error) {
 
 	var query struct {
 		GetUser struct {
@@ -109,9 +337,9 @@ func (s *SpaceAPI) GetSpaces() ([]*userspace.Space, error) {
 			}
 		} `graphql:"getUser"`
 	}
-	if err := s.apiClient.Query(context.Background(), &query, map[string]interface{}{}); err != nil {
+	if err := s.cache.query(ctx, s.apiClient, SpacesCacheEntry, &query, map[string]interface{}{}); err != nil {
 		logger.DebugMessage("SpaceAPI: getUsers query to retrieve user's space list returned an error: %s", err.Error())
-		return nil, err
+		return nil, apiError(err)
 	}
 	logger.TraceMessage("SpaceAPI: getUsers query to retrieve user's space list returned response: %# v", query)
 
@@ -1,11 +1,13 @@
 package mycscloud
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/appbricks/cloud-builder/config"
 	"github.com/appbricks/cloud-builder/target"
@@ -27,10 +29,23 @@ type SpaceNodes struct {
 	// remote space targets
 	sharedSpaces []*userspace.Space
 
+	// lookup by key for all remote and local apps
+	apps map[string]*App
+	// lookup by app id for local app targets
+	appTargets map[string]*App
+	// remote apps which are not local targets
+	sharedApps []*App
+
 	// synchronizes async call to get spaces
 	asyncCall      sync.WaitGroup
 	asyncCallError error
 
+	// set if the remote space targets were
+	// read from the offline cache as the
+	// MyCS cloud could not be reached
+	isStale  bool
+	cachedAt time.Time
+
 	// space API clients
 	spaceAPIClients map[string]*apiClientInstance
 	apiClientSync   sync.Mutex
@@ -50,14 +65,35 @@ func NewSpaceNodes(config config.Config) *SpaceNodes {
 		spaceNodeByEndpoint: make(map[string]userspace.SpaceNode),
 		sharedSpaces:        []*userspace.Space{},
 
+		apps:       make(map[string]*App),
+		appTargets: make(map[string]*App),
+		sharedApps: []*App{},
+
 		spaceAPIClients: make(map[string]*apiClientInstance),
 	}
 	_ = sn.consolidateRemoteAndLocalNodes(config)
 	return sn
 }
 
-// load all owned and shared spaces
 func GetSpaceNodes(config config.Config, apiUrl string) (*SpaceNodes, error) {
+	return GetSpaceNodesForService(config, api.ServiceConfig{ApiURL: apiUrl})
+}
+
+// load all owned and shared spaces. the auth
+// context token is refreshed via the service's
+// token endpoint if it has expired.
+func GetSpaceNodesForService(config config.Config, serviceConfig api.ServiceConfig) (*SpaceNodes, error) {
+	return GetSpaceNodesWithContext(context.Background(), config, serviceConfig)
+}
+
+// load all owned and shared spaces. the spaces
+// are loaded asynchronously and the load is
+// cancelled if the given context is cancelled.
+func GetSpaceNodesWithContext(
+	ctx context.Context,
+	config config.Config,
+	serviceConfig api.ServiceConfig,
+) (*SpaceNodes, error) {
 
 	var (
 		err error
@@ -69,6 +105,10 @@ func GetSpaceNodes(config config.Config, apiUrl string) (*SpaceNodes, error) {
 		spaceNodes:          make(map[string]userspace.SpaceNode),
 		spaceNodeByEndpoint: make(map[string]userspace.SpaceNode),
 
+		apps:       make(map[string]*App),
+		appTargets: make(map[string]*App),
+		sharedApps: []*App{},
+
 		spaceAPIClients: make(map[string]*apiClientInstance),
 	}
 
@@ -76,8 +116,14 @@ func GetSpaceNodes(config config.Config, apiUrl string) (*SpaceNodes, error) {
 	go func() {
 		defer sn.asyncCall.Done()
 
-		spaceAPI := NewSpaceAPI(api.NewGraphQLClient(apiUrl, "", config.AuthContext()))
-		sn.sharedSpaces, sn.asyncCallError = spaceAPI.GetSpaces()
+		cache, cacheErr := NewCacheForConfig(config)
+		if cacheErr != nil {
+			logger.DebugMessage("GetSpaceNodes(): Spaces will not be cached: %s", cacheErr.Error())
+		}
+		spaceAPI := NewSpaceAPI(api.NewGraphQLClientForService(ctx, serviceConfig, config.AuthContext())).WithCache(cache)
+		if sn.sharedSpaces, sn.asyncCallError = spaceAPI.GetSpacesWithContext(ctx); sn.asyncCallError == nil && cache != nil {
+			sn.isStale, sn.cachedAt = cache.IsStale(SpacesCacheEntry)
+		}
 	}()
 
 	if err = sn.consolidateRemoteAndLocalNodes(config); err != nil {
@@ -121,6 +167,12 @@ func (sn *SpaceNodes) consolidateRemoteAndLocalNodes(config config.Config) error
 			} else {
 				logger.DebugMessage("SpaceNodes.consolidateRemoteAndLocalNodes(): Failed to load remote state for target: %s", t.Key())
 			}
+		} else {
+			app := appFromTarget(t)
+			if len(t.NodeID) > 0 {
+				sn.appTargets[t.NodeID] = app
+			}
+			sn.apps[app.Key()] = app
 		}
 	}
 
@@ -163,6 +215,13 @@ func (sn *SpaceNodes) consolidateRemoteAndLocalNodes(config config.Config) error
 	return nil
 }
 
+// returns whether the remote space nodes were loaded 
+// from the offline cache as the MyCS cloud could not 
+// be reached and when they were cached
+func (sn *SpaceNodes) IsStale() (bool, time.Time) {
+	return sn.isStale, sn.cachedAt
+}
+
 func (sn *SpaceNodes) LookupSpace(key string) userspace.SpaceNode {
 	return sn.spaceNodes[key]
 }
@@ -239,3 +298,132 @@ func (sn *SpaceNodes) GetAllSpaces() []userspace.SpaceNode {
 func (sn *SpaceNodes) GetSharedSpaces() []*userspace.Space {
 	return sn.sharedSpaces
 }
+
+// retrieves the apps deployed to all spaces the user
+// has access to and merges them with the local app
+// targets. apps which are local targets are updated
+// with the space, status and users of the remote app.
+func (sn *SpaceNodes) LoadApps(serviceConfig api.ServiceConfig) error {
+	return sn.LoadAppsWithContext(context.Background(), serviceConfig)
+}
+
+func (sn *SpaceNodes) LoadAppsWithContext(ctx context.Context, serviceConfig api.ServiceConfig) error {
+
+	var (
+		err error
+
+		apps []*App
+	)
+
+	// local and remote nodes may refer to the same
+	// space so apps are retrieved once per space id
+	spaceIDs := []string{}
+	hasSpaceID := make(map[string]bool)
+	for _, node := range sn.spaceNodes {
+		if space, isRemote := node.(*userspace.Space); isRemote && space.AccessStatus != SpaceAccessActive {
+			continue
+		}
+		if spaceID := node.GetSpaceID(); len(spaceID) > 0 && !hasSpaceID[spaceID] {
+			hasSpaceID[spaceID] = true
+			spaceIDs = append(spaceIDs, spaceID)
+		}
+	}
+	sort.Strings(spaceIDs)
+
+	appAPI := NewAppAPI(api.NewGraphQLClientForService(ctx, serviceConfig, sn.config.AuthContext()))
+	sharedApps := []*App{}
+	for _, spaceID := range spaceIDs {
+		if apps, err = appAPI.GetAppsWithContext(ctx, spaceID); err != nil {
+			return err
+		}
+		for _, app := range apps {
+			if appTarget, isTarget := sn.appTargets[app.AppID]; isTarget {
+				appTarget.SpaceID = app.SpaceID
+				appTarget.Status = app.Status
+				appTarget.Users = app.Users
+				if len(appTarget.Endpoint) == 0 {
+					appTarget.Endpoint = app.Endpoint
+				}
+			} else {
+				sharedApps = append(sharedApps, app)
+			}
+		}
+	}
+
+	// replace previously loaded remote apps
+	for _, app := range sn.sharedApps {
+		delete(sn.apps, app.Key())
+	}
+	for _, app := range sharedApps {
+		sn.apps[app.Key()] = app
+	}
+	sn.sharedApps = sharedApps
+	return nil
+}
+
+func (sn *SpaceNodes) LookupApp(key string) *App {
+	return sn.apps[key]
+}
+
+// returns all local app targets and the remote
+// apps loaded via LoadApps sorted by name
+func (sn *SpaceNodes) GetAllApps() []*App {
+
+	apps := make([]*App, 0, len(sn.apps))
+	for _, app := range sn.apps {
+		apps = append(apps, app)
+	}
+	sortApps(apps)
+	return apps
+}
+
+// returns the apps deployed to the given space
+func (sn *SpaceNodes) GetAppsForSpace(space userspace.SpaceNode) []*App {
+
+	apps := []*App{}
+	if spaceID := space.GetSpaceID(); len(spaceID) > 0 {
+		for _, app := range sn.apps {
+			if app.SpaceID == spaceID {
+				apps = append(apps, app)
+			}
+		}
+	}
+	sortApps(apps)
+	return apps
+}
+
+// returns the remote apps which are
+// not managed by this client
+func (sn *SpaceNodes) GetSharedApps() []*App {
+	return sn.sharedApps
+}
+
+func appFromTarget(t *target.Target) *App {
+
+	app := &App{
+		AppID:    t.NodeID,
+		AppName:  t.DeploymentName(),
+		Cookbook: t.CookbookName,
+		Recipe:   t.RecipeName,
+		IaaS:     t.RecipeIaas,
+		Target:   t,
+	}
+	if region := t.Provider.Region(); region != nil {
+		app.Region = *region
+	}
+	if t.Error() == nil {
+		if endpoint, err := t.GetEndpoint(); err == nil {
+			app.Endpoint = endpoint
+		}
+	}
+	return app
+}
+
+func sortApps(apps []*App) {
+	sort.Slice(apps, func(i, j int) bool {
+		if apps[i].AppName == apps[j].AppName {
+			return apps[i].Key() < apps[j].Key()
+		}
+		return apps[i].AppName < apps[j].AppName
+	})
+}
@@ -1,6 +1,15 @@
 package mycscloud_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"golang.org/x/oauth2"
+
 	"github.com/appbricks/cloud-builder/config"
 	"github.com/appbricks/cloud-builder/target"
 	"github.com/appbricks/mycloudspace-client/api"
@@ -95,6 +104,59 @@ var _ = Describe("Space API", func() {
 		Expect(testServer.Done()).To(BeTrue())
 	})
 
+	It("updates a space in place", func() {
+		testServer, spaceAPI := startMockNodeService()
+		defer testServer.Stop()
+
+		testServer.PushRequest().
+			ExpectJSONRequest(updateSpaceEgressRequest).
+			RespondWith(errorResponse)
+
+		err = spaceAPI.SetSpaceEgressNode(tgt, false)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Message: a test error occurred, Locations: []"))
+
+		testServer.PushRequest().
+			ExpectJSONRequest(updateSpaceEgressRequest).
+			RespondWith(updateSpaceEgressResponse)
+
+		err = spaceAPI.SetSpaceEgressNode(tgt, false)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(testServer.Done()).To(BeTrue())
+
+		// the target's recipe does not have
+		// a name so it cannot be renamed
+		err = spaceAPI.RenameSpace(tgt, "new space name")
+		Expect(err).To(HaveOccurred())
+
+		// the target is renamed along with the space
+		simpleTgt, err := cfg.TargetContext().GetTarget("test-simple-deployment/testsimple1")
+		Expect(err).ToNot(HaveOccurred())
+
+		testServer.PushRequest().
+			ExpectJSONRequest(renameSpaceRequest).
+			RespondWith(renameSpaceResponse)
+
+		err = spaceAPI.WithTargetContext(cfg.TargetContext()).RenameSpace(simpleTgt, "renamed-deployment")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(simpleTgt.DeploymentName()).To(Equal("renamed-deployment"))
+		Expect(testServer.Done()).To(BeTrue())
+
+		// the renamed target is saved with its new key
+		targetKeys := []string{}
+		for _, t := range cfg.TargetContext().TargetSet().GetTargets() {
+			targetKeys = append(targetKeys, t.Key())
+		}
+		Expect(targetKeys).To(ContainElement(simpleTgt.Key()))
+		Expect(targetKeys).ToNot(ContainElement("test-simple-deployment/testsimple1"))
+
+		// a target that has not been
+		// added cannot be updated
+		tgt.NodeID = ""
+		err = spaceAPI.SetSpaceEgressNode(tgt, true)
+		Expect(err).To(HaveOccurred())
+	})
+
 	It("retrieves user's spaces", func() {
 		testServer, spaceAPI := startMockNodeService()
 		defer testServer.Stop()
@@ -136,6 +198,18 @@ var _ = Describe("Space API", func() {
 		Expect(testServer.Done()).To(BeTrue())
 	})
 
+	It("stops retrieving user's spaces when the request is cancelled", func() {
+		testServer, spaceAPI := startMockNodeService()
+		defer testServer.Stop()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err = spaceAPI.GetSpacesWithContext(ctx)
+		Expect(err).To(HaveOccurred())
+		Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+	})
+
 	It("retrieves user's space nodes", func() {
 		testServer, _ := startMockNodeService()
 		defer testServer.Stop()
@@ -165,6 +239,130 @@ var _ = Describe("Space API", func() {
 		Expect(spaceNode).NotTo(BeNil())
 		Expect(spaceNode.GetSpaceID()).To(Equal("aa4ea679-ee74-4de6-852c-ccf7636bf644"))
 	})
+
+	It("stops retrieving user's space nodes when the context is cancelled", func() {
+		testServer, _ := startMockNodeService()
+		defer testServer.Stop()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err = mycscloud.GetSpaceNodesWithContext(ctx, cfg, api.ServiceConfig{ApiURL: testServerUrl})
+		Expect(err).To(HaveOccurred())
+		Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+	})
+
+	It("refreshes an expired token used to retrieve user's space nodes", func() {
+		testServer, _ := startMockNodeService()
+		defer testServer.Stop()
+
+		refreshCount := 0
+		tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+			Expect(r.ParseForm()).To(Succeed())
+			Expect(r.Form.Get("grant_type")).To(Equal("refresh_token"))
+			Expect(r.Form.Get("refresh_token")).To(Equal("mock refresh token"))
+
+			refreshCount++
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w,
+				`{"access_token":"refreshed access token","id_token":"mock authorization token","token_type":"Bearer","expires_in":3600}`,
+			)
+		}))
+		defer tokenServer.Close()
+
+		// the test server only accepts
+		// the refreshed id token
+		cfg.AuthContext().SetToken(
+			(&oauth2.Token{
+				AccessToken:  "expired access token",
+				RefreshToken: "mock refresh token",
+				Expiry:       time.Now().Add(-time.Minute),
+			}).WithExtra(
+				map[string]interface{}{
+					"id_token": "expired id token",
+				},
+			),
+		)
+
+		testServer.PushRequest().
+			ExpectJSONRequest(getSpaceNodesRequest).
+			RespondWith(getSpaceNodesResponse)
+
+		_, err = mycscloud.GetSpaceNodesForService(cfg, api.ServiceConfig{
+			CliendID: "mock client id",
+			TokenURL: tokenServer.URL + "/token",
+			ApiURL:   testServerUrl,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(testServer.Done()).To(BeTrue())
+		Expect(refreshCount).To(Equal(1))
+
+		token := cfg.AuthContext().GetToken()
+		Expect(token.Extra("id_token")).To(Equal("mock authorization token"))
+		Expect(token.Expiry.After(time.Now())).To(BeTrue())
+	})
+
+	It("merges local app targets with the apps deployed to the user's spaces", func() {
+		testServer, _ := startMockNodeService()
+		defer testServer.Stop()
+
+		testServer.PushRequest().
+			ExpectJSONRequest(getSpaceNodesRequest).
+			RespondWith(getSpaceNodesResponse)
+
+		spaceNodes, err := mycscloud.GetSpaceNodes(cfg, testServerUrl)
+		Expect(err).ToNot(HaveOccurred())
+
+		// only local app targets are known
+		// until the remote apps are loaded
+		apps := spaceNodes.GetAllApps()
+		Expect(len(apps)).To(Equal(1))
+		Expect(apps[0].Key()).To(Equal("test-simple-deployment/testsimple1"))
+		Expect(apps[0].Target).ToNot(BeNil())
+		Expect(apps[0].SpaceID).To(Equal(""))
+
+		// apps are retrieved once for each
+		// space in the order of their ids
+		testServer.PushRequest().
+			ExpectJSONRequest(fmt.Sprintf(getSpaceAppsRequest, "1d2a49d7-330b-4beb-a102-33049869e472")).
+			RespondWith(getNoAppsResponse)
+		testServer.PushRequest().
+			ExpectJSONRequest(getAppsRequest).
+			RespondWith(getAppsResponse)
+		testServer.PushRequest().
+			ExpectJSONRequest(fmt.Sprintf(getSpaceAppsRequest, "aa4ea679-ee74-4de6-852c-ccf7636bf644")).
+			RespondWith(getNoAppsResponse)
+		testServer.PushRequest().
+			ExpectJSONRequest(fmt.Sprintf(getSpaceAppsRequest, "ad601f92-e073-4dfb-8e48-d97acde8e3fc")).
+			RespondWith(getNoAppsResponse)
+
+		err = spaceNodes.LoadApps(api.ServiceConfig{ApiURL: testServerUrl})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(testServer.Done()).To(BeTrue())
+
+		apps = spaceNodes.GetAllApps()
+		Expect(len(apps)).To(Equal(2))
+		Expect(apps[0].Key()).To(Equal("test-simple-deployment/testsimple1"))
+		Expect(apps[0].SpaceID).To(Equal("1d812616-5955-4bc6-8b67-ec3f0f12a756"))
+		Expect(apps[0].Status).To(Equal("running"))
+		Expect(len(apps[0].Users)).To(Equal(2))
+		Expect(apps[1].Key()).To(Equal("a9f3c1d2-0b7e-4c55-9a3e-5f1d2c3b4a5e"))
+		Expect(apps[1].Target).To(BeNil())
+
+		sharedApps := spaceNodes.GetSharedApps()
+		Expect(len(sharedApps)).To(Equal(1))
+		Expect(sharedApps[0].AppName).To(Equal("wiki"))
+
+		app := spaceNodes.LookupApp("a9f3c1d2-0b7e-4c55-9a3e-5f1d2c3b4a5e")
+		Expect(app).ToNot(BeNil())
+		Expect(app.Endpoint).To(Equal("https://wiki.test.local"))
+
+		apps = spaceNodes.GetAppsForSpace(spaceNodes.LookupSpace("aa/cookbook"))
+		Expect(len(apps)).To(Equal(2))
+		apps = spaceNodes.GetAppsForSpace(spaceNodes.LookupSpace("space2"))
+		Expect(len(apps)).To(Equal(0))
+	})
 })
 
 const addSpaceRequest = `{
@@ -207,6 +405,58 @@ const deleteSpaceResponse = `{
 	}
 }`
 
+const updateSpaceEgressRequest = `{
+	"query": "mutation ($fqdn:String$ipAddress:String$isEgressNode:Boolean$port:Int$spaceID:ID!$spaceName:String$version:String){updateSpace(spaceID: $spaceID, space: { spaceName: $spaceName, isEgressNode: $isEgressNode, version: $version, ipAddress: $ipAddress, fqdn: $fqdn, port: $port }){spaceID,spaceName,version,isEgressNode,ipAddress,fqdn,port}}",
+	"variables": {
+		"spaceID": "1d812616-5955-4bc6-8b67-ec3f0f12a756",
+		"spaceName": null,
+		"isEgressNode": false,
+		"version": null,
+		"ipAddress": null,
+		"fqdn": null,
+		"port": null
+	}
+}`
+const updateSpaceEgressResponse = `{
+	"data": {
+		"updateSpace": {
+			"spaceID": "1d812616-5955-4bc6-8b67-ec3f0f12a756",
+			"spaceName": "NONAME",
+			"version": "dev",
+			"isEgressNode": false,
+			"ipAddress": "1.1.1.1",
+			"fqdn": "aa.mycs.appbricks.org",
+			"port": 443
+		}
+	}
+}`
+
+const renameSpaceRequest = `{
+	"query": "mutation ($fqdn:String$ipAddress:String$isEgressNode:Boolean$port:Int$spaceID:ID!$spaceName:String$version:String){updateSpace(spaceID: $spaceID, space: { spaceName: $spaceName, isEgressNode: $isEgressNode, version: $version, ipAddress: $ipAddress, fqdn: $fqdn, port: $port }){spaceID,spaceName,version,isEgressNode,ipAddress,fqdn,port}}",
+	"variables": {
+		"spaceID": "126e0de1-d422-4200-9486-25b108d6cc8d",
+		"spaceName": "renamed-deployment",
+		"isEgressNode": null,
+		"version": null,
+		"ipAddress": null,
+		"fqdn": null,
+		"port": null
+	}
+}`
+const renameSpaceResponse = `{
+	"data": {
+		"updateSpace": {
+			"spaceID": "126e0de1-d422-4200-9486-25b108d6cc8d",
+			"spaceName": "renamed-deployment",
+			"version": "dev",
+			"isEgressNode": true,
+			"ipAddress": "2.2.2.2",
+			"fqdn": "simple.mycs.appbricks.org",
+			"port": 443
+		}
+	}
+}`
+
 const getSpacesRequest = `{
 	"query": "{getUser{spaces{spaceUsers{space{spaceID,spaceName,publicKey,cookbook,recipe,iaas,region,version,isEgressNode,ipAddress,fqdn,port,vpnType,localCARoot,status,lastSeen},isOwner,isAdmin,canUseSpaceForEgress,status}}}}"
 }`
@@ -321,4 +571,17 @@ const getSpaceNodesResponse = `{
 			}
 		}
 	}
-}`
\ No newline at end of file
+}`
+const getSpaceAppsRequest = `{
+	"query": "query ($spaceID:ID!){getSpace(spaceID: $spaceID){apps{appID,appName,cookbook,recipe,iaas,region,status,endpoint,users{appUsers{user{userID,userName,firstName,middleName,familyName}}}}}}",
+	"variables": {
+		"spaceID": "%s"
+	}
+}`
+const getNoAppsResponse = `{
+	"data": {
+		"getSpace": {
+			"apps": []
+		}
+	}
+}`
@@ -0,0 +1,359 @@
+package mycscloud
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/appbricks/cloud-builder/userspace"
+	"github.com/hasura/go-graphql-client"
+	"github.com/mevansam/goutils/logger"
+)
+
+// status of a user's access to a space
+const (
+	SpaceAccessActive   = "active"
+	SpaceAccessPending  = "pending"
+	SpaceAccessInactive = "inactive"
+)
+
+// a user who is a member of or has
+// been invited to join a space
+type SpaceMember struct {
+	*userspace.User
+
+	IsOwner,
+	IsAdmin,
+	CanUseForEgress bool
+
+	// one of SpaceAccessActive, SpaceAccessPending
+	// if the user has been invited but has not yet
+	// accepted or SpaceAccessInactive
+	Status string
+
+	// time a pending invitation expires at. this
+	// is zero if the invitation does not expire.
+	InviteExpiresAt time.Time
+}
+
+// returns whether the member's invitation to
+// the space is pending and has not expired
+func (m *SpaceMember) IsInvitePending() bool {
+	return m.Status == SpaceAccessPending &&
+		(m.InviteExpiresAt.IsZero() || m.InviteExpiresAt.After(time.Now()))
+}
+
+// an invitation to the logged in
+// user to join a space
+type SpaceInvitation struct {
+	SpaceID,
+	SpaceName string
+
+	IsAdmin,
+	CanUseForEgress bool
+
+	// zero if the invitation does not expire
+	ExpiresAt time.Time
+}
+
+// fields of a space user returned by
+// queries and mutations of space users
+type spaceUserFields struct {
+	User struct {
+		UserID     graphql.String `graphql:"userID"`
+		UserName   graphql.String
+		FirstName  graphql.String
+		MiddleName graphql.String
+		FamilyName graphql.String
+	}
+	IsOwner         graphql.Boolean
+	IsAdmin         graphql.Boolean
+	CanUseForEgress graphql.Boolean `graphql:"canUseSpaceForEgress"`
+	Status          graphql.String
+	InviteExpiresAt graphql.Float
+}
+
+func (f *spaceUserFields) spaceMember() *SpaceMember {
+	return &SpaceMember{
+		User: &userspace.User{
+			UserID:     string(f.User.UserID),
+			Name:       string(f.User.UserName),
+			FirstName:  string(f.User.FirstName),
+			MiddleName: string(f.User.MiddleName),
+			FamilyName: string(f.User.FamilyName),
+		},
+		IsOwner:         bool(f.IsOwner),
+		IsAdmin:         bool(f.IsAdmin),
+		CanUseForEgress: bool(f.CanUseForEgress),
+		Status:          string(f.Status),
+		InviteExpiresAt: timeFromMillis(f.InviteExpiresAt),
+	}
+}
+
+// invites the given user to join the given space with the
+// given permissions. the invitation expires after the given
+// duration or does not expire if the duration is 0.
+func (s *SpaceAPI) InviteSpaceUser(
+	spaceID, userID string,
+	isAdmin, canUseForEgress bool,
+	expiresIn time.Duration,
+) (*SpaceMember, error) {
+	return s.InviteSpaceUserWithContext(context.Background(), spaceID, userID, isAdmin, canUseForEgress, expiresIn)
+}
+
+func (s *SpaceAPI) InviteSpaceUserWithContext(
+	ctx context.Context,
+	spaceID, userID string,
+	isAdmin, canUseForEgress bool,
+	expiresIn time.Duration,
+) (*SpaceMember, error) {
+
+	if expiresIn < 0 {
+		return nil, fmt.Errorf("an invitation cannot expire in the past")
+	}
+
+	var mutation struct {
+		InviteSpaceUser spaceUserFields `graphql:"inviteSpaceUser(spaceID: $spaceID, userID: $userID, isAdmin: $isAdmin, canUseSpaceForEgress: $canUseSpaceForEgress, expiresAt: $expiresAt)"`
+	}
+	variables := map[string]interface{}{
+		"spaceID":              graphql.ID(spaceID),
+		"userID":               graphql.ID(userID),
+		"isAdmin":              graphql.Boolean(isAdmin),
+		"canUseSpaceForEgress": graphql.Boolean(canUseForEgress),
+		"expiresAt":            (*graphql.Float)(nil),
+	}
+	if expiresIn > 0 {
+		variables["expiresAt"] = graphql.NewFloat(graphql.Float(time.Now().Add(expiresIn).UnixMilli()))
+	}
+	if err := s.apiClient.Mutate(ctx, &mutation, variables); err != nil {
+		logger.ErrorMessage("SpaceAPI.InviteSpaceUser(): inviteSpaceUser mutation returned an error: %s", err.Error())
+		return nil, apiError(err)
+	}
+	logger.TraceMessage("SpaceAPI.InviteSpaceUser(): inviteSpaceUser mutation returned response: %# v", mutation)
+	return mutation.InviteSpaceUser.spaceMember(), nil
+}
+
+// returns the members of the given space including
+// users whose invitations are pending. only the
+// space's owner and admins can list its members.
+func (s *SpaceAPI) GetSpaceMembers(spaceID string) ([]*SpaceMember, error) {
+	return s.GetSpaceMembersWithContext(context.Background(), spaceID)
+}
+
+func (s *SpaceAPI) GetSpaceMembersWithContext(ctx context.Context, spaceID string) ([]*SpaceMember, error) {
+
+	var query struct {
+		GetSpace struct {
+			Users struct {
+				SpaceUsers []spaceUserFields
+			}
+		} `graphql:"getSpace(spaceID: $spaceID)"`
+	}
+	variables := map[string]interface{}{
+		"spaceID": graphql.ID(spaceID),
+	}
+	if err := s.apiClient.Query(ctx, &query, variables); err != nil {
+		logger.ErrorMessage("SpaceAPI.GetSpaceMembers(): getSpace query returned an error: %s", err.Error())
+		return nil, apiError(err)
+	}
+	logger.TraceMessage("SpaceAPI.GetSpaceMembers(): getSpace query returned response: %# v", query)
+
+	members := make([]*SpaceMember, 0, len(query.GetSpace.Users.SpaceUsers))
+	for _, spaceUser := range query.GetSpace.Users.SpaceUsers {
+		members = append(members, spaceUser.spaceMember())
+	}
+	return members, nil
+}
+
+// returns the users invited to the given space whose
+// invitations are pending and have not expired
+func (s *SpaceAPI) GetPendingSpaceInvitations(spaceID string) ([]*SpaceMember, error) {
+	return s.GetPendingSpaceInvitationsWithContext(context.Background(), spaceID)
+}
+
+func (s *SpaceAPI) GetPendingSpaceInvitationsWithContext(ctx context.Context, spaceID string) ([]*SpaceMember, error) {
+
+	var (
+		err error
+
+		members []*SpaceMember
+	)
+
+	if members, err = s.GetSpaceMembersWithContext(ctx, spaceID); err != nil {
+		return nil, err
+	}
+	invited := []*SpaceMember{}
+	for _, member := range members {
+		if member.IsInvitePending() {
+			invited = append(invited, member)
+		}
+	}
+	return invited, nil
+}
+
+// returns the pending invitations of the
+// logged in user to join spaces which
+// have not expired
+func (s *SpaceAPI) GetSpaceInvitations() ([]*SpaceInvitation, error) {
+	return s.GetSpaceInvitationsWithContext(context.Background())
+}
+
+func (s *SpaceAPI) GetSpaceInvitationsWithContext(ctx context.Context) ([]*SpaceInvitation, error) {
+
+	var query struct {
+		GetUser struct {
+			Spaces struct {
+				SpaceUsers []struct {
+					Space struct {
+						SpaceID   graphql.String `graphql:"spaceID"`
+						SpaceName graphql.String
+					}
+					IsAdmin         graphql.Boolean
+					CanUseForEgress graphql.Boolean `graphql:"canUseSpaceForEgress"`
+					Status          graphql.String
+					InviteExpiresAt graphql.Float
+				}
+			}
+		} `graphql:"getUser"`
+	}
+	if err := s.apiClient.Query(ctx, &query, map[string]interface{}{}); err != nil {
+		logger.ErrorMessage("SpaceAPI.GetSpaceInvitations(): getUser query to retrieve user's space invitations returned an error: %s", err.Error())
+		return nil, apiError(err)
+	}
+	logger.TraceMessage("SpaceAPI.GetSpaceInvitations(): getUser query to retrieve user's space invitations returned response: %# v", query)
+
+	invitations := []*SpaceInvitation{}
+	for _, spaceUser := range query.GetUser.Spaces.SpaceUsers {
+		member := SpaceMember{
+			Status:          string(spaceUser.Status),
+			InviteExpiresAt: timeFromMillis(spaceUser.InviteExpiresAt),
+		}
+		if member.IsInvitePending() {
+			invitations = append(invitations, &SpaceInvitation{
+				SpaceID:         string(spaceUser.Space.SpaceID),
+				SpaceName:       string(spaceUser.Space.SpaceName),
+				IsAdmin:         bool(spaceUser.IsAdmin),
+				CanUseForEgress: bool(spaceUser.CanUseForEgress),
+				ExpiresAt:       member.InviteExpiresAt,
+			})
+		}
+	}
+	return invitations, nil
+}
+
+// accepts the logged in user's
+// invitation to join the given space
+func (s *SpaceAPI) AcceptSpaceInvitation(spaceID string) error {
+	return s.AcceptSpaceInvitationWithContext(context.Background(), spaceID)
+}
+
+func (s *SpaceAPI) AcceptSpaceInvitationWithContext(ctx context.Context, spaceID string) error {
+
+	var mutation struct {
+		AcceptSpaceUserInvitation struct {
+			Status graphql.String
+		} `graphql:"acceptSpaceUserInvitation(spaceID: $spaceID)"`
+	}
+	variables := map[string]interface{}{
+		"spaceID": graphql.ID(spaceID),
+	}
+	if err := s.apiClient.Mutate(ctx, &mutation, variables); err != nil {
+		logger.ErrorMessage("SpaceAPI.AcceptSpaceInvitation(): acceptSpaceUserInvitation mutation returned an error: %s", err.Error())
+		return apiError(err)
+	}
+	logger.TraceMessage("SpaceAPI.AcceptSpaceInvitation(): acceptSpaceUserInvitation mutation returned response: %# v", mutation)
+	return nil
+}
+
+// declines the logged in user's
+// invitation to join the given space
+func (s *SpaceAPI) DeclineSpaceInvitation(spaceID string) error {
+	return s.DeclineSpaceInvitationWithContext(context.Background(), spaceID)
+}
+
+func (s *SpaceAPI) DeclineSpaceInvitationWithContext(ctx context.Context, spaceID string) error {
+
+	var mutation struct {
+		DeclineSpaceUserInvitation struct {
+			Status graphql.String
+		} `graphql:"declineSpaceUserInvitation(spaceID: $spaceID)"`
+	}
+	variables := map[string]interface{}{
+		"spaceID": graphql.ID(spaceID),
+	}
+	if err := s.apiClient.Mutate(ctx, &mutation, variables); err != nil {
+		logger.ErrorMessage("SpaceAPI.DeclineSpaceInvitation(): declineSpaceUserInvitation mutation returned an error: %s", err.Error())
+		return apiError(err)
+	}
+	logger.TraceMessage("SpaceAPI.DeclineSpaceInvitation(): declineSpaceUserInvitation mutation returned response: %# v", mutation)
+	return nil
+}
+
+// changes whether the given member of the given space
+// is an admin of the space and can use it for egress
+func (s *SpaceAPI) UpdateSpaceUser(spaceID, userID string, isAdmin, canUseForEgress bool) (*SpaceMember, error) {
+	return s.UpdateSpaceUserWithContext(context.Background(), spaceID, userID, isAdmin, canUseForEgress)
+}
+
+func (s *SpaceAPI) UpdateSpaceUserWithContext(
+	ctx context.Context,
+	spaceID, userID string,
+	isAdmin, canUseForEgress bool,
+) (*SpaceMember, error) {
+
+	var mutation struct {
+		UpdateSpaceUser spaceUserFields `graphql:"updateSpaceUser(spaceID: $spaceID, userID: $userID, isAdmin: $isAdmin, canUseSpaceForEgress: $canUseSpaceForEgress)"`
+	}
+	variables := map[string]interface{}{
+		"spaceID":              graphql.ID(spaceID),
+		"userID":               graphql.ID(userID),
+		"isAdmin":              graphql.Boolean(isAdmin),
+		"canUseSpaceForEgress": graphql.Boolean(canUseForEgress),
+	}
+	if err := s.apiClient.Mutate(ctx, &mutation, variables); err != nil {
+		logger.ErrorMessage("SpaceAPI.UpdateSpaceUser(): updateSpaceUser mutation returned an error: %s", err.Error())
+		return nil, apiError(err)
+	}
+	logger.TraceMessage("SpaceAPI.UpdateSpaceUser(): updateSpaceUser mutation returned response: %# v", mutation)
+	return mutation.UpdateSpaceUser.spaceMember(), nil
+}
+
+// revokes the given user's access to the given
+// space or their invitation to join it if it
+// is still pending
+func (s *SpaceAPI) RevokeSpaceUser(spaceID, userID string) error {
+	return s.RevokeSpaceUserWithContext(context.Background(), spaceID, userID)
+}
+
+func (s *SpaceAPI) RevokeSpaceUserWithContext(ctx context.Context, spaceID, userID string) error {
+
+	var mutation struct {
+		DeleteSpaceUser struct {
+			Space struct {
+				SpaceID graphql.String `graphql:"spaceID"`
+			}
+			User struct {
+				UserID graphql.String `graphql:"userID"`
+			}
+		} `graphql:"deleteSpaceUser(spaceID: $spaceID, userID: $userID)"`
+	}
+	variables := map[string]interface{}{
+		"spaceID": graphql.ID(spaceID),
+		"userID":  graphql.ID(userID),
+	}
+	if err := s.apiClient.Mutate(ctx, &mutation, variables); err != nil {
+		logger.ErrorMessage("SpaceAPI.RevokeSpaceUser(): deleteSpaceUser mutation returned an error: %s", err.Error())
+		return apiError(err)
+	}
+	logger.TraceMessage("SpaceAPI.RevokeSpaceUser(): deleteSpaceUser mutation returned response: %# v", mutation)
+	return nil
+}
+
+// times are returned by the MyCS cloud
+// as milliseconds since the epoch
+func timeFromMillis(millis graphql.Float) time.Time {
+	if millis == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(int64(millis))
+}
@@ -0,0 +1,321 @@
+package mycscloud_test
+
+import (
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/appbricks/mycloudspace-client/api"
+	"github.com/appbricks/mycloudspace-client/mycscloud"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	mycs_mocks "github.com/appbricks/mycloudspace-client/test/mocks"
+	test_server "github.com/mevansam/goutils/test/mocks"
+)
+
+var _ = Describe("Space Users API", func() {
+
+	var (
+		err error
+		cfg config.Config
+	)
+
+	BeforeEach(func() {
+		cfg, err = mycs_mocks.NewMockConfig(sourceDirPath)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	startMockNodeService := func() (*test_server.MockHttpServer, *mycscloud.SpaceAPI) {
+		// start test server
+		testServer, testServerUrl := startTestServer()
+		// Space API client
+		return testServer,
+			mycscloud.NewSpaceAPI(api.NewGraphQLClient(testServerUrl, "", cfg.AuthContext()))
+	}
+
+	It("invites a user to a space and manages the user's access", func() {
+		testServer, spaceAPI := startMockNodeService()
+		defer testServer.Stop()
+
+		testServer.PushRequest().
+			ExpectJSONRequest(inviteSpaceUserRequest).
+			RespondWith(errorResponse)
+
+		_, err = spaceAPI.InviteSpaceUser("a space id", "bob's user id", true, false, 0)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Message: a test error occurred, Locations: []"))
+
+		testServer.PushRequest().
+			ExpectJSONRequest(inviteSpaceUserRequest).
+			RespondWith(inviteSpaceUserResponse)
+
+		member, err := spaceAPI.InviteSpaceUser("a space id", "bob's user id", true, false, 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(member.Name).To(Equal("bob"))
+		Expect(member.IsAdmin).To(BeTrue())
+		Expect(member.CanUseForEgress).To(BeFalse())
+		Expect(member.IsInvitePending()).To(BeTrue())
+		Expect(member.InviteExpiresAt.IsZero()).To(BeTrue())
+
+		_, err = spaceAPI.InviteSpaceUser("a space id", "bob's user id", true, false, -1)
+		Expect(err).To(HaveOccurred())
+
+		// expired invitations and members
+		// are not pending invitations
+		testServer.PushRequest().
+			ExpectJSONRequest(getSpaceMembersRequest).
+			RespondWith(getSpaceMembersResponse)
+
+		invited, err := spaceAPI.GetPendingSpaceInvitations("a space id")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(invited)).To(Equal(1))
+		Expect(invited[0].UserID).To(Equal("bob's user id"))
+
+		testServer.PushRequest().
+			ExpectJSONRequest(updateSpaceUserRequest).
+			RespondWith(updateSpaceUserResponse)
+
+		member, err = spaceAPI.UpdateSpaceUser("a space id", "bob's user id", false, true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(member.IsAdmin).To(BeFalse())
+		Expect(member.CanUseForEgress).To(BeTrue())
+
+		testServer.PushRequest().
+			ExpectJSONRequest(deleteSpaceUserRequest).
+			RespondWith(deleteSpaceUserResponse)
+
+		err = spaceAPI.RevokeSpaceUser("a space id", "bob's user id")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(testServer.Done()).To(BeTrue())
+	})
+
+	It("accepts and declines the logged in user's invitations", func() {
+		testServer, spaceAPI := startMockNodeService()
+		defer testServer.Stop()
+
+		testServer.PushRequest().
+			ExpectJSONRequest(getSpaceInvitationsRequest).
+			RespondWith(getSpaceInvitationsResponse)
+
+		invitations, err := spaceAPI.GetSpaceInvitations()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(invitations)).To(Equal(2))
+		Expect(invitations[0].SpaceName).To(Equal("home space"))
+		Expect(invitations[0].CanUseForEgress).To(BeTrue())
+		Expect(invitations[0].ExpiresAt.IsZero()).To(BeTrue())
+		Expect(invitations[1].SpaceName).To(Equal("work space"))
+		Expect(invitations[1].IsAdmin).To(BeTrue())
+		Expect(invitations[1].ExpiresAt.UnixMilli()).To(Equal(int64(4102444800000)))
+
+		testServer.PushRequest().
+			ExpectJSONRequest(acceptSpaceInvitationRequest).
+			RespondWith(acceptSpaceInvitationResponse)
+
+		err = spaceAPI.AcceptSpaceInvitation("home space id")
+		Expect(err).ToNot(HaveOccurred())
+
+		testServer.PushRequest().
+			ExpectJSONRequest(declineSpaceInvitationRequest).
+			RespondWith(errorResponse)
+
+		err = spaceAPI.DeclineSpaceInvitation("work space id")
+		Expect(err).To(HaveOccurred())
+		Expect(testServer.Done()).To(BeTrue())
+	})
+})
+
+const inviteSpaceUserRequest = `{
+	"query": "mutation ($canUseSpaceForEgress:Boolean!$expiresAt:Float$isAdmin:Boolean!$spaceID:ID!$userID:ID!){inviteSpaceUser(spaceID: $spaceID, userID: $userID, isAdmin: $isAdmin, canUseSpaceForEgress: $canUseSpaceForEgress, expiresAt: $expiresAt){user{userID,userName,firstName,middleName,familyName},isOwner,isAdmin,canUseSpaceForEgress,status,inviteExpiresAt}}",
+	"variables": {
+		"spaceID": "a space id",
+		"userID": "bob's user id",
+		"isAdmin": true,
+		"canUseSpaceForEgress": false,
+		"expiresAt": null
+	}
+}`
+const inviteSpaceUserResponse = `{
+	"data": {
+		"inviteSpaceUser": {
+			"user": {
+				"userID": "bob's user id",
+				"userName": "bob",
+				"firstName": "Bob",
+				"middleName": "",
+				"familyName": "Builder"
+			},
+			"isOwner": false,
+			"isAdmin": true,
+			"canUseSpaceForEgress": false,
+			"status": "pending",
+			"inviteExpiresAt": null
+		}
+	}
+}`
+
+const getSpaceMembersRequest = `{
+	"query": "query ($spaceID:ID!){getSpace(spaceID: $spaceID){users{spaceUsers{user{userID,userName,firstName,middleName,familyName},isOwner,isAdmin,canUseSpaceForEgress,status,inviteExpiresAt}}}}",
+	"variables": {
+		"spaceID": "a space id"
+	}
+}`
+const getSpaceMembersResponse = `{
+	"data": {
+		"getSpace": {
+			"users": {
+				"spaceUsers": [
+					{
+						"user": {
+							"userID": "owner's user id",
+							"userName": "owner"
+						},
+						"isOwner": true,
+						"isAdmin": true,
+						"canUseSpaceForEgress": true,
+						"status": "active"
+					},
+					{
+						"user": {
+							"userID": "bob's user id",
+							"userName": "bob"
+						},
+						"isOwner": false,
+						"isAdmin": true,
+						"canUseSpaceForEgress": false,
+						"status": "pending",
+						"inviteExpiresAt": null
+					},
+					{
+						"user": {
+							"userID": "alice's user id",
+							"userName": "alice"
+						},
+						"isOwner": false,
+						"isAdmin": false,
+						"canUseSpaceForEgress": false,
+						"status": "pending",
+						"inviteExpiresAt": 1577836800000
+					}
+				]
+			}
+		}
+	}
+}`
+
+const updateSpaceUserRequest = `{
+	"query": "mutation ($canUseSpaceForEgress:Boolean!$isAdmin:Boolean!$spaceID:ID!$userID:ID!){updateSpaceUser(spaceID: $spaceID, userID: $userID, isAdmin: $isAdmin, canUseSpaceForEgress: $canUseSpaceForEgress){user{userID,userName,firstName,middleName,familyName},isOwner,isAdmin,canUseSpaceForEgress,status,inviteExpiresAt}}",
+	"variables": {
+		"spaceID": "a space id",
+		"userID": "bob's user id",
+		"isAdmin": false,
+		"canUseSpaceForEgress": true
+	}
+}`
+const updateSpaceUserResponse = `{
+	"data": {
+		"updateSpaceUser": {
+			"user": {
+				"userID": "bob's user id",
+				"userName": "bob"
+			},
+			"isOwner": false,
+			"isAdmin": false,
+			"canUseSpaceForEgress": true,
+			"status": "pending"
+		}
+	}
+}`
+
+const deleteSpaceUserRequest = `{
+	"query": "mutation ($spaceID:ID!$userID:ID!){deleteSpaceUser(spaceID: $spaceID, userID: $userID){space{spaceID},user{userID}}}",
+	"variables": {
+		"spaceID": "a space id",
+		"userID": "bob's user id"
+	}
+}`
+const deleteSpaceUserResponse = `{
+	"data": {
+		"deleteSpaceUser": {
+			"space": {
+				"spaceID": "a space id"
+			},
+			"user": {
+				"userID": "bob's user id"
+			}
+		}
+	}
+}`
+
+const getSpaceInvitationsRequest = `{
+	"query": "{getUser{spaces{spaceUsers{space{spaceID,spaceName},isAdmin,canUseSpaceForEgress,status,inviteExpiresAt}}}}"
+}`
+const getSpaceInvitationsResponse = `{
+	"data": {
+		"getUser": {
+			"spaces": {
+				"spaceUsers": [
+					{
+						"space": {
+							"spaceID": "home space id",
+							"spaceName": "home space"
+						},
+						"isAdmin": false,
+						"canUseSpaceForEgress": true,
+						"status": "pending",
+						"inviteExpiresAt": null
+					},
+					{
+						"space": {
+							"spaceID": "own space id",
+							"spaceName": "own space"
+						},
+						"isAdmin": true,
+						"canUseSpaceForEgress": true,
+						"status": "active",
+						"inviteExpiresAt": null
+					},
+					{
+						"space": {
+							"spaceID": "work space id",
+							"spaceName": "work space"
+						},
+						"isAdmin": true,
+						"canUseSpaceForEgress": false,
+						"status": "pending",
+						"inviteExpiresAt": 4102444800000
+					},
+					{
+						"space": {
+							"spaceID": "old space id",
+							"spaceName": "old space"
+						},
+						"isAdmin": false,
+						"canUseSpaceForEgress": false,
+						"status": "pending",
+						"inviteExpiresAt": 1577836800000
+					}
+				]
+			}
+		}
+	}
+}`
+
+const acceptSpaceInvitationRequest = `{
+	"query": "mutation ($spaceID:ID!){acceptSpaceUserInvitation(spaceID: $spaceID){status}}",
+	"variables": {
+		"spaceID": "home space id"
+	}
+}`
+const acceptSpaceInvitationResponse = `{
+	"data": {
+		"acceptSpaceUserInvitation": {
+			"status": "active"
+		}
+	}
+}`
+
+const declineSpaceInvitationRequest = `{
+	"query": "mutation ($spaceID:ID!){declineSpaceUserInvitation(spaceID: $spaceID){status}}",
+	"variables": {
+		"spaceID": "work space id"
+	}
+}`
@@ -0,0 +1,186 @@
+package mycscloud
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/hasura/go-graphql-client"
+
+	"github.com/appbricks/cloud-builder/userspace"
+	"github.com/appbricks/mycloudspace-client/api"
+	"github.com/mevansam/goutils/logger"
+)
+
+type SubscriptionAPI struct {
+	subClient *api.SubscriptionClient
+}
+
+// space status change event
+type SpaceStatus struct {
+	SpaceID  string
+	Status   string
+	LastSeen uint64
+}
+
+// device access request event
+type DeviceAccessRequest struct {
+	DeviceID string
+	User     *userspace.User
+	Status   string
+}
+
+// user key and config change event
+type UserConfigUpdate struct {
+	UserID          string
+	KeyTimestamp    int64
+	ConfigTimestamp int64
+}
+
+func NewSubscriptionAPI(subClient *api.SubscriptionClient) *SubscriptionAPI {
+
+	return &SubscriptionAPI{
+		subClient: subClient,
+	}
+}
+
+func (s *SubscriptionAPI) SubscribeToSpaceStatus(
+	spaceID string,
+	handleStatus func(status *SpaceStatus, err error),
+) (string, error) {
+
+	type subscription struct {
+		SpaceStatusUpdated struct {
+			SpaceID  graphql.String `graphql:"spaceID"`
+			Status   graphql.String
+			LastSeen graphql.Float
+		} `graphql:"spaceStatusUpdated(spaceID: $spaceID)"`
+	}
+	variables := map[string]interface{}{
+		"spaceID": graphql.ID(spaceID),
+	}
+	return s.subClient.Subscribe(&subscription{}, variables, func(data *json.RawMessage, err error) {
+		event := subscription{}
+		if err = unmarshalEvent(data, err, &event); err != nil {
+			logger.ErrorMessage("SubscriptionAPI.SubscribeToSpaceStatus(): spaceStatusUpdated subscription returned an error: %s", err.Error())
+			handleStatus(nil, err)
+			return
+		}
+		logger.TraceMessage("SubscriptionAPI.SubscribeToSpaceStatus(): spaceStatusUpdated subscription returned event: %# v", event)
+
+		handleStatus(&SpaceStatus{
+			SpaceID:  string(event.SpaceStatusUpdated.SpaceID),
+			Status:   string(event.SpaceStatusUpdated.Status),
+			LastSeen: uint64(float64(event.SpaceStatusUpdated.LastSeen)),
+		}, nil)
+	})
+}
+
+func (s *SubscriptionAPI) SubscribeToDeviceAccessRequests(
+	deviceID string,
+	handleRequest func(request *DeviceAccessRequest, err error),
+) (string, error) {
+
+	type subscription struct {
+		DeviceAccessRequested struct {
+			Device struct {
+				DeviceID graphql.String `graphql:"deviceID"`
+			}
+			User struct {
+				UserID     graphql.String `graphql:"userID"`
+				UserName   graphql.String
+				FirstName  graphql.String
+				MiddleName graphql.String
+				FamilyName graphql.String
+			}
+			Status graphql.String
+		} `graphql:"deviceAccessRequested(deviceID: $deviceID)"`
+	}
+	variables := map[string]interface{}{
+		"deviceID": graphql.ID(deviceID),
+	}
+	return s.subClient.Subscribe(&subscription{}, variables, func(data *json.RawMessage, err error) {
+		event := subscription{}
+		if err = unmarshalEvent(data, err, &event); err != nil {
+			logger.ErrorMessage("SubscriptionAPI.SubscribeToDeviceAccessRequests(): deviceAccessRequested subscription returned an error: %s", err.Error())
+			handleRequest(nil, err)
+			return
+		}
+		logger.TraceMessage("SubscriptionAPI.SubscribeToDeviceAccessRequests(): deviceAccessRequested subscription returned event: %# v", event)
+
+		user := event.DeviceAccessRequested.User
+		handleRequest(&DeviceAccessRequest{
+			DeviceID: string(event.DeviceAccessRequested.Device.DeviceID),
+			User: &userspace.User{
+				UserID:     string(user.UserID),
+				Name:       string(user.UserName),
+				FirstName:  string(user.FirstName),
+				MiddleName: string(user.MiddleName),
+				FamilyName: string(user.FamilyName),
+			},
+			Status: string(event.DeviceAccessRequested.Status),
+		}, nil)
+	})
+}
+
+func (s *SubscriptionAPI) SubscribeToUserConfigUpdates(
+	userID string,
+	handleUpdate func(update *UserConfigUpdate, err error),
+) (string, error) {
+
+	type subscription struct {
+		UserConfigUpdated struct {
+			UserID          graphql.String `graphql:"userID"`
+			KeyTimestamp    graphql.String
+			ConfigTimestamp graphql.String
+		} `graphql:"userConfigUpdated(userID: $userID)"`
+	}
+	variables := map[string]interface{}{
+		"userID": graphql.ID(userID),
+	}
+	return s.subClient.Subscribe(&subscription{}, variables, func(data *json.RawMessage, err error) {
+
+		var (
+			keyTimestamp, configTimestamp int64
+		)
+
+		event := subscription{}
+		if err = unmarshalEvent(data, err, &event); err == nil {
+			if keyTimestamp, err = parseTimestamp(string(event.UserConfigUpdated.KeyTimestamp)); err == nil {
+				configTimestamp, err = parseTimestamp(string(event.UserConfigUpdated.ConfigTimestamp))
+			}
+		}
+		if err != nil {
+			logger.ErrorMessage("SubscriptionAPI.SubscribeToUserConfigUpdates(): userConfigUpdated subscription returned an error: %s", err.Error())
+			handleUpdate(nil, err)
+			return
+		}
+		logger.TraceMessage("SubscriptionAPI.SubscribeToUserConfigUpdates(): userConfigUpdated subscription returned event: %# v", event)
+
+		handleUpdate(&UserConfigUpdate{
+			UserID:          string(event.UserConfigUpdated.UserID),
+			KeyTimestamp:    keyTimestamp,
+			ConfigTimestamp: configTimestamp,
+		}, nil)
+	})
+}
+
+func (s *SubscriptionAPI) Unsubscribe(subscriptionID string) error {
+	return s.subClient.Unsubscribe(subscriptionID)
+}
+
+func unmarshalEvent(data *json.RawMessage, err error, event interface{}) error {
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		return nil
+	}
+	return json.Unmarshal(*data, event)
+}
+
+func parseTimestamp(ts string) (int64, error) {
+	if len(ts) == 0 {
+		return 0, nil
+	}
+	return strconv.ParseInt(ts, 10, 64)
+}
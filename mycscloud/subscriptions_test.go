@@ -0,0 +1,149 @@
+package mycscloud_test
+
+import (
+	"time"
+
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/appbricks/mycloudspace-client/api"
+	"github.com/appbricks/mycloudspace-client/mycscloud"
+
+	mycs_mocks "github.com/appbricks/mycloudspace-client/test/mocks"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Subscription API", func() {
+
+	var (
+		err error
+		cfg config.Config
+
+		testServer *mycs_mocks.MockSubscriptionServer
+		subClient  *api.SubscriptionClient
+		subAPI     *mycscloud.SubscriptionAPI
+	)
+
+	BeforeEach(func() {
+		cfg, err = mycs_mocks.NewMockConfig(sourceDirPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		testServer = mycs_mocks.NewMockSubscriptionServer()
+		testServer.Start()
+
+		subClient = api.NewSubscriptionClient(testServer.URL(), cfg.AuthContext())
+		subClient.Start()
+		subAPI = mycscloud.NewSubscriptionAPI(subClient)
+	})
+
+	AfterEach(func() {
+		subClient.Stop()
+		testServer.Stop()
+	})
+
+	It("subscribes to space status changes", func() {
+
+		statusUpdates := make(chan *mycscloud.SpaceStatus, 1)
+		_, err = subAPI.SubscribeToSpaceStatus("1d812616-5955-4bc6-8b67-ec3f0f12a756", func(status *mycscloud.SpaceStatus, err error) {
+			Expect(err).ToNot(HaveOccurred())
+			statusUpdates <- status
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		sub, err := testServer.WaitForSubscription(5 * time.Second)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sub.Query).To(Equal(spaceStatusSubscription))
+
+		err = testServer.Publish(sub, spaceStatusEvent)
+		Expect(err).ToNot(HaveOccurred())
+
+		var status *mycscloud.SpaceStatus
+		Eventually(statusUpdates, 5*time.Second).Should(Receive(&status))
+		Expect(status.SpaceID).To(Equal("1d812616-5955-4bc6-8b67-ec3f0f12a756"))
+		Expect(status.Status).To(Equal("running"))
+		Expect(status.LastSeen).To(Equal(uint64(1630519684375)))
+	})
+
+	It("subscribes to device access requests", func() {
+
+		requests := make(chan *mycscloud.DeviceAccessRequest, 1)
+		_, err = subAPI.SubscribeToDeviceAccessRequests("1234", func(request *mycscloud.DeviceAccessRequest, err error) {
+			Expect(err).ToNot(HaveOccurred())
+			requests <- request
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		sub, err := testServer.WaitForSubscription(5 * time.Second)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sub.Query).To(Equal(deviceAccessSubscription))
+
+		err = testServer.Publish(sub, deviceAccessEvent)
+		Expect(err).ToNot(HaveOccurred())
+
+		var request *mycscloud.DeviceAccessRequest
+		Eventually(requests, 5*time.Second).Should(Receive(&request))
+		Expect(request.DeviceID).To(Equal("1234"))
+		Expect(request.User.UserID).To(Equal("2222"))
+		Expect(request.User.Name).To(Equal("guest2"))
+		Expect(request.User.FirstName).To(Equal("Guest"))
+		Expect(request.Status).To(Equal("pending"))
+	})
+
+	It("subscribes to user config changes", func() {
+
+		updates := make(chan *mycscloud.UserConfigUpdate, 1)
+		_, err = subAPI.SubscribeToUserConfigUpdates("0000", func(update *mycscloud.UserConfigUpdate, err error) {
+			Expect(err).ToNot(HaveOccurred())
+			updates <- update
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		sub, err := testServer.WaitForSubscription(5 * time.Second)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sub.Query).To(Equal(userConfigSubscription))
+
+		err = testServer.Publish(sub, userConfigEvent)
+		Expect(err).ToNot(HaveOccurred())
+
+		var update *mycscloud.UserConfigUpdate
+		Eventually(updates, 5*time.Second).Should(Receive(&update))
+		Expect(update.UserID).To(Equal("0000"))
+		Expect(update.KeyTimestamp).To(Equal(int64(1630519684375)))
+		Expect(update.ConfigTimestamp).To(Equal(int64(1630519699999)))
+	})
+})
+
+const spaceStatusSubscription = `subscription ($spaceID:ID!){spaceStatusUpdated(spaceID: $spaceID){spaceID,status,lastSeen}}`
+const spaceStatusEvent = `{
+	"spaceStatusUpdated": {
+		"spaceID": "1d812616-5955-4bc6-8b67-ec3f0f12a756",
+		"status": "running",
+		"lastSeen": 1630519684375
+	}
+}`
+
+const deviceAccessSubscription = `subscription ($deviceID:ID!){deviceAccessRequested(deviceID: $deviceID){device{deviceID},user{userID,userName,firstName,middleName,familyName},status}}`
+const deviceAccessEvent = `{
+	"deviceAccessRequested": {
+		"device": {
+			"deviceID": "1234"
+		},
+		"user": {
+			"userID": "2222",
+			"userName": "guest2",
+			"firstName": "Guest",
+			"middleName": "",
+			"familyName": "Two"
+		},
+		"status": "pending"
+	}
+}`
+
+const userConfigSubscription = `subscription ($userID:ID!){userConfigUpdated(userID: $userID){userID,keyTimestamp,configTimestamp}}`
+const userConfigEvent = `{
+	"userConfigUpdated": {
+		"userID": "0000",
+		"keyTimestamp": "1630519684375",
+		"configTimestamp": "1630519699999"
+	}
+}`
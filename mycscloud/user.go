@@ -4,14 +4,23 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/appbricks/cloud-builder/userspace"
+	"github.com/appbricks/mycloudspace-client/api"
 	"github.com/hasura/go-graphql-client"
+	"github.com/mevansam/goutils/crypto"
 	"github.com/mevansam/goutils/logger"
 )
 
+// time allowed to revert the remote updates of a
+// failed key rotation. the rollback does not use the
+// caller's context as it may have been cancelled.
+const keyRotationRollbackTimeout = 30 * time.Second
+
 type UserAPI struct {
 	apiClient *graphql.Client
+	cache     *Cache
 }
 
 func NewUserAPI(apiClient *graphql.Client) *UserAPI {
@@ -21,7 +30,20 @@ func NewUserAPI(apiClient *graphql.Client) *UserAPI {
 	}
 }
 
+// returns the API with its query responses
+// saved to the given offline cache
+func (u *UserAPI) WithCache(cache *Cache) *UserAPI {
+	u.cache = cache
+	return u
+}
+
+// returns up to 5 users matching the given user name.
+// use SearchUsers to page through all matching users.
 func (u *UserAPI) UserSearch(name string) ([]*userspace.User, error) {
+	return u.UserSearchWithContext(context.Background(), name)
+}
+
+func (u *UserAPI) UserSearchWithContext(ctx context.Context, name string) ([]*userspace.User, error) {
 
 	var (
 		users []*userspace.User
@@ -39,9 +61,9 @@ func (u *UserAPI) UserSearch(name string) ([]*userspace.User, error) {
 	variables := map[string]interface{}{
 		"userName": graphql.String(name),
 	}
-	if err := u.apiClient.Query(context.Background(), &query, variables); err != nil {
+	if err := u.apiClient.Query(ctx, &query, variables); err != nil {
 		logger.ErrorMessage("UserAPI.UserSearch(): userSearch query returned an error: %s", err.Error())
-		return nil, err
+		return nil, apiError(err)
 	}
 	logger.TraceMessage("UserAPI.UserSearch(): userSearch query returned response: %# v", query)
 
@@ -62,6 +84,10 @@ func (u *UserAPI) UserSearch(name string) ([]*userspace.User, error) {
 }
 
 func (u *UserAPI) GetUser(user *userspace.User) (*userspace.User, error) {
+	return u.GetUserWithContext(context.Background(), user)
+}
+
+func (u *UserAPI) GetUserWithContext(ctx context.Context, user *userspace.User) (*userspace.User, error) {
 
 	var query struct {
 		GetUser struct {
@@ -70,9 +96,9 @@ func (u *UserAPI) GetUser(user *userspace.User) (*userspace.User, error) {
 			Certificate     graphql.String
 		} `graphql:"getUser"`
 	}
-	if err := u.apiClient.Query(context.Background(), &query, map[string]interface{}{}); err != nil {
+	if err := u.cache.query(ctx, u.apiClient, UserCacheEntry, &query, map[string]interface{}{}); err != nil {
 		logger.DebugMessage("UserAPI: getUser query to retrieve user returned an error: %s", err.Error())
-		return nil, err
+		return nil, apiError(err)
 	}
 	logger.TraceMessage("UserAPI: getUser query to retrieve user returned response: %# v", query)
 
@@ -86,6 +112,10 @@ func (u *UserAPI) GetUser(user *userspace.User) (*userspace.User, error) {
 }
 
 func (u *UserAPI) GetUserConfig(user *userspace.User) ([]byte, error) {
+	return u.GetUserConfigWithContext(context.Background(), user)
+}
+
+func (u *UserAPI) GetUserConfigWithContext(ctx context.Context, user *userspace.User) ([]byte, error) {
 
 	var (
 		err error
@@ -99,9 +129,9 @@ func (u *UserAPI) GetUserConfig(user *userspace.User) ([]byte, error) {
 			UniversalConfig graphql.String
 		} `graphql:"getUser"`
 	}
-	if err = u.apiClient.Query(context.Background(), &query, map[string]interface{}{}); err != nil {
+	if err = u.apiClient.Query(ctx, &query, map[string]interface{}{}); err != nil {
 		logger.DebugMessage("UserAPI: getUser query to retrieve user returned an error: %s", err.Error())
-		return nil, err
+		return nil, apiError(err)
 	}
 	logger.TraceMessage("UserAPI: getUser query to retrieve user returned response: %# v", query)
 
@@ -117,6 +147,10 @@ func (u *UserAPI) GetUserConfig(user *userspace.User) ([]byte, error) {
 }
 
 func (u *UserAPI) UpdateUserKey(user *userspace.User) error {
+	return u.UpdateUserKeyWithContext(context.Background(), user)
+}
+
+func (u *UserAPI) UpdateUserKeyWithContext(ctx context.Context, user *userspace.User) error {
 
 	var mutation struct {
 		UpdateUserKey struct {
@@ -127,9 +161,10 @@ func (u *UserAPI) UpdateUserKey(user *userspace.User) error {
 		"publicKey": graphql.String(user.RSAPublicKey),
 		"keyTimestamp": graphql.String(strconv.FormatInt(user.KeyTimestamp, 10)),
 	}
-	if err := u.apiClient.Mutate(context.Background(), &mutation, variables); err != nil {
+	// setting the key is idempotent so it is safe to retry
+	if err := u.apiClient.Mutate(api.WithMutationRetry(ctx), &mutation, variables); err != nil {
 		logger.DebugMessage("UserAPI: updateUserKey mutation returned an error: %s", err.Error())
-		return err
+		return apiError(err)
 	}
 	logger.TraceMessage("UserAPI: updateUserKey mutation returned response: %# v", mutation)
 
@@ -140,6 +175,10 @@ func (u *UserAPI) UpdateUserKey(user *userspace.User) error {
 }
 
 func (u *UserAPI) UpdateUserConfig(user *userspace.User, config []byte, asOfTimestamp int64) (int64, error) {
+	return u.UpdateUserConfigWithContext(context.Background(), user, config, asOfTimestamp)
+}
+
+func (u *UserAPI) UpdateUserConfigWithContext(ctx context.Context, user *userspace.User, config []byte, asOfTimestamp int64) (int64, error) {
 
 	var (
 		err error
@@ -159,9 +198,9 @@ func (u *UserAPI) UpdateUserConfig(user *userspace.User, config []byte, asOfTime
 		"config": graphql.String(configData),
 		"asOf": graphql.String(strconv.FormatInt(asOfTimestamp, 10)),
 	}
-	if err = u.apiClient.Mutate(context.Background(), &mutation, variables); err != nil {
+	if err = u.apiClient.Mutate(ctx, &mutation, variables); err != nil {
 		logger.DebugMessage("UserAPI: updateUserConfig mutation returned an error: %s", err.Error())
-		return 0, err
+		return 0, apiError(err)
 	}
 	logger.TraceMessage("UserAPI: updateUserConfig mutation returned response: %# v", mutation)
 	
@@ -170,3 +209,113 @@ func (u *UserAPI) UpdateUserConfig(user *userspace.User, config []byte, asOfTime
 	}
 	return configTimestamp, nil
 }
+
+func (u *UserAPI) RotateUserKey(
+	user *userspace.User, 
+	newKey *crypto.RSAKey, 
+	asOfTimestamp int64, 
+	commit func(configTimestamp int64) error,
+) error {
+	return u.RotateUserKeyWithContext(context.Background(), user, newKey, asOfTimestamp, commit)
+}
+
+// replaces the given user's key with the new key. the
+// user's universal config is re-encrypted with the new
+// key and saved along with the new public key. once
+// the remote updates have completed the given commit
+// function is called with the timestamp of the saved
+// config so the caller can persist the new key. if any
+// step including the commit fails then all updates
+// made are rolled back so that the user's previous
+// key remains valid.
+func (u *UserAPI) RotateUserKeyWithContext(
+	ctx context.Context, 
+	user *userspace.User, 
+	newKey *crypto.RSAKey, 
+	asOfTimestamp int64, 
+	commit func(configTimestamp int64) error,
+) error {
+
+	var (
+		err error
+
+		prevKey          *crypto.RSAKey
+		prevKeyTimestamp int64
+
+		configData      []byte
+		configTimestamp int64
+
+		keyUpdated, 
+		configUpdated bool
+	)
+
+	if len(user.RSAPrivateKey) == 0 {
+		return fmt.Errorf("user '%s' does not have a key to rotate", user.Name)
+	}
+	if prevKey, err = crypto.NewRSAKeyFromPEM(user.RSAPrivateKey, nil); err != nil {
+		return fmt.Errorf("unable to read the current key of user '%s': %s", user.Name, err.Error())
+	}
+	prevKeyTimestamp = user.KeyTimestamp
+
+	// the config needs to be retrieved 
+	// while it can still be decrypted
+	// with the current key
+	if configData, err = u.GetUserConfigWithContext(ctx, user); err != nil {
+		return err
+	}
+
+	// restores the previous key locally and 
+	// reverts the remote updates made
+	rollback := func(cause error) error {
+
+		var (
+			err error
+		)
+
+		logger.DebugMessage("UserAPI.RotateUserKey(): Rolling back key rotation of user '%s': %s", user.Name, cause.Error())
+
+		if err = user.SetKey(prevKey, true); err != nil {
+			err = fmt.Errorf("unable to restore previous key: %s", err.Error())
+		} else {
+			user.KeyTimestamp = prevKeyTimestamp
+
+			rollbackCtx, cancel := context.WithTimeout(context.Background(), keyRotationRollbackTimeout)
+			defer cancel()
+
+			if keyUpdated {
+				err = u.UpdateUserKeyWithContext(rollbackCtx, user)
+			}
+			if err == nil && configUpdated {
+				_, err = u.UpdateUserConfigWithContext(rollbackCtx, user, configData, configTimestamp)
+			}
+		}
+		if err != nil {
+			logger.ErrorMessage("UserAPI.RotateUserKey(): Failed to roll back key rotation of user '%s': %s", user.Name, err.Error())
+			return fmt.Errorf("%w: key rotation could not be rolled back: %s", cause, err.Error())
+		}
+		return cause
+	}
+
+	if err = user.SetKey(newKey, true); err != nil {
+		return rollback(err)
+	}
+	// the key may have been updated even if an error
+	// is returned such as when the response times out
+	// so the previous key is always restored remotely
+	keyUpdated = true
+	if err = u.UpdateUserKeyWithContext(ctx, user); err != nil {
+		return rollback(err)
+	}
+
+	configTimestamp = asOfTimestamp
+	if len(configData) > 0 {
+		if configTimestamp, err = u.UpdateUserConfigWithContext(ctx, user, configData, asOfTimestamp); err != nil {
+			return rollback(err)
+		}
+		configUpdated = true
+	}
+	if err = commit(configTimestamp); err != nil {
+		return rollback(err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package mycscloud
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/appbricks/cloud-builder/userspace"
+	"github.com/hasura/go-graphql-client"
+	"github.com/mevansam/goutils/logger"
+)
+
+// a device the logged in user owns
+// or has been granted access to
+type UserDevice struct {
+	*userspace.Device
+
+	ClientVersion string
+
+	// whether the logged in user owns the device
+	IsOwned bool
+	// status of the logged in user's access to
+	// the device. one of SpaceAccessActive or
+	// SpaceAccessPending.
+	AccessStatus string
+
+	// time the device last connected to the
+	// MyCS cloud. this is zero if the device
+	// has never connected.
+	LastSeen time.Time
+}
+
+func (d *DeviceAPI) ListDevices() ([]*UserDevice, error) {
+	return d.ListDevicesWithContext(context.Background())
+}
+
+func (d *DeviceAPI) ListDevicesWithContext(ctx context.Context) ([]*UserDevice, error) {
+
+	var query struct {
+		GetUser struct {
+			Devices struct {
+				DeviceUsers []struct {
+					Device struct {
+						DeviceID      graphql.String `graphql:"deviceID"`
+						DeviceName    graphql.String
+						DeviceType    graphql.String
+						ClientVersion graphql.String
+						LastSeen      graphql.Float
+					}
+					IsOwner graphql.Boolean
+					Status  graphql.String
+				}
+			}
+		} `graphql:"getUser"`
+	}
+	if err := d.apiClient.Query(ctx, &query, map[string]interface{}{}); err != nil {
+		logger.ErrorMessage("DeviceAPI.ListDevices(): getUser query to retrieve user's device list returned an error: %s", err.Error())
+		return nil, apiError(err)
+	}
+	logger.TraceMessage("DeviceAPI.ListDevices(): getUser query to retrieve user's device list returned response: %# v", query)
+
+	devices := []*UserDevice{}
+	for _, deviceUser := range query.GetUser.Devices.DeviceUsers {
+		if deviceUser.Status != SpaceAccessInactive {
+			devices = append(devices, &UserDevice{
+				Device: &userspace.Device{
+					DeviceID: string(deviceUser.Device.DeviceID),
+					Name:     string(deviceUser.Device.DeviceName),
+					Type:     string(deviceUser.Device.DeviceType),
+				},
+				ClientVersion: string(deviceUser.Device.ClientVersion),
+				IsOwned:       bool(deviceUser.IsOwner),
+				AccessStatus:  string(deviceUser.Status),
+				LastSeen:      timeFromMillis(deviceUser.Device.LastSeen),
+			})
+		}
+	}
+	return devices, nil
+}
+
+// returns the device with the given id from
+// the devices the logged in user has access to
+func (d *DeviceAPI) GetDevice(deviceID string) (*UserDevice, error) {
+	return d.GetDeviceWithContext(context.Background(), deviceID)
+}
+
+func (d *DeviceAPI) GetDeviceWithContext(ctx context.Context, deviceID string) (*UserDevice, error) {
+
+	var (
+		err error
+
+		devices []*UserDevice
+	)
+
+	if devices, err = d.ListDevicesWithContext(ctx); err != nil {
+		return nil, err
+	}
+	for _, device := range devices {
+		if device.DeviceID == deviceID {
+			return device, nil
+		}
+	}
+	return nil, newError(ErrNotFound, fmt.Sprintf("device with id '%s' was not found", deviceID))
+}
+
+// renames a device owned by the logged in user
+func (d *DeviceAPI) RenameDevice(deviceID, deviceName string) error {
+	return d.RenameDeviceWithContext(context.Background(), deviceID, deviceName)
+}
+
+func (d *DeviceAPI) RenameDeviceWithContext(ctx context.Context, deviceID, deviceName string) error {
+
+	deviceName = strings.TrimSpace(deviceName)
+	if len(deviceName) == 0 {
+		return newError(ErrBadRequest, "a device name cannot be empty")
+	}
+
+	var mutation struct {
+		UpdateDevice struct {
+			DeviceID   graphql.String `graphql:"deviceID"`
+			DeviceName graphql.String
+		} `graphql:"updateDevice(deviceID: $deviceID, deviceName: $deviceName)"`
+	}
+	variables := map[string]interface{}{
+		"deviceID":   graphql.ID(deviceID),
+		"deviceName": graphql.String(deviceName),
+	}
+	if err := d.apiClient.Mutate(ctx, &mutation, variables); err != nil {
+		logger.ErrorMessage("DeviceAPI.RenameDevice(): updateDevice mutation returned an error: %s", err.Error())
+		return apiError(err)
+	}
+	logger.TraceMessage("DeviceAPI.RenameDevice(): updateDevice mutation returned response: %# v", mutation)
+	return nil
+}
@@ -0,0 +1,215 @@
+package mycscloud
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/appbricks/cloud-builder/userspace"
+	"github.com/hasura/go-graphql-client"
+	"github.com/mevansam/goutils/logger"
+)
+
+// number of users returned per page
+// if the filter does not specify one
+const (
+	defaultUserSearchPageSize = 20
+	maxUserSearchPageSize     = 100
+)
+
+// filters the users returned by a user search. fields
+// which are empty do not filter the results.
+type UserSearchFilter struct {
+	UserName,
+	FirstName,
+	FamilyName,
+	EmailAddress string
+
+	// match users whose fields contain the
+	// filter values instead of users whose
+	// fields are equal to them
+	PartialMatch bool
+
+	// the device or space that users in the
+	// results are flagged as having access to.
+	// only one of these may be given.
+	DeviceID,
+	SpaceID string
+
+	// number of users returned per page
+	PageSize int
+}
+
+// a user returned by a user search
+type UserSearchResult struct {
+	*userspace.User
+
+	EmailAddress string
+
+	// whether the user has access to the
+	// device or space given in the filter
+	HasAccess bool
+}
+
+// pages through the results of a user search.
+// each call to Next returns the next page of
+// users until HasNext returns false.
+type UserSearch struct {
+	userAPI *UserAPI
+	filter  UserSearchFilter
+
+	cursor string
+	done   bool
+
+	// users retrieved but not yet returned as
+	// the maximum requested of All was reached
+	buffered []*UserSearchResult
+}
+
+// returns a search for the users matching the
+// given filter. the search is not run until its
+// first page is requested.
+func (u *UserAPI) SearchUsers(filter UserSearchFilter) *UserSearch {
+
+	if filter.PageSize <= 0 {
+		filter.PageSize = defaultUserSearchPageSize
+	} else if filter.PageSize > maxUserSearchPageSize {
+		filter.PageSize = maxUserSearchPageSize
+	}
+	return &UserSearch{
+		userAPI: u,
+		filter:  filter,
+	}
+}
+
+// returns whether there are more
+// pages of users to be returned
+func (s *UserSearch) HasNext() bool {
+	return !s.done || len(s.buffered) > 0
+}
+
+// restarts the search from the first page
+func (s *UserSearch) Reset() {
+	s.cursor = ""
+	s.done = false
+	s.buffered = nil
+}
+
+func (s *UserSearch) Next() ([]*UserSearchResult, error) {
+	return s.NextWithContext(context.Background())
+}
+
+func (s *UserSearch) NextWithContext(ctx context.Context) ([]*UserSearchResult, error) {
+
+	var (
+		results []*UserSearchResult
+	)
+
+	if len(s.buffered) > 0 {
+		// users left over from the last page
+		// are returned before the next page
+		results, s.buffered = s.buffered, nil
+		return results, nil
+	}
+	if s.done {
+		return nil, fmt.Errorf("no more users to return")
+	}
+	if len(s.filter.DeviceID) > 0 && len(s.filter.SpaceID) > 0 {
+		return nil, fmt.Errorf("users can be checked for access to either a device or a space but not both")
+	}
+
+	var query struct {
+		SearchUsers struct {
+			Users []struct {
+				UserID       graphql.String `graphql:"userID"`
+				UserName     graphql.String
+				FirstName    graphql.String
+				MiddleName   graphql.String
+				FamilyName   graphql.String
+				EmailAddress graphql.String
+				HasAccess    graphql.Boolean
+			}
+			NextCursor graphql.String
+		} `graphql:"searchUsers(filter: { userName: $userName, firstName: $firstName, familyName: $familyName, emailAddress: $emailAddress, partialMatch: $partialMatch }, accessTo: { deviceID: $deviceID, spaceID: $spaceID }, limit: $limit, cursor: $cursor)"`
+	}
+	variables := map[string]interface{}{
+		"userName":     optionalString(s.filter.UserName),
+		"firstName":    optionalString(s.filter.FirstName),
+		"familyName":   optionalString(s.filter.FamilyName),
+		"emailAddress": optionalString(s.filter.EmailAddress),
+		"partialMatch": graphql.Boolean(s.filter.PartialMatch),
+		"deviceID":     optionalID(s.filter.DeviceID),
+		"spaceID":      optionalID(s.filter.SpaceID),
+		"limit":        graphql.Int(s.filter.PageSize),
+		"cursor":       optionalString(s.cursor),
+	}
+	if err := s.userAPI.apiClient.Query(ctx, &query, variables); err != nil {
+		logger.ErrorMessage("UserSearch.Next(): searchUsers query returned an error: %s", err.Error())
+		return nil, apiError(err)
+	}
+	logger.TraceMessage("UserSearch.Next(): searchUsers query returned response: %# v", query)
+
+	results = make([]*UserSearchResult, 0, len(query.SearchUsers.Users))
+	for _, u := range query.SearchUsers.Users {
+		results = append(results, &UserSearchResult{
+			User: &userspace.User{
+				UserID:     string(u.UserID),
+				Name:       string(u.UserName),
+				FirstName:  string(u.FirstName),
+				MiddleName: string(u.MiddleName),
+				FamilyName: string(u.FamilyName),
+			},
+			EmailAddress: string(u.EmailAddress),
+			HasAccess:    bool(u.HasAccess),
+		})
+	}
+
+	s.cursor = string(query.SearchUsers.NextCursor)
+	s.done = len(s.cursor) == 0
+	return results, nil
+}
+
+// returns all the users matching the search from its
+// current page onwards up to the given maximum number
+// of users. a maximum of 0 returns all users. users
+// beyond the maximum are returned by the next call.
+func (s *UserSearch) All(max int) ([]*UserSearchResult, error) {
+	return s.AllWithContext(context.Background(), max)
+}
+
+func (s *UserSearch) AllWithContext(ctx context.Context, max int) ([]*UserSearchResult, error) {
+
+	var (
+		err error
+
+		page []*UserSearchResult
+	)
+
+	results := []*UserSearchResult{}
+	for s.HasNext() && (max == 0 || len(results) < max) {
+		if page, err = s.NextWithContext(ctx); err != nil {
+			return nil, err
+		}
+		results = append(results, page...)
+	}
+	if max > 0 && len(results) > max {
+		s.buffered = results[max:]
+		results = results[:max]
+	}
+	return results, nil
+}
+
+// empty values are sent as
+// null optional arguments
+func optionalString(value string) *graphql.String {
+	if len(value) == 0 {
+		return nil
+	}
+	return graphql.NewString(graphql.String(value))
+}
+
+func optionalID(value string) *graphql.ID {
+	if len(value) == 0 {
+		return nil
+	}
+	return graphql.NewID(graphql.ID(value))
+}
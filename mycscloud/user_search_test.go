@@ -0,0 +1,232 @@
+package mycscloud_test
+
+import (
+	"github.com/appbricks/cloud-builder/config"
+	"github.com/appbricks/mycloudspace-client/api"
+	"github.com/appbricks/mycloudspace-client/mycscloud"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	mycs_mocks "github.com/appbricks/mycloudspace-client/test/mocks"
+)
+
+var _ = Describe("User Search", func() {
+
+	var (
+		err error
+		cfg config.Config
+	)
+
+	BeforeEach(func() {
+		cfg, err = mycs_mocks.NewMockConfig(sourceDirPath)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("pages through the users matching a filter", func() {
+		testServer, testServerUrl := startTestServer()
+		defer testServer.Stop()
+		userAPI := mycscloud.NewUserAPI(api.NewGraphQLClient(testServerUrl, "", cfg.AuthContext()))
+
+		testServer.PushRequest().
+			ExpectJSONRequest(searchUsersFirstPageRequest).
+			RespondWith(searchUsersFirstPageResponse)
+		testServer.PushRequest().
+			ExpectJSONRequest(searchUsersLastPageRequest).
+			RespondWith(searchUsersLastPageResponse)
+
+		search := userAPI.SearchUsers(mycscloud.UserSearchFilter{
+			UserName:     "ram",
+			PartialMatch: true,
+			DeviceID:     "1234",
+			PageSize:     2,
+		})
+		Expect(search.HasNext()).To(BeTrue())
+
+		users, err := search.Next()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(users)).To(Equal(2))
+		Expect(users[0].UserID).To(Equal("12345"))
+		Expect(users[0].Name).To(Equal("ramsey"))
+		Expect(users[0].EmailAddress).To(Equal("ramsey@acme.com"))
+		Expect(users[0].HasAccess).To(BeTrue())
+		Expect(users[1].Name).To(Equal("ramiro"))
+		Expect(users[1].HasAccess).To(BeFalse())
+		Expect(search.HasNext()).To(BeTrue())
+
+		users, err = search.Next()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(users)).To(Equal(1))
+		Expect(users[0].Name).To(Equal("ramona"))
+		Expect(search.HasNext()).To(BeFalse())
+		Expect(testServer.Done()).To(BeTrue())
+
+		_, err = search.Next()
+		Expect(err).To(HaveOccurred())
+
+		// all users are returned
+		// after a reset
+		testServer.PushRequest().
+			ExpectJSONRequest(searchUsersFirstPageRequest).
+			RespondWith(searchUsersFirstPageResponse)
+		testServer.PushRequest().
+			ExpectJSONRequest(searchUsersLastPageRequest).
+			RespondWith(searchUsersLastPageResponse)
+
+		search.Reset()
+		users, err = search.All(0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(users)).To(Equal(3))
+		Expect(testServer.Done()).To(BeTrue())
+	})
+
+	It("returns users beyond the maximum requested with the next users", func() {
+		testServer, testServerUrl := startTestServer()
+		defer testServer.Stop()
+		userAPI := mycscloud.NewUserAPI(api.NewGraphQLClient(testServerUrl, "", cfg.AuthContext()))
+
+		testServer.PushRequest().
+			ExpectJSONRequest(searchUsersFirstPageRequest).
+			RespondWith(searchUsersFirstPageResponse)
+		testServer.PushRequest().
+			ExpectJSONRequest(searchUsersLastPageRequest).
+			RespondWith(searchUsersLastPageResponse)
+
+		search := userAPI.SearchUsers(mycscloud.UserSearchFilter{
+			UserName:     "ram",
+			PartialMatch: true,
+			DeviceID:     "1234",
+			PageSize:     2,
+		})
+
+		users, err := search.All(1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(users)).To(Equal(1))
+		Expect(users[0].Name).To(Equal("ramsey"))
+		Expect(search.HasNext()).To(BeTrue())
+
+		users, err = search.All(0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(users)).To(Equal(2))
+		Expect(users[0].Name).To(Equal("ramiro"))
+		Expect(users[1].Name).To(Equal("ramona"))
+		Expect(search.HasNext()).To(BeFalse())
+		Expect(testServer.Done()).To(BeTrue())
+	})
+
+	It("returns errors from the search", func() {
+		testServer, testServerUrl := startTestServer()
+		defer testServer.Stop()
+		userAPI := mycscloud.NewUserAPI(api.NewGraphQLClient(testServerUrl, "", cfg.AuthContext()))
+
+		_, err = userAPI.SearchUsers(mycscloud.UserSearchFilter{
+			UserName: "ram",
+			DeviceID: "1234",
+			SpaceID:  "5678",
+		}).Next()
+		Expect(err).To(HaveOccurred())
+
+		testServer.PushRequest().
+			ExpectJSONRequest(searchUsersByEmailRequest).
+			RespondWith(errorResponse)
+
+		search := userAPI.SearchUsers(mycscloud.UserSearchFilter{
+			EmailAddress: "ramsey@acme.com",
+			SpaceID:      "5678",
+		})
+		_, err = search.Next()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Message: a test error occurred, Locations: []"))
+		// the failed page can be retried
+		Expect(search.HasNext()).To(BeTrue())
+		Expect(testServer.Done()).To(BeTrue())
+	})
+})
+
+const searchUsersFirstPageRequest = `{
+	"query": "query ($cursor:String$deviceID:ID$emailAddress:String$familyName:String$firstName:String$limit:Int!$partialMatch:Boolean!$spaceID:ID$userName:String){searchUsers(filter: { userName: $userName, firstName: $firstName, familyName: $familyName, emailAddress: $emailAddress, partialMatch: $partialMatch }, accessTo: { deviceID: $deviceID, spaceID: $spaceID }, limit: $limit, cursor: $cursor){users{userID,userName,firstName,middleName,familyName,emailAddress,hasAccess},nextCursor}}",
+	"variables": {
+		"cursor": null,
+		"deviceID": "1234",
+		"emailAddress": null,
+		"familyName": null,
+		"firstName": null,
+		"limit": 2,
+		"partialMatch": true,
+		"spaceID": null,
+		"userName": "ram"
+	}
+}`
+const searchUsersFirstPageResponse = `{
+	"data": {
+		"searchUsers": {
+			"users": [
+				{
+					"userID": "12345",
+					"userName": "ramsey",
+					"firstName": "Ramsey",
+					"middleName": "X",
+					"familyName": "Havier",
+					"emailAddress": "ramsey@acme.com",
+					"hasAccess": true
+				},
+				{
+					"userID": "67890",
+					"userName": "ramiro",
+					"firstName": "Ramiro",
+					"middleName": "E",
+					"familyName": "Sales",
+					"emailAddress": "ramiro@acme.com",
+					"hasAccess": false
+				}
+			],
+			"nextCursor": "cursor #1"
+		}
+	}
+}`
+const searchUsersLastPageRequest = `{
+	"query": "query ($cursor:String$deviceID:ID$emailAddress:String$familyName:String$firstName:String$limit:Int!$partialMatch:Boolean!$spaceID:ID$userName:String){searchUsers(filter: { userName: $userName, firstName: $firstName, familyName: $familyName, emailAddress: $emailAddress, partialMatch: $partialMatch }, accessTo: { deviceID: $deviceID, spaceID: $spaceID }, limit: $limit, cursor: $cursor){users{userID,userName,firstName,middleName,familyName,emailAddress,hasAccess},nextCursor}}",
+	"variables": {
+		"cursor": "cursor #1",
+		"deviceID": "1234",
+		"emailAddress": null,
+		"familyName": null,
+		"firstName": null,
+		"limit": 2,
+		"partialMatch": true,
+		"spaceID": null,
+		"userName": "ram"
+	}
+}`
+const searchUsersLastPageResponse = `{
+	"data": {
+		"searchUsers": {
+			"users": [
+				{
+					"userID": "24680",
+					"userName": "ramona",
+					"firstName": "Ramona",
+					"middleName": "",
+					"familyName": "Flowers",
+					"emailAddress": "ramona@acme.com",
+					"hasAccess": false
+				}
+			],
+			"nextCursor": null
+		}
+	}
+}`
+const searchUsersByEmailRequest = `{
+	"query": "query ($cursor:String$deviceID:ID$emailAddress:String$familyName:String$firstName:String$limit:Int!$partialMatch:Boolean!$spaceID:ID$userName:String){searchUsers(filter: { userName: $userName, firstName: $firstName, familyName: $familyName, emailAddress: $emailAddress, partialMatch: $partialMatch }, accessTo: { deviceID: $deviceID, spaceID: $spaceID }, limit: $limit, cursor: $cursor){users{userID,userName,firstName,middleName,familyName,emailAddress,hasAccess},nextCursor}}",
+	"variables": {
+		"cursor": null,
+		"deviceID": null,
+		"emailAddress": "ramsey@acme.com",
+		"familyName": null,
+		"firstName": null,
+		"limit": 20,
+		"partialMatch": false,
+		"spaceID": "5678",
+		"userName": null
+	}
+}`
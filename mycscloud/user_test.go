@@ -1,6 +1,7 @@
 package mycscloud_test
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -223,6 +224,194 @@ var _ = Describe("User API", func() {
 		Expect(err).ToNot(HaveOccurred())
 		Expect(configTimestamp).To(Equal(timestamp + 300000))
 	})
+
+	It("rotates a user's key", func() {
+		testServer, userAPI := startMockNodeService()
+		defer testServer.Stop()
+
+		prevKey, err := crypto.NewRSAKey()
+		Expect(err).ToNot(HaveOccurred())
+		newKey, err := crypto.NewRSAKey()
+		Expect(err).ToNot(HaveOccurred())
+
+		user := &userspace.User{
+			UserID: "test user id",
+		}
+		err = user.SetKey(prevKey, false)
+		Expect(err).ToNot(HaveOccurred())
+		prevPublicKey := user.RSAPublicKey
+		prevKeyTimestamp := user.KeyTimestamp
+
+		configData, err := user.EncryptConfig([]byte("test config data"))
+		Expect(err).ToNot(HaveOccurred())
+		timestamp := time.Now().UnixMilli()
+
+		// expects the config pushed to be
+		// encrypted with the user's current key
+		expectConfig := func(asOf int64) func(w http.ResponseWriter, r *http.Request, body string) *string {
+			return func(w http.ResponseWriter, r *http.Request, body string) *string {
+				GinkgoRecover()
+
+				var requestBody interface{}
+				err = json.Unmarshal([]byte(body), &requestBody)
+				Expect(err).ToNot(HaveOccurred())
+
+				value, err := utils.GetValueAtPath("variables/asOf", requestBody)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(value).To(Equal(strconv.FormatInt(asOf, 10)))
+
+				value, err = utils.GetValueAtPath("variables/config", requestBody)
+				Expect(err).ToNot(HaveOccurred())
+				config, err := user.DecryptConfig(value.(string))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(config)).To(Equal("test config data"))
+
+				response := fmt.Sprintf(updateUserConfigResponse, asOf + 300000)
+				return &response
+			}
+		}
+
+		// rotation is rolled back if the
+		// re-encrypted config cannot be saved
+		testServer.PushRequest().
+			ExpectJSONRequest(getUserConfigRequest).
+			RespondWith(fmt.Sprintf(getUserConfigResponse, configData))
+		testServer.PushRequest().
+			RespondWith(updateUserKeyResponse)
+		testServer.PushRequest().
+			RespondWith(errorResponse)
+		testServer.PushRequest().
+			WithCallbackTest(func(w http.ResponseWriter, r *http.Request, body string) *string {
+				GinkgoRecover()
+
+				var requestBody interface{}
+				err = json.Unmarshal([]byte(body), &requestBody)
+				Expect(err).ToNot(HaveOccurred())
+
+				// the previous key is restored
+				value, err := utils.GetValueAtPath("variables/publicKey", requestBody)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(value).To(Equal(prevPublicKey))
+				value, err = utils.GetValueAtPath("variables/keyTimestamp", requestBody)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(value).To(Equal(strconv.FormatInt(prevKeyTimestamp, 10)))
+
+				response := updateUserKeyResponse
+				return &response
+			})
+
+		err = userAPI.RotateUserKey(user, newKey, timestamp, func(configTimestamp int64) error {
+			Fail("rotation should not have been committed")
+			return nil
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Message: a test error occurred, Locations: []"))
+		Expect(testServer.Done()).To(BeTrue())
+		Expect(user.RSAPublicKey).To(Equal(prevPublicKey))
+		Expect(user.KeyTimestamp).To(Equal(prevKeyTimestamp))
+
+		// the previous key is restored remotely if the
+		// key update fails as it may have been applied
+		testServer.PushRequest().
+			ExpectJSONRequest(getUserConfigRequest).
+			RespondWith(fmt.Sprintf(getUserConfigResponse, configData))
+		testServer.PushRequest().
+			RespondWith(errorResponse)
+		testServer.PushRequest().
+			WithCallbackTest(func(w http.ResponseWriter, r *http.Request, body string) *string {
+				GinkgoRecover()
+
+				var requestBody interface{}
+				err = json.Unmarshal([]byte(body), &requestBody)
+				Expect(err).ToNot(HaveOccurred())
+
+				value, err := utils.GetValueAtPath("variables/publicKey", requestBody)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(value).To(Equal(prevPublicKey))
+
+				response := updateUserKeyResponse
+				return &response
+			})
+
+		err = userAPI.RotateUserKey(user, newKey, timestamp, func(configTimestamp int64) error {
+			Fail("rotation should not have been committed")
+			return nil
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("Message: a test error occurred, Locations: []"))
+		Expect(testServer.Done()).To(BeTrue())
+		Expect(user.RSAPublicKey).To(Equal(prevPublicKey))
+
+		// rotation is rolled back if 
+		// the commit fails
+		testServer.PushRequest().
+			ExpectJSONRequest(getUserConfigRequest).
+			RespondWith(fmt.Sprintf(getUserConfigResponse, configData))
+		testServer.PushRequest().
+			RespondWith(updateUserKeyResponse)
+		testServer.PushRequest().
+			WithCallbackTest(expectConfig(timestamp))
+		testServer.PushRequest().
+			RespondWith(updateUserKeyResponse)
+		testServer.PushRequest().
+			WithCallbackTest(expectConfig(timestamp + 300000))
+
+		err = userAPI.RotateUserKey(user, newKey, timestamp, func(configTimestamp int64) error {
+			Expect(configTimestamp).To(Equal(timestamp + 300000))
+			Expect(user.RSAPublicKey).ToNot(Equal(prevPublicKey))
+			return fmt.Errorf("unable to save config")
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("unable to save config"))
+		Expect(testServer.Done()).To(BeTrue())
+		Expect(user.RSAPublicKey).To(Equal(prevPublicKey))
+
+		// rotation is rolled back even if the
+		// caller's context has been cancelled
+		testServer.PushRequest().
+			ExpectJSONRequest(getUserConfigRequest).
+			RespondWith(fmt.Sprintf(getUserConfigResponse, configData))
+		testServer.PushRequest().
+			RespondWith(updateUserKeyResponse)
+		testServer.PushRequest().
+			WithCallbackTest(expectConfig(timestamp))
+		testServer.PushRequest().
+			RespondWith(updateUserKeyResponse)
+		testServer.PushRequest().
+			WithCallbackTest(expectConfig(timestamp + 300000))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		err = userAPI.RotateUserKeyWithContext(ctx, user, newKey, timestamp, func(configTimestamp int64) error {
+			cancel()
+			return fmt.Errorf("unable to save config")
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("unable to save config"))
+		Expect(testServer.Done()).To(BeTrue())
+		Expect(user.RSAPublicKey).To(Equal(prevPublicKey))
+
+		// rotates the key
+		testServer.PushRequest().
+			ExpectJSONRequest(getUserConfigRequest).
+			RespondWith(fmt.Sprintf(getUserConfigResponse, configData))
+		testServer.PushRequest().
+			RespondWith(updateUserKeyResponse)
+		testServer.PushRequest().
+			WithCallbackTest(expectConfig(timestamp))
+
+		committed := int64(0)
+		err = userAPI.RotateUserKey(user, newKey, timestamp, func(configTimestamp int64) error {
+			committed = configTimestamp
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(testServer.Done()).To(BeTrue())
+		Expect(committed).To(Equal(timestamp + 300000))
+		Expect(user.RSAPublicKey).ToNot(Equal(prevPublicKey))
+
+		_, err = user.DecryptConfig(string(configData))
+		Expect(err).To(HaveOccurred())
+	})
 })
 
 const userSearchRequest = `{
@@ -0,0 +1,449 @@
+package mocks
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
+)
+
+// a local stand-in for an OIDC identity provider
+// which publishes its discovery document and
+// signing keys and issues signed id tokens
+type MockOIDCServer struct {
+	server *httptest.Server
+
+	signingKey jwk.Key
+	keySet     jwk.Set
+
+	keySetRequests int
+
+	// claims of the id tokens
+	// issued by the token endpoint
+	userClaims map[string]interface{}
+
+	// number of device access token requests
+	// that will be responded to with an
+	// authorization pending error
+	pendingPolls int
+	tokenPolls   int
+
+	// lifetime of issued tokens
+	tokenLifetime time.Duration
+	// refresh tokens are rejected
+	// once they have been revoked
+	refreshRevoked bool
+	// tokens are issued without an id token
+	omitIDTokens bool
+
+	// pending authorization code grants
+	authRequests map[string]mockAuthRequest
+	// overrides the nonce of issued id tokens
+	nonceOverride *string
+
+	mx sync.Mutex
+}
+
+type mockAuthRequest struct {
+	clientID,
+	redirectURI,
+	codeChallenge,
+	nonce string
+}
+
+const (
+	MockDeviceCode = "mock device code"
+	MockUserCode   = "WDJB-MJHT"
+
+	// credentials of the confidential client
+	// which may use the client credentials grant.
+	// as with Cognito no id token is issued for
+	// the grant as it does not identify a user.
+	MockMachineClientID     = "mock machine client id"
+	MockMachineClientSecret = "mock machine client secret"
+)
+
+func NewMockOIDCServer() (*MockOIDCServer, error) {
+
+	var (
+		err error
+	)
+
+	s := &MockOIDCServer{
+		userClaims:   make(map[string]interface{}),
+		authRequests: make(map[string]mockAuthRequest),
+
+		tokenLifetime: time.Hour,
+	}
+	if err = s.newSigningKey("mock-key-1"); err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.handleDiscovery)
+	mux.HandleFunc("/.well-known/jwks.json", s.handleJWKS)
+	mux.HandleFunc("/oauth2/authorize", s.handleAuthorize)
+	mux.HandleFunc("/oauth2/device_authorization", s.handleDeviceAuthorization)
+	mux.HandleFunc("/oauth2/token", s.handleToken)
+	s.server = httptest.NewUnstartedServer(mux)
+	return s, nil
+}
+
+func (s *MockOIDCServer) Start() {
+	s.server.Start()
+}
+
+func (s *MockOIDCServer) Stop() {
+	s.server.Close()
+}
+
+// the issuer URL of the mock provider
+func (s *MockOIDCServer) IssuerURL() string {
+	return s.server.URL
+}
+
+// replaces the key used to sign new tokens. the
+// previous keys remain in the published key set
+// so tokens signed with them can be verified.
+func (s *MockOIDCServer) RotateSigningKey(keyID string) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.newSigningKey(keyID)
+}
+
+// returns the number of requests
+// for the provider's key set
+func (s *MockOIDCServer) KeySetRequests() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.keySetRequests
+}
+
+// sets the claims of the id tokens
+// issued by the token endpoint
+func (s *MockOIDCServer) SetUserClaims(claims map[string]interface{}) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.userClaims = claims
+}
+
+// sets the number of device access token polls
+// to respond to with "authorization_pending"
+// before the login is completed
+func (s *MockOIDCServer) SetDeviceAuthPendingPolls(polls int) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.pendingPolls = polls
+}
+
+// overrides the nonce claim of the id tokens
+// issued for authorization code grants
+func (s *MockOIDCServer) SetIDTokenNonce(nonce string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.nonceOverride = &nonce
+}
+
+// sets the lifetime of the tokens
+// issued by the token endpoint
+func (s *MockOIDCServer) SetTokenLifetime(lifetime time.Duration) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.tokenLifetime = lifetime
+}
+
+// revokes all refresh tokens issued so
+// that refresh requests are rejected
+func (s *MockOIDCServer) RevokeRefreshTokens() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.refreshRevoked = true
+}
+
+// issues tokens without an id token for
+// all grants as a provider would if the
+// openid scope was not granted
+func (s *MockOIDCServer) OmitIDTokens(omit bool) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.omitIDTokens = omit
+}
+
+// returns the number of token requests
+// received by the token endpoint
+func (s *MockOIDCServer) TokenPolls() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.tokenPolls
+}
+
+// returns an id token with the given claims
+// signed with the provider's signing key. the
+// issuer, issued at and expiry claims are
+// added if not provided.
+func (s *MockOIDCServer) NewIDToken(claims map[string]interface{}) (string, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.newIDToken(claims)
+}
+
+func (s *MockOIDCServer) newIDToken(claims map[string]interface{}) (string, error) {
+
+	var (
+		err error
+
+		signed []byte
+	)
+
+	now := time.Now()
+	token := jwt.New()
+	for name, value := range map[string]interface{}{
+		jwt.IssuerKey:     s.server.URL,
+		jwt.IssuedAtKey:   now,
+		jwt.ExpirationKey: now.Add(s.tokenLifetime),
+	} {
+		if err = token.Set(name, value); err != nil {
+			return "", err
+		}
+	}
+	for name, value := range claims {
+		if err = token.Set(name, value); err != nil {
+			return "", err
+		}
+	}
+	if signed, err = jwt.Sign(token, jwa.RS256, s.signingKey); err != nil {
+		return "", err
+	}
+	return string(signed), nil
+}
+
+func (s *MockOIDCServer) newSigningKey(keyID string) error {
+
+	var (
+		err error
+
+		rsaKey *rsa.PrivateKey
+		pubKey jwk.Key
+	)
+
+	if rsaKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		return err
+	}
+	if s.signingKey, err = jwk.New(rsaKey); err != nil {
+		return err
+	}
+	if err = s.signingKey.Set(jwk.KeyIDKey, keyID); err != nil {
+		return err
+	}
+	if err = s.signingKey.Set(jwk.AlgorithmKey, jwa.RS256); err != nil {
+		return err
+	}
+	if pubKey, err = s.signingKey.PublicKey(); err != nil {
+		return err
+	}
+	if s.keySet == nil {
+		s.keySet = jwk.NewSet()
+	}
+	s.keySet.Add(pubKey)
+	return nil
+}
+
+func (s *MockOIDCServer) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"issuer":                        s.server.URL,
+		"authorization_endpoint":        s.server.URL + "/oauth2/authorize",
+		"token_endpoint":                s.server.URL + "/oauth2/token",
+		"device_authorization_endpoint": s.server.URL + "/oauth2/device_authorization",
+		"jwks_uri":                      s.server.URL + "/.well-known/jwks.json",
+		"scopes_supported":              []string{"openid", "profile"},
+	}); err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode discovery document: %s", err.Error()), http.StatusInternalServerError)
+	}
+}
+
+func (s *MockOIDCServer) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.keySetRequests++
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.keySet); err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode key set: %s", err.Error()), http.StatusInternalServerError)
+	}
+}
+
+// logs in the user without prompting and redirects
+// back to the client with an authorization code.
+// only public clients using PKCE are supported.
+func (s *MockOIDCServer) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	query := r.URL.Query()
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || len(redirectURI.Host) == 0 {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	response := url.Values{}
+	response.Set("state", query.Get("state"))
+
+	if query.Get("response_type") != "code" ||
+		query.Get("code_challenge_method") != "S256" ||
+		len(query.Get("code_challenge")) == 0 {
+
+		response.Set("error", "invalid_request")
+		response.Set("error_description", "PKCE S256 code challenge is required")
+	} else {
+		code := fmt.Sprintf("mock authorization code #%d", len(s.authRequests)+1)
+		s.authRequests[code] = mockAuthRequest{
+			clientID:      query.Get("client_id"),
+			redirectURI:   redirectURI.String(),
+			codeChallenge: query.Get("code_challenge"),
+			nonce:         query.Get("nonce"),
+		}
+		response.Set("code", code)
+	}
+	redirectURI.RawQuery = response.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (s *MockOIDCServer) handleDeviceAuthorization(w http.ResponseWriter, r *http.Request) {
+
+	if err := r.ParseForm(); err != nil || len(r.Form.Get("client_id")) == 0 {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"device_code":               MockDeviceCode,
+		"user_code":                 MockUserCode,
+		"verification_uri":          s.server.URL + "/device",
+		"verification_uri_complete": s.server.URL + "/device?user_code=" + MockUserCode,
+		"expires_in":                300,
+		"interval":                  1,
+	})
+}
+
+func (s *MockOIDCServer) handleToken(w http.ResponseWriter, r *http.Request) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+	s.tokenPolls++
+
+	// confidential clients authenticate
+	// with their id and secret
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		// credentials are form encoded
+		// within the basic auth header
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID, clientSecret = r.Form.Get("client_id"), r.Form.Get("client_secret")
+	}
+	claims := s.userClaims
+
+	switch r.Form.Get("grant_type") {
+	case "authorization_code":
+		authRequest, ok := s.authRequests[r.Form.Get("code")]
+		if !ok ||
+			authRequest.clientID != r.Form.Get("client_id") ||
+			authRequest.redirectURI != r.Form.Get("redirect_uri") {
+
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant")
+			return
+		}
+		verifierHash := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(verifierHash[:]) != authRequest.codeChallenge {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant")
+			return
+		}
+		delete(s.authRequests, r.Form.Get("code"))
+
+		claims = copyClaims(s.userClaims)
+		claims["nonce"] = authRequest.nonce
+		if s.nonceOverride != nil {
+			claims["nonce"] = *s.nonceOverride
+		}
+
+	case "refresh_token":
+		if s.refreshRevoked || r.Form.Get("refresh_token") != "mock refresh token" {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant")
+			return
+		}
+
+	case "client_credentials":
+		if clientID != MockMachineClientID || clientSecret != MockMachineClientSecret {
+			writeOAuthError(w, http.StatusUnauthorized, "invalid_client")
+			return
+		}
+		claims = nil
+
+	case "urn:ietf:params:oauth:grant-type:device_code":
+		if r.Form.Get("device_code") != MockDeviceCode {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant")
+			return
+		}
+		if s.tokenPolls <= s.pendingPolls {
+			writeOAuthError(w, http.StatusBadRequest, "authorization_pending")
+			return
+		}
+	default:
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	response := map[string]interface{}{
+		"access_token":  fmt.Sprintf("mock access token #%d", s.tokenPolls),
+		"refresh_token": "mock refresh token",
+		"token_type":    "Bearer",
+		"expires_in":    int(s.tokenLifetime.Seconds()),
+	}
+	if claims != nil && !s.omitIDTokens {
+		if _, exists := claims["aud"]; !exists {
+			claims = copyClaims(claims)
+			claims["aud"] = clientID
+		}
+		idToken, err := s.newIDToken(claims)
+		if err != nil {
+			writeOAuthError(w, http.StatusInternalServerError, "server_error")
+			return
+		}
+		response["id_token"] = idToken
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func copyClaims(claims map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{})
+	for name, value := range claims {
+		c[name] = value
+	}
+	return c
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, errorCode string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": errorCode})
+}
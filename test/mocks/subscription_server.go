@@ -0,0 +1,204 @@
+package mocks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+)
+
+// a local stand-in for the MyCS cloud graphql
+// subscription service which implements the
+// graphql-ws websocket protocol
+type MockSubscriptionServer struct {
+	server *httptest.Server
+
+	// connection init params of the
+	// last client that connected
+	connectionParams map[string]interface{}
+	// number of connections accepted
+	connectCount int
+
+	conns   map[*websocket.Conn]context.CancelFunc
+	started chan *MockSubscription
+
+	mx sync.Mutex
+}
+
+type MockSubscription struct {
+	ID        string
+	Query     string
+	Variables map[string]interface{}
+
+	conn *websocket.Conn
+}
+
+type operationMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+func NewMockSubscriptionServer() *MockSubscriptionServer {
+
+	s := &MockSubscriptionServer{
+		conns:   make(map[*websocket.Conn]context.CancelFunc),
+		started: make(chan *MockSubscription, 100),
+	}
+	s.server = httptest.NewUnstartedServer(http.HandlerFunc(s.handleConnection))
+	return s
+}
+
+func (s *MockSubscriptionServer) Start() {
+	s.server.Start()
+}
+
+func (s *MockSubscriptionServer) Stop() {
+	s.DropConnections()
+	s.server.Close()
+}
+
+// returns the websocket url of the server
+func (s *MockSubscriptionServer) URL() string {
+	return "ws" + strings.TrimPrefix(s.server.URL, "http")
+}
+
+// returns the connection params sent by
+// the client that last connected
+func (s *MockSubscriptionServer) ConnectionParams() map[string]interface{} {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.connectionParams
+}
+
+// returns the number of client
+// connections that were accepted
+func (s *MockSubscriptionServer) ConnectCount() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.connectCount
+}
+
+// waits for a client to start a subscription
+func (s *MockSubscriptionServer) WaitForSubscription(timeout time.Duration) (*MockSubscription, error) {
+	select {
+	case sub := <-s.started:
+		return sub, nil
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("timed out waiting for a subscription to start")
+	}
+}
+
+// sends the given json data to the subscription
+func (s *MockSubscriptionServer) Publish(sub *MockSubscription, data string) error {
+	return s.send(sub, fmt.Sprintf(`{"data":%s}`, data))
+}
+
+// sends the given graphql error to the subscription
+func (s *MockSubscriptionServer) PublishError(sub *MockSubscription, message string) error {
+	return s.send(sub, fmt.Sprintf(`{"errors":[{"message":%q}]}`, message))
+}
+
+// drops all client connections without
+// following the close handshake
+func (s *MockSubscriptionServer) DropConnections() {
+	s.mx.Lock()
+	conns := s.conns
+	s.conns = make(map[*websocket.Conn]context.CancelFunc)
+	s.mx.Unlock()
+
+	// cancelling a connection's read
+	// context closes it immediately
+	for _, cancel := range conns {
+		cancel()
+	}
+}
+
+func (s *MockSubscriptionServer) send(sub *MockSubscription, payload string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return wsjson.Write(ctx, sub.conn, operationMessage{
+		ID:      sub.ID,
+		Type:    "data",
+		Payload: json.RawMessage(payload),
+	})
+}
+
+func (s *MockSubscriptionServer) handleConnection(w http.ResponseWriter, r *http.Request) {
+
+	var (
+		err error
+
+		conn *websocket.Conn
+	)
+
+	if conn, err = websocket.Accept(w, r, &websocket.AcceptOptions{
+		Subprotocols: []string{"graphql-ws"},
+	}); err != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s.mx.Lock()
+	s.conns[conn] = cancel
+	s.connectCount++
+	s.mx.Unlock()
+
+	defer func() {
+		s.mx.Lock()
+		delete(s.conns, conn)
+		s.mx.Unlock()
+		cancel()
+	}()
+
+	for {
+		msg := operationMessage{}
+		if err = wsjson.Read(ctx, conn, &msg); err != nil {
+			return
+		}
+
+		switch msg.Type {
+		case "connection_init":
+			params := make(map[string]interface{})
+			if len(msg.Payload) > 0 {
+				_ = json.Unmarshal(msg.Payload, &params)
+			}
+			s.mx.Lock()
+			s.connectionParams = params
+			s.mx.Unlock()
+
+			if err = wsjson.Write(ctx, conn, operationMessage{Type: "connection_ack"}); err != nil {
+				return
+			}
+
+		case "start":
+			payload := struct {
+				Query     string                 `json:"query"`
+				Variables map[string]interface{} `json:"variables"`
+			}{}
+			if err = json.Unmarshal(msg.Payload, &payload); err != nil {
+				return
+			}
+			sub := &MockSubscription{
+				ID:        msg.ID,
+				Query:     payload.Query,
+				Variables: payload.Variables,
+
+				conn: conn,
+			}
+			s.started <- sub
+
+		case "connection_terminate":
+			_ = conn.Close(websocket.StatusNormalClosure, "")
+			return
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package mocks
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/appbricks/mycloudspace-client/ui"
+)
+
+// a scripted ui.UI which records all messages
+// shown and answers input requests from queues
+// of pre-set responses
+type MockUI struct {
+	messages []*MockUIMessage
+
+	// responses to input requests
+	inputs      []*string
+	yesNoInputs []bool
+
+	mx sync.Mutex
+}
+
+type MockUIMessage struct {
+	Title string
+	Lines []string
+
+	Dismissed bool
+	Cancel    context.CancelFunc
+
+	ui *MockUI
+}
+
+type mockProgressMessage struct{}
+
+func NewMockUI() *MockUI {
+	return &MockUI{}
+}
+
+// queues a response to the next text,
+// secure or file input request. a nil
+// response cancels the input.
+func (u *MockUI) AddInput(input *string) {
+	u.mx.Lock()
+	defer u.mx.Unlock()
+	u.inputs = append(u.inputs, input)
+}
+
+// queues a response to the
+// next yes/no input request
+func (u *MockUI) AddYesNoInput(yes bool) {
+	u.mx.Lock()
+	defer u.mx.Unlock()
+	u.yesNoInputs = append(u.yesNoInputs, yes)
+}
+
+// returns all messages shown so far
+func (u *MockUI) Messages() []*MockUIMessage {
+	u.mx.Lock()
+	defer u.mx.Unlock()
+	return append([]*MockUIMessage{}, u.messages...)
+}
+
+// returns all text written to
+// the messages shown so far
+func (u *MockUI) Text() string {
+	u.mx.Lock()
+	defer u.mx.Unlock()
+
+	text := strings.Builder{}
+	for _, m := range u.messages {
+		text.WriteString(m.Title)
+		text.WriteByte('\n')
+		for _, l := range m.Lines {
+			text.WriteString(l)
+			text.WriteByte('\n')
+		}
+	}
+	return text.String()
+}
+
+// waits until text containing the given
+// string has been written to the UI
+func (u *MockUI) WaitForText(s string, timeout time.Duration) bool {
+	for end := time.Now().Add(timeout); time.Now().Before(end); {
+		if strings.Contains(u.Text(), s) {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func (u *MockUI) NewUIMessage(title string) ui.Message {
+	return u.NewUIMessageWithCancel(title, nil)
+}
+
+func (u *MockUI) NewUIMessageWithCancel(title string, cancel context.CancelFunc) ui.Message {
+	u.mx.Lock()
+	defer u.mx.Unlock()
+
+	m := &MockUIMessage{
+		Title:  title,
+		Cancel: cancel,
+		ui:     u,
+	}
+	u.messages = append(u.messages, m)
+	return m
+}
+
+func (u *MockUI) ShowErrorMessage(message string) {
+	u.NewUIMessage("Error").WriteErrorMessage(message)
+}
+
+func (u *MockUI) ShowInfoMessage(title, message string) {
+	u.NewUIMessage(title).WriteInfoMessage(message)
+}
+
+func (u *MockUI) ShowNoteMessage(title, message string) {
+	u.NewUIMessage(title).WriteNoteMessage(message)
+}
+
+func (u *MockUI) ShowNoticeMessage(title, message string) {
+	u.NewUIMessage(title).WriteNoticeMessage(message)
+}
+
+func (m *MockUIMessage) write(kind, message string) {
+	m.ui.mx.Lock()
+	defer m.ui.mx.Unlock()
+	m.Lines = append(m.Lines, fmt.Sprintf("%s: %s", kind, message))
+}
+
+func (m *MockUIMessage) WriteMessage(message string)        { m.write("message", message) }
+func (m *MockUIMessage) WriteCommentMessage(message string) { m.write("comment", message) }
+func (m *MockUIMessage) WriteInfoMessage(message string)    { m.write("info", message) }
+func (m *MockUIMessage) WriteNoteMessage(message string)    { m.write("note", message) }
+func (m *MockUIMessage) WriteNoticeMessage(message string)  { m.write("notice", message) }
+func (m *MockUIMessage) WriteErrorMessage(message string)   { m.write("error", message) }
+func (m *MockUIMessage) WriteDangerMessage(message string)  { m.write("danger", message) }
+func (m *MockUIMessage) WriteFatalMessage(message string)   { m.write("fatal", message) }
+func (m *MockUIMessage) WriteText(text string)              { m.write("text", text) }
+
+// input handlers are called asynchronously as
+// they may block until the input is consumed
+func (m *MockUIMessage) ShowMessageWithInput(defaultInput string, handleInput func(*string)) {
+	go handleInput(m.nextInput())
+}
+
+func (m *MockUIMessage) ShowMessageWithSecureInput(handleInput func(*string)) {
+	go handleInput(m.nextInput())
+}
+
+func (m *MockUIMessage) ShowMessageWithSecureVerifiedInput(handleInput func(*string)) {
+	go handleInput(m.nextInput())
+}
+
+func (m *MockUIMessage) ShowMessageWithFileInput(handleInput func(*string)) {
+	go handleInput(m.nextInput())
+}
+
+func (m *MockUIMessage) ShowMessageWithYesNoInput(handleInput func(bool)) {
+	m.ui.mx.Lock()
+	yes := false
+	if len(m.ui.yesNoInputs) > 0 {
+		yes = m.ui.yesNoInputs[0]
+		m.ui.yesNoInputs = m.ui.yesNoInputs[1:]
+	}
+	m.ui.mx.Unlock()
+
+	go handleInput(yes)
+}
+
+func (m *MockUIMessage) DismissMessage() {
+	m.ui.mx.Lock()
+	defer m.ui.mx.Unlock()
+	m.Dismissed = true
+}
+
+func (m *MockUIMessage) ShowMessageWithProgressIndicator(startMsg, progressMsg, endMsg string, doneAt int) ui.ProgressMessage {
+	m.write("progress", startMsg)
+	return &mockProgressMessage{}
+}
+
+func (m *MockUIMessage) nextInput() *string {
+	m.ui.mx.Lock()
+	defer m.ui.mx.Unlock()
+
+	if len(m.ui.inputs) == 0 {
+		return nil
+	}
+	input := m.ui.inputs[0]
+	m.ui.inputs = m.ui.inputs[1:]
+	return input
+}
+
+func (p *mockProgressMessage) Start()                                  {}
+func (p *mockProgressMessage) Update(updateMsg string, progressAt int) {}
+func (p *mockProgressMessage) Done()                                   {}